@@ -1,49 +1,581 @@
 package controllers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/ayushwar/major/database"
 	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// CreatePayment - User initiates payment
+// CreatePayment → POST /payments {course_id, coupon_code}
+// Prices the course on the server (see POST /payments/quote), creates a gateway order and a
+// pending payment keyed on the order id. The client completes checkout with the returned
+// order; the webhook settles it and enrolls the student. A fully discounted course is
+// settled at once without the gateway. Starting a new checkout expires the student's open one
+// for the course; paying both anyway opens a refund request for the second payment.
 func CreatePayment(ctx *gin.Context) {
+	userID, ok := getContextUserID(ctx)
+	if !ok {
+		ctx.JSON(401, gin.H{"error": "unauthorized"})
+		return
+	}
+
+	var input struct {
+		CourseID      uint   `json:"course_id" binding:"required"`
+		CouponCode    string `json:"coupon_code"`
+		PaymentMethod string `json:"payment_method"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+
+	var course models.Course
+	if err := database.DB.First(&course, input.CourseID).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "course not found"})
+		return
+	}
+	if !course.RequiresPayment() {
+		ctx.JSON(400, gin.H{"error": "course is free", "details": "enroll directly with POST /enrollments"})
+		return
+	}
+
+	var enrolled int64
+	database.DB.Model(&models.Enrollment{}).
+		Where("user_id = ? AND course_id = ? AND status <> ?", userID, course.ID, models.EnrollmentSuspended).
+		Count(&enrolled)
+	if enrolled > 0 {
+		ctx.JSON(409, gin.H{"error": "already enrolled in this course"})
+		return
+	}
+	var paid int64
+	database.DB.Model(&models.Payment{}).Where("user_id = ? AND course_id = ? AND status = ?", userID, course.ID, models.PaymentSuccess).Count(&paid)
+	if paid > 0 {
+		ctx.JSON(409, gin.H{"error": "course already paid for"})
+		return
+	}
+
+	// Only the newest checkout stays open, so its coupon use is given back before pricing this one;
+	// a late capture of the old order is flagged as a duplicate
+	if err := database.DB.Model(&models.Payment{}).
+		Where("user_id = ? AND course_id = ? AND status = ?", userID, course.ID, models.PaymentPending).
+		Updates(map[string]interface{}{"status": models.PaymentExpired, "failure_reason": "replaced by a newer checkout"}).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to close the previous checkout", "details": err.Error()})
+		return
+	}
+
+	quote, err := quoteCourse(database.DB, userID, course, input.CouponCode)
+	if err != nil {
+		if reason, ok := err.(couponError); ok {
+			ctx.JSON(400, gin.H{"error": "coupon cannot be applied", "details": reason.Error()})
+			return
+		}
+		ctx.JSON(500, gin.H{"error": "failed to price course", "details": err.Error()})
+		return
+	}
+
+	payment := models.Payment{
+		UserID:          userID,
+		CourseID:        course.ID,
+		Amount:          quote.Amount,
+		Currency:        quote.Currency,
+		DiscountApplied: quote.Discount,
+		PaymentMethod:   input.PaymentMethod,
+		Status:          models.PaymentPending,
+	}
+
+	var order *utils.Order
+	if quote.Amount > 0 {
+		gw, err := utils.Gateway()
+		if err != nil {
+			ctx.JSON(500, gin.H{"error": "payment gateway is not configured", "details": err.Error()})
+			return
+		}
+		created, err := gw.CreateOrder(utils.OrderRequest{
+			Amount:   utils.ToMinorUnits(payment.Amount),
+			Currency: payment.Currency,
+			Receipt:  fmt.Sprintf("u%d-c%d-%d", userID, course.ID, time.Now().Unix()),
+			Notes:    map[string]string{"user_id": fmt.Sprint(userID), "course_id": fmt.Sprint(course.ID)},
+		})
+		if err != nil {
+			ctx.JSON(502, gin.H{"error": "failed to create gateway order", "details": err.Error()})
+			return
+		}
+		order = &created
+		payment.Gateway, payment.TransactionID = gw.Name(), created.OrderID
+	} else {
+		payment.Gateway, payment.TransactionID = "none", utils.RandomReference("free_")
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if quote.coupon != nil {
+			// Re-check the caps with the coupon locked so two checkouts cannot both take the last use
+			var locked models.Coupon
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, quote.coupon.ID).Error; err != nil {
+				return err
+			}
+			again, err := quoteCourse(tx, userID, course, input.CouponCode)
+			if err != nil {
+				return err
+			}
+			if again.Amount != quote.Amount {
+				return couponError("the price changed while checking out; request a new quote")
+			}
+		}
+
+		if err := tx.Create(&payment).Error; err != nil {
+			return err
+		}
+		if quote.coupon != nil {
+			redemption := models.CouponRedemption{CouponID: quote.coupon.ID, UserID: userID, PaymentID: payment.ID}
+			for _, line := range quote.Discounts {
+				if line.Kind == discountCoupon {
+					redemption.Discount = line.Amount
+				}
+			}
+			if err := tx.Create(&redemption).Error; err != nil {
+				return err
+			}
+		}
+		if order == nil {
+			var err error
+			payment, _, err = applyPaymentStatus(tx, payment.TransactionID, models.PaymentSuccess, paymentUpdate{Method: "coupon"})
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if reason, ok := err.(couponError); ok {
+			ctx.JSON(409, gin.H{"error": "coupon cannot be applied", "details": reason.Error()})
+			return
+		}
+		ctx.JSON(500, gin.H{"error": "failed to create payment", "details": err.Error()})
+		return
+	}
+
+	response := gin.H{"message": "payment created successfully", "payment": payment, "quote": quote}
+	if order != nil {
+		response["order"] = order
+	} else {
+		response["message"] = "course fully discounted; enrolled without payment"
+	}
+	ctx.JSON(201, response)
+}
+
+// loadOwnPayment → the payment in :id if it belongs to the caller (admins see all)
+func loadOwnPayment(ctx *gin.Context) (models.Payment, bool) {
 	var payment models.Payment
+	if err := database.DB.Preload("Course").First(&payment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "payment not found"})
+		return payment, false
+	}
+	userID, _ := getContextUserID(ctx)
+	if getUserRole(ctx) != "admin" && payment.UserID != userID {
+		ctx.JSON(403, gin.H{"error": "not your payment"})
+		return payment, false
+	}
+	return payment, true
+}
 
-	if err := ctx.ShouldBindJSON(&payment); err != nil {
-		ctx.JSON(400, gin.H{"error": err.Error()})
+// GetPayment → GET /payments/:id (owner/admin)
+func GetPayment(ctx *gin.Context) {
+	payment, ok := loadOwnPayment(ctx)
+	if !ok {
 		return
 	}
+	ctx.JSON(200, gin.H{"payment": payment})
+}
+
+// GetMyPayments → GET /payments/me
+func GetMyPayments(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+	var payments []models.Payment
+	if err := database.DB.Preload("Course").Where("user_id = ?", userID).Order("created_at DESC").Find(&payments).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch payments", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"payments": payments})
+}
+
+// paymentUpdate is what the gateway told us alongside a status
+type paymentUpdate struct {
+	GatewayPaymentID string
+	Method           string
+	Reason           string
+}
+
+// paymentTransitionAllowed → pending settles either way; a failed or expired order can still be
+// paid on a later attempt (the money was taken); success only moves on through refunds
+func paymentTransitionAllowed(from, to string) bool {
+	switch from {
+	case models.PaymentPending:
+		return to == models.PaymentSuccess || to == models.PaymentFailed
+	case models.PaymentFailed, models.PaymentExpired:
+		return to == models.PaymentSuccess
+	}
+	return false
+}
+
+// applyPaymentStatus moves the payment with this TransactionID to status, locking the row so
+// concurrent deliveries (webhook and checkout callback) apply once. changed is false for repeats.
+func applyPaymentStatus(tx *gorm.DB, transactionID, status string, update paymentUpdate) (models.Payment, bool, error) {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("transaction_id = ?", transactionID).First(&payment).Error; err != nil {
+		return payment, false, err
+	}
+	if !paymentTransitionAllowed(payment.Status, status) {
+		return payment, false, nil
+	}
+
+	// Expired and failed payments gave their coupon use back, which someone may have taken since
+	late := payment.Status == models.PaymentExpired || payment.Status == models.PaymentFailed
+	payment.Status = status
+	if update.GatewayPaymentID != "" {
+		payment.GatewayPaymentID = update.GatewayPaymentID
+	}
+	if update.Method != "" {
+		payment.PaymentMethod = update.Method
+	}
+	switch status {
+	case models.PaymentSuccess:
+		now := time.Now()
+		payment.PaidAt, payment.FailureReason = &now, ""
+	case models.PaymentFailed:
+		payment.FailureReason = update.Reason
+		if len(payment.FailureReason) > 255 {
+			payment.FailureReason = payment.FailureReason[:255]
+		}
+	}
+	if err := tx.Save(&payment).Error; err != nil {
+		return payment, false, err
+	}
+	if status == models.PaymentSuccess {
+		if err := onPaymentSucceeded(tx, payment); err != nil {
+			return payment, false, err
+		}
+		if late {
+			if err := flagLateCouponUse(tx, payment); err != nil {
+				return payment, false, err
+			}
+		}
+	}
+	return payment, true, nil
+}
+
+// onPaymentSucceeded runs in the settling transaction, so a failure here leaves the payment
+// pending and the gateway redelivers
+func onPaymentSucceeded(tx *gorm.DB, payment models.Payment) error {
+	if _, _, err := enrollStudent(tx, payment.UserID, payment.CourseID); err != nil {
+		return err
+	}
+	if _, err := createInvoice(tx, payment); err != nil {
+		return err
+	}
+	if err := postPaymentLedger(tx, payment); err != nil {
+		return err
+	}
+	return flagDuplicatePayment(tx, payment)
+}
+
+// flagDuplicatePayment opens a full refund request when the student had already paid for the
+// course (e.g. a late capture of an expired checkout), for an admin to approve
+func flagDuplicatePayment(tx *gorm.DB, payment models.Payment) error {
+	var earlier models.Payment
+	err := tx.Where("user_id = ? AND course_id = ? AND status = ? AND id <> ?", payment.UserID, payment.CourseID, models.PaymentSuccess, payment.ID).
+		Order("id").First(&earlier).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return flagPaymentForRefund(tx, payment, fmt.Sprintf("duplicate payment: course already paid for with order %s", earlier.TransactionID), true)
+}
+
+// flagLateCouponUse opens a full refund request when an expired or failed payment is captured
+// after its coupon use was given back and the coupon has since reached its cap. An admin
+// either refunds it or rejects the request to honour the discount.
+func flagLateCouponUse(tx *gorm.DB, payment models.Payment) error {
+	var redemption models.CouponRedemption
+	err := tx.Where("payment_id = ?", payment.ID).First(&redemption).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var coupon models.Coupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, redemption.CouponID).Error; err != nil {
+		return err
+	}
+
+	over := false
+	if coupon.MaxUses > 0 {
+		used, err := couponUses(tx, coupon.ID, nil)
+		if err != nil {
+			return err
+		}
+		over = used > int64(coupon.MaxUses)
+	}
+	if coupon.MaxUsesPerUser > 0 && !over {
+		used, err := couponUses(tx, coupon.ID, &payment.UserID)
+		if err != nil {
+			return err
+		}
+		over = used > int64(coupon.MaxUsesPerUser)
+	}
+	if !over {
+		return nil
+	}
+	return flagPaymentForRefund(tx, payment, fmt.Sprintf("coupon %s reached its usage limit before this late capture", coupon.Code), false)
+}
+
+// flagPaymentForRefund opens a refund request for the whole payment unless one is already open
+func flagPaymentForRefund(tx *gorm.DB, payment models.Payment, reason string, keepEnrollment bool) error {
+	if payment.Amount <= 0 {
+		return nil
+	}
+	var open int64
+	if err := tx.Model(&models.RefundRequest{}).
+		Where("payment_id = ? AND status IN ?", payment.ID, []string{models.RefundRequested, models.RefundProcessing, models.RefundFailed}).
+		Count(&open).Error; err != nil {
+		return err
+	}
+	if open > 0 {
+		return nil
+	}
+	return tx.Create(&models.RefundRequest{
+		PaymentID:      payment.ID,
+		UserID:         payment.UserID,
+		Amount:         payment.Amount,
+		Reason:         reason,
+		Status:         models.RefundRequested,
+		KeepEnrollment: keepEnrollment,
+	}).Error
+}
+
+// processPaymentWebhook applies a verified webhook body once per event id
+func processPaymentWebhook(gw utils.PaymentGateway, body []byte, eventID string) (string, error) {
+	event, err := gw.ParseWebhook(body)
+	if err != nil {
+		return "", err
+	}
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = hex.EncodeToString(sum[:])
+	}
+
+	result := ""
+	var settled uint
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var seen int64
+		if err := tx.Model(&models.PaymentEvent{}).Where("event_id = ?", eventID).Count(&seen).Error; err != nil {
+			return err
+		}
+		if seen > 0 {
+			result = "duplicate"
+			return nil
+		}
+
+		record := models.PaymentEvent{Gateway: gw.Name(), EventID: eventID, Type: event.Type, TransactionID: event.OrderID, Payload: string(body)}
 
-	// Step 1: Fetch profile of the user
-	var profile models.Profile
-	if err := database.DB.Where("user_id = ?", payment.UserID).First(&profile).Error; err == nil {
-		// Step 2: Check if student is verified
-		if profile.Verified {
-			// Example: 20% discount
-			discount := payment.Amount * 0.20
-			payment.DiscountApplied = discount
-			payment.Amount = payment.Amount - discount
+		var status string
+		switch event.Status {
+		case utils.GatewayPaymentCaptured:
+			status = models.PaymentSuccess
+		case utils.GatewayPaymentFailed:
+			status = models.PaymentFailed
+		}
+
+		// Refund events may only carry the gateway's payment id
+		var payment models.Payment
+		found := false
+		switch {
+		case event.OrderID != "":
+			found = tx.Where("transaction_id = ?", event.OrderID).First(&payment).Error == nil
+		case event.PaymentID != "":
+			found = tx.Where("gateway_payment_id = ?", event.PaymentID).First(&payment).Error == nil
+		}
+		if found {
+			record.PaymentID, record.TransactionID = &payment.ID, payment.TransactionID
 		}
+
+		changed := false
+		switch {
+		case event.Status == "":
+			record.Result = models.PaymentEventIgnored
+		case !found:
+			record.Result = models.PaymentEventUnknownOrder
+		case event.Status == utils.GatewayRefundProcessed:
+			if changed, err = recordGatewayRefund(tx, payment, event); err != nil {
+				return err
+			}
+		case status == models.PaymentSuccess && event.Amount != 0 && event.Amount != utils.ToMinorUnits(payment.Amount):
+			// Never settle a payment for a different amount than the order we priced
+			record.Result = models.PaymentEventAmountMismatch
+			fmt.Println("ERROR: payment", payment.ID, "captured", event.Amount, "but order was", utils.ToMinorUnits(payment.Amount))
+		default:
+			if payment, changed, err = applyPaymentStatus(tx, payment.TransactionID, status, paymentUpdate{
+				GatewayPaymentID: event.PaymentID,
+				Method:           event.Method,
+				Reason:           event.Reason,
+			}); err != nil {
+				return err
+			}
+			if changed && status == models.PaymentSuccess {
+				settled = payment.ID
+			}
+			// Also for a payment the checkout callback settled first
+			if event.Fee > 0 && payment.Status == models.PaymentSuccess {
+				if err := postGatewayFee(tx, payment, utils.FromMinorUnits(event.Fee)); err != nil {
+					return err
+				}
+			}
+		}
+		if record.Result == "" {
+			record.Result = models.PaymentEventUnchanged
+			if changed {
+				record.Result = models.PaymentEventApplied
+			}
+		}
+
+		result = record.Result
+		return tx.Create(&record).Error
+	})
+	if err == nil && settled != 0 {
+		go emailInvoice(settled)
+	}
+	return result, err
+}
+
+// PaymentWebhook → POST /payments/webhook (public, HMAC signed)
+// Gateways retry until they get a 2xx, so anything we have understood is acknowledged with
+// 200, including duplicates and orders we do not know.
+func PaymentWebhook(ctx *gin.Context) {
+	gw, err := utils.Gateway()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "payment gateway is not configured", "details": err.Error()})
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, 1<<20))
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "failed to read body", "details": err.Error()})
+		return
+	}
+	if !gw.VerifyWebhookSignature(body, ctx.GetHeader("X-Razorpay-Signature")) {
+		ctx.JSON(400, gin.H{"error": "invalid signature"})
+		return
 	}
 
-	// Step 3: Set default values
-	payment.Status = "pending"
-	payment.CreatedAt = time.Now()
+	result, err := processPaymentWebhook(gw, body, ctx.GetHeader("X-Razorpay-Event-Id"))
+	if err != nil {
+		fmt.Println("ERROR: payment webhook:", err)
+		ctx.JSON(500, gin.H{"error": "failed to process webhook", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"status": result})
+}
 
-	// Step 4: Save payment
-	if err := database.DB.Create(&payment).Error; err != nil {
-		ctx.JSON(500, gin.H{"error": "failed to create payment"})
+// VerifyPayment → POST /payments/verify
+// Checkout callback: the browser posts the ids and signature the gateway handed it, so the
+// student sees the result without waiting for the webhook. Both paths settle idempotently.
+func VerifyPayment(ctx *gin.Context) {
+	var input struct {
+		OrderID   string `json:"razorpay_order_id" binding:"required"`
+		PaymentID string `json:"razorpay_payment_id" binding:"required"`
+		Signature string `json:"razorpay_signature" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+
+	gw, err := utils.Gateway()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "payment gateway is not configured", "details": err.Error()})
+		return
+	}
+	if !gw.VerifyPaymentSignature(input.OrderID, input.PaymentID, input.Signature) {
+		ctx.JSON(400, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	var payment models.Payment
+	if err := database.DB.Where("transaction_id = ?", input.OrderID).First(&payment).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "payment not found"})
+		return
+	}
+	userID, _ := getContextUserID(ctx)
+	if payment.UserID != userID {
+		ctx.JSON(403, gin.H{"error": "not your payment"})
 		return
 	}
 
-	ctx.JSON(201, gin.H{
-		"message": "payment created successfully",
-		"payment": payment,
+	changed := false
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		payment, changed, err = applyPaymentStatus(tx, input.OrderID, models.PaymentSuccess, paymentUpdate{GatewayPaymentID: input.PaymentID})
+		return err
 	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to update payment", "details": err.Error()})
+		return
+	}
+	if changed {
+		go emailInvoice(payment.ID)
+	}
+	ctx.JSON(200, gin.H{"payment": payment})
 }
 
+// SimulatePayment → POST /payments/:id/simulate (owner/admin, fake gateway only; registered in PAYMENT_DEV_MODE)
+// Body {"outcome": "success"|"failed", "reason": "..."}; sends a signed webhook through the
+// normal processing path so development works without a real gateway.
+func SimulatePayment(ctx *gin.Context) {
+	gw, err := utils.Gateway()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "payment gateway is not configured", "details": err.Error()})
+		return
+	}
+	fake, ok := gw.(*utils.FakeGateway)
+	if !ok {
+		ctx.JSON(404, gin.H{"error": "simulation is only available with the fake gateway"})
+		return
+	}
 
+	payment, ok := loadOwnPayment(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		Outcome string `json:"outcome" binding:"required,oneof=success failed"`
+		Reason  string `json:"reason"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	if input.Outcome == "failed" && input.Reason == "" {
+		input.Reason = "simulated failure"
+	}
 
+	body, _, err := fake.Simulate(payment.TransactionID, utils.ToMinorUnits(payment.Amount), input.Outcome == "success", input.Reason)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to build webhook", "details": err.Error()})
+		return
+	}
+	result, err := processPaymentWebhook(fake, body, "")
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to process webhook", "details": err.Error()})
+		return
+	}
+
+	database.DB.Preload("Course").First(&payment, payment.ID)
+	ctx.JSON(200, gin.H{"status": result, "payment": payment})
+}
@@ -57,13 +57,22 @@ func UpdateProgress(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(200, gin.H{
+	response := gin.H{
 		"message":       "progress updated successfully",
 		"progress":      progress,
 		"total":         totalAssignments,
 		"completed":     completedAssignments,
 		"enrollment_id": enrollment.ID,
-	})
+	}
+
+	// Completing the course marks the enrollment and issues the certificate
+	outcome, err := runCompletion(enrollment.ID)
+	if err != nil {
+		response["completion_error"] = err.Error()
+	} else {
+		response["completion"] = outcome
+	}
+	ctx.JSON(200, response)
 }
 
 // GetProgress → GET /progress/:userId/:courseId
@@ -1,16 +1,25 @@
 package controllers
 
 import (
+	"strconv"
+
 	"github.com/ayushwar/major/database"
 	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // CreateQuestion → POST /assignments/:id/questions
 func CreateQuestion(ctx *gin.Context)  {
 	assignmentID:=ctx.Param("id")
 	var input struct{
-		Text string `json:"text" binding:"required"`
+		Text       string   `json:"text" binding:"required"`
+		Type       string   `json:"type"`
+		Points     int      `json:"points"`
+		Difficulty string   `json:"difficulty"`
+		Tags       []string `json:"tags"`
+		Feedback   string   `json:"feedback"`
 	}
 	if err:=ctx.ShouldBindBodyWithJSON(&input);err!=nil{
 		ctx.JSON(400,gin.H{"error":"invaild request"})
@@ -24,9 +33,32 @@ func CreateQuestion(ctx *gin.Context)  {
 		return
 	}
 
+	if input.Difficulty == "" {
+		input.Difficulty = models.DifficultyMedium
+	}
+	if !validDifficulties[input.Difficulty] {
+		ctx.JSON(400, gin.H{"error": "difficulty must be one of easy, medium, hard"})
+		return
+	}
+	if input.Type == "" {
+		input.Type = models.QuestionTypeMCQ
+	}
+	if !validQuestionTypes[input.Type] {
+		ctx.JSON(400, gin.H{"error": "type must be one of mcq, text"})
+		return
+	}
+	if input.Points <= 0 {
+		input.Points = 1
+	}
+
 	question := models.Question{
-		AssignmentID: assignment.ID,
+		AssignmentID: &assignment.ID,
+		Type:         input.Type,
+		Points:       input.Points,
 		Text:         input.Text,
+		Difficulty:   input.Difficulty,
+		Tags:         utils.JoinTags(input.Tags),
+		Feedback:     input.Feedback,
 	}
 
 	if err := database.DB.Create(&question).Error; err != nil {
@@ -40,29 +72,46 @@ func CreateQuestion(ctx *gin.Context)  {
 }
 // GetQuestionsByAssignment → GET /assignments/:id/questions
 func GetQuestionsByAssignment(ctx *gin.Context) {
-	assignmentID := ctx.Param("id")
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
 
 	var questions []models.Question
-	if err := database.DB.Where("assignment_id = ?", assignmentID).Find(&questions).Error; err != nil {
+	if err := database.DB.Where("assignment_id = ?", assignment.ID).Find(&questions).Error; err != nil {
 		ctx.JSON(500, gin.H{"error": "failed to fetch questions", "details": err.Error()})
 		return
 	}
 
+	if !canSeeAnswerKey(ctx, assignment) {
+		stripAnswerKey(questions)
+	}
+
 	ctx.JSON(200, gin.H{"questions": questions})
 }
 
-// UpdateQuestion → PUT /questions/:id
+// UpdateQuestion → PUT /questions/:question_id
+// Changing points regrades existing submissions; ?preview=true shows the impact without saving.
 func UpdateQuestion(ctx *gin.Context) {
-	id := ctx.Param("id")
+	id := ctx.Param("question_id")
 
-	var question models.Question
-	if err := database.DB.First(&question, id).Error; err != nil {
+	question, err := loadQuestionKey(id)
+	if err != nil {
 		ctx.JSON(400, gin.H{"error": "question not found"})
 		return
 	}
+	if !canManageQuestion(ctx, question) {
+		ctx.JSON(403, gin.H{"error": errQuestionForbidden})
+		return
+	}
 
 	var input struct {
-		Text string `json:"text"`
+		Text       string   `json:"text"`
+		Points     int      `json:"points"`
+		Difficulty string   `json:"difficulty"`
+		Tags       []string `json:"tags"`
+		Feedback   *string  `json:"feedback"`
 	}
 	if err := ctx.ShouldBindJSON(&input); err != nil {
 		ctx.JSON(404, gin.H{"error": "invalid request", "details": err.Error()})
@@ -72,18 +121,41 @@ func UpdateQuestion(ctx *gin.Context) {
 	if input.Text != "" {
 		question.Text = input.Text
 	}
+	keyChanged := input.Points > 0 && input.Points != questionPoints(question)
+	if input.Points > 0 {
+		question.Points = input.Points
+	}
+	if input.Difficulty != "" {
+		if !validDifficulties[input.Difficulty] {
+			ctx.JSON(400, gin.H{"error": "difficulty must be one of easy, medium, hard"})
+			return
+		}
+		question.Difficulty = input.Difficulty
+	}
+	if input.Tags != nil {
+		question.Tags = utils.JoinTags(input.Tags)
+	}
+	if input.Feedback != nil {
+		question.Feedback = *input.Feedback
+	}
+
+	if isPreview(ctx) {
+		respondRegradePreview(ctx, question)
+		return
+	}
 
-	if err := database.DB.Save(&question).Error; err != nil {
+	if err := database.DB.Omit("Options").Save(&question).Error; err != nil {
 		ctx.JSON(500, gin.H{"error": "failed to update question", "details": err.Error()})
 		return
 	}
 
-	ctx.JSON(200, gin.H{"message": "question updated successfully", "question": question})
+	respondKeyChanged(ctx, 200, keyChanged, gin.H{"message": "question updated successfully", "question": question})
 }
 
-// DeleteQuestion → DELETE /questions/:id
+// DeleteQuestion → DELETE /questions/:question_id
+// Refused once students have answered it: their answers, scores and disputes depend on it.
 func DeleteQuestion(ctx *gin.Context) {
-	id := ctx.Param("id")
+	id := ctx.Param("question_id")
 
 	var question models.Question
 	if err := database.DB.First(&question, id).Error; err != nil {
@@ -91,6 +163,16 @@ func DeleteQuestion(ctx *gin.Context) {
 		return
 	}
 
+	var answers int64
+	if err := database.DB.Model(&models.SubmissionAnswer{}).Where("question_id = ?", question.ID).Count(&answers).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to check answers", "details": err.Error()})
+		return
+	}
+	if answers > 0 {
+		ctx.JSON(409, gin.H{"error": "question has been answered and cannot be deleted", "answers": answers})
+		return
+	}
+
 	if err := database.DB.Delete(&question).Error; err != nil {
 		ctx.JSON(500, gin.H{"error": "failed to delete question", "details": err.Error()})
 		return
@@ -102,72 +184,195 @@ func DeleteQuestion(ctx *gin.Context) {
 // ------------------------------------------------------------------
 //							option controllers 
 // ------------------------------------------------------------------
-// CreateOption → add option to a question
+// CreateOption → POST /questions/:question_id/options
+// Adding a correct option changes the key, so existing submissions are regraded.
 func CreateOption(c *gin.Context) {
-	var option models.Option
-	if err := c.ShouldBindJSON(&option); err != nil {
+	var input struct {
+		Text      string `json:"text" binding:"required"`
+		IsCorrect bool   `json:"is_correct"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := database.DB.Create(&option).Error; err != nil {
+	question, err := loadQuestionKey(c.Param("question_id"))
+	if err != nil {
+		c.JSON(404, gin.H{"error": "question not found"})
+		return
+	}
+	if !canManageQuestion(c, question) {
+		c.JSON(403, gin.H{"error": errQuestionForbidden})
+		return
+	}
+	option := models.Option{QuestionID: question.ID, Text: input.Text, IsCorrect: input.IsCorrect}
+
+	if isPreview(c) {
+		option.ID = ^uint(0) // placeholder id that no stored answer can have selected
+		question.Options = append(question.Options, option)
+		respondRegradePreview(c, question)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&option).Error; err != nil {
+			return err
+		}
+		return syncCorrectOption(tx, question.ID)
+	})
+	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(201, option)
+	respondKeyChanged(c, 201, option.IsCorrect, gin.H{"option": option})
 }
 
 // GetOptions → get all options for a question
 func GetOptions(c *gin.Context) {
-	questionID := c.Param("question_id")
+	var question models.Question
+	if err := database.DB.First(&question, c.Param("question_id")).Error; err != nil {
+		c.JSON(404, gin.H{"error": "question not found"})
+		return
+	}
 	var options []models.Option
 
-	if err := database.DB.Where("question_id = ?", questionID).Find(&options).Error; err != nil {
+	if err := database.DB.Where("question_id = ?", question.ID).Find(&options).Error; err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
+	// Same people who may change the key
+	if !canManageQuestion(c, question) {
+		stripOptionKey(options)
+	}
+
 	c.JSON(200, options)
 }
 
-// UpdateOption → edit option (e.g. text or correctness)
+// UpdateOption → PUT /questions/:question_id/options/:option_id
+// Flipping is_correct regrades existing submissions; ?preview=true shows the impact without saving.
 func UpdateOption(c *gin.Context) {
-	id := c.Param("id")
 	var option models.Option
-
-	if err := database.DB.First(&option, id).Error; err != nil {
+	if err := database.DB.Where("question_id = ?", c.Param("question_id")).First(&option, c.Param("option_id")).Error; err != nil {
 		c.JSON(404, gin.H{"error": "Option not found"})
 		return
 	}
+	question, err := loadQuestionKey(option.QuestionID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "question not found"})
+		return
+	}
+	if !canManageQuestion(c, question) {
+		c.JSON(403, gin.H{"error": errQuestionForbidden})
+		return
+	}
 
-	if err := c.ShouldBindJSON(&option); err != nil {
+	var input struct {
+		Text      *string `json:"text"`
+		IsCorrect *bool   `json:"is_correct"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(400, gin.H{"error": err.Error()})
 		return
 	}
 
-	if err := database.DB.Save(&option).Error; err != nil {
+	keyChanged := input.IsCorrect != nil && *input.IsCorrect != option.IsCorrect
+	if input.Text != nil {
+		option.Text = *input.Text
+	}
+	if input.IsCorrect != nil {
+		option.IsCorrect = *input.IsCorrect
+	}
+
+	if isPreview(c) {
+		for i := range question.Options {
+			if question.Options[i].ID == option.ID {
+				question.Options[i] = option
+			}
+		}
+		respondRegradePreview(c, question)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&option).Updates(map[string]interface{}{"text": option.Text, "is_correct": option.IsCorrect}).Error; err != nil {
+			return err
+		}
+		return syncCorrectOption(tx, option.QuestionID)
+	})
+	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, option)
+	respondKeyChanged(c, 200, keyChanged, gin.H{"option": option})
 }
 
-// DeleteOption → delete an option
+// DeleteOption → DELETE /questions/:question_id/options/:option_id
+// Deleting a correct option changes the key, so existing submissions are regraded.
 func DeleteOption(c *gin.Context) {
-	id := c.Param("id")
 	var option models.Option
-
-	if err := database.DB.First(&option, id).Error; err != nil {
+	if err := database.DB.Where("question_id = ?", c.Param("question_id")).First(&option, c.Param("option_id")).Error; err != nil {
 		c.JSON(404, gin.H{"error": "Option not found"})
 		return
 	}
+	question, err := loadQuestionKey(option.QuestionID)
+	if err != nil {
+		c.JSON(404, gin.H{"error": "question not found"})
+		return
+	}
+	if !canManageQuestion(c, question) {
+		c.JSON(403, gin.H{"error": errQuestionForbidden})
+		return
+	}
 
-	if err := database.DB.Delete(&option).Error; err != nil {
+	if isPreview(c) {
+		kept := question.Options[:0]
+		for _, o := range question.Options {
+			if o.ID != option.ID {
+				kept = append(kept, o)
+			}
+		}
+		question.Options = kept
+		respondRegradePreview(c, question)
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&option).Error; err != nil {
+			return err
+		}
+		return syncCorrectOption(tx, option.QuestionID)
+	})
+	if err != nil {
 		c.JSON(500, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(200, gin.H{"message": "Option deleted successfully"})
-}
\ No newline at end of file
+	respondKeyChanged(c, 200, option.IsCorrect, gin.H{"message": "Option deleted successfully"})
+}
+
+// respondRegradePreview answers a ?preview=true edit with the would-be impact on submissions
+func respondRegradePreview(c *gin.Context, question models.Question) {
+	impact, err := computeRegrade(question)
+	if err != nil {
+		c.JSON(500, gin.H{"error": "failed to compute regrade", "details": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"preview": true, "impact": impact})
+}
+
+// respondKeyChanged starts a regrade job when the edit changed the key and adds it to the response
+func respondKeyChanged(c *gin.Context, status int, keyChanged bool, response gin.H) {
+	if keyChanged {
+		questionID, _ := strconv.ParseUint(c.Param("question_id"), 10, 64)
+		job, err := queueRegrade(c, uint(questionID))
+		if err != nil {
+			c.JSON(500, gin.H{"error": "saved, but failed to start regrade", "details": err.Error()})
+			return
+		}
+		response["regrade_job"] = job
+	}
+	c.JSON(status, response)
+}
@@ -1,20 +1,23 @@
 package controllers
 
 import (
-	
+	"fmt"
 	"time"
 
 	"github.com/ayushwar/major/database"
 	"github.com/ayushwar/major/models"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // SubmitAssignment → POST /submissions
 func SubmitAssignment(ctx *gin.Context) {
 	var req struct {
-		AssignmentID uint            `json:"assignment_id"`
-		UserID       uint            `json:"user_id"`
-		Answers      map[uint]uint   `json:"answers"` // QuestionID → SelectedOptionID
+		AssignmentID uint              `json:"assignment_id"`
+		UserID       uint              `json:"user_id"`
+		Answers      map[uint]uint     `json:"answers"`      // QuestionID → SelectedOptionID
+		Selections   map[uint][]uint   `json:"selections"`   // QuestionID → SelectedOptionIDs (multi-answer)
+		TextAnswers  map[uint]string   `json:"text_answers"` // QuestionID → free text
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -22,47 +25,195 @@ func SubmitAssignment(ctx *gin.Context) {
 		return
 	}
 
-	// Fetch assignment questions with options
-	var questions []models.Question
-	if err := database.DB.Preload("Options").Where("assignment_id = ?", req.AssignmentID).Find(&questions).Error; err != nil {
-		ctx.JSON(500, gin.H{"error": "failed to fetch questions"})
+	// Students always submit as themselves
+	if getUserRole(ctx) == "student" {
+		req.UserID, _ = getContextUserID(ctx)
+	}
+
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, req.AssignmentID).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if assignment.DueDate != nil && time.Now().After(*assignment.DueDate) {
+		ctx.JSON(400, gin.H{"error": "the due date for this assignment has passed"})
 		return
 	}
 
-	// Calculate score
-	score := 0
-	for _, q := range questions {
-		correctOption := uint(0)
-		for _, opt := range q.Options {
-			if opt.IsCorrect {
-				correctOption = opt.ID
-				break
-			}
+	// Once answers are released after submission, a second attempt would be answering with the key in hand
+	if assignment.ReviewPolicy == models.ReviewAfterSubmission {
+		var previous int64
+		database.DB.Model(&models.Submission{}).
+			Where("assignment_id = ? AND user_id = ?", req.AssignmentID, req.UserID).Count(&previous)
+		if previous > 0 {
+			ctx.JSON(400, gin.H{"error": "assignment already submitted and answers have been released"})
+			return
 		}
+	}
 
-		if req.Answers[q.ID] == correctOption {
-			score++
-		}
+	// Fetch the questions this student was given (fixed questions or their drawn paper)
+	questions, attempt, err := submissionQuestions(req.AssignmentID, req.UserID)
+	if err == errAttemptNotStarted {
+		ctx.JSON(400, gin.H{"error": "start the assignment before submitting"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch questions"})
+		return
 	}
 
-	// Save submission
+	// Grade every question the student was given, answered or not
 	submission := models.Submission{
 		AssignmentID: req.AssignmentID,
 		UserID:       req.UserID,
-		Score:        score,
 		SubmittedAt:  time.Now(),
 	}
+	if attempt != nil {
+		submission.AttemptID = &attempt.ID
+	}
+	for _, q := range questions {
+		selected := req.Selections[q.ID]
+		if selected == nil && req.Answers[q.ID] != 0 {
+			selected = []uint{req.Answers[q.ID]}
+		}
+		answer := gradeAnswer(q, selected, req.TextAnswers[q.ID])
 
+		submission.Answers = append(submission.Answers, answer)
+		submission.Score += answer.PointsAwarded
+		submission.MaxScore += answer.MaxPoints
+		submission.NeedsGrading = submission.NeedsGrading || answer.NeedsGrading
+	}
+	// The assignment rubric is graded by hand, so the score stays provisional until then
+	if assignment.RubricID != nil {
+		submission.NeedsGrading = true
+	}
+
+	// Save submission and its answers together
 	if err := database.DB.Create(&submission).Error; err != nil {
 		ctx.JSON(500, gin.H{"error": "failed to save submission"})
 		return
 	}
 
-	ctx.JSON(200, gin.H{
+	// Free-text answers are compared with classmates' in the background
+	queueSimilarityCheck(submission)
+
+	// Answers are only echoed back once the review policy allows it
+	response := gin.H{
 		"message":    "submission saved successfully",
-		"score":      score,
+		"score":      submission.Score,
+		"max_score":  submission.MaxScore,
 		"submission": submission,
+	}
+	if !canSeeAnswerKey(ctx, assignment) && !reviewOpen(assignment, true, time.Now()) {
+		submission.Answers = nil
+		response["submission"] = submission
+	}
+	ctx.JSON(200, response)
+}
+
+// GetSubmissionByID → GET /submissions/:id
+// Full per-question breakdown. Teachers of the course and admins always see it;
+// the student who submitted sees it once the assignment's review policy allows.
+func GetSubmissionByID(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+
+	var submission models.Submission
+	if err := database.DB.Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Preload("Answers.Question.Options").Preload("RubricAssessments.Scores.Criterion").
+		Preload("RubricAssessments.Scores.Level").First(&submission, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "submission not found"})
+		return
+	}
+
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, submission.AssignmentID).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+
+	if canGradeAssignment(ctx, assignment) {
+		ctx.JSON(200, gin.H{"submission": submission})
+		return
+	}
+	if submission.UserID != userID {
+		ctx.JSON(403, gin.H{"error": "you can only view your own submissions"})
+		return
+	}
+
+	if !reviewOpen(assignment, true, time.Now()) {
+		// Score only until the review opens
+		submission.Answers = nil
+		submission.RubricAssessments = nil
+		ctx.JSON(200, gin.H{
+			"submission":       submission,
+			"review_available": false,
+			"review_policy":    assignment.ReviewPolicy,
+		})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"submission": submission, "review_available": true})
+}
+
+// OverrideAnswerScore → PUT /submissions/:id/answers/:answer_id
+// Lets the course teacher set an answer's points by hand; the reason is kept for disputes.
+func OverrideAnswerScore(ctx *gin.Context) {
+	var input struct {
+		Points *int   `json:"points" binding:"required"`
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request, points and reason are required", "details": err.Error()})
+		return
+	}
+
+	var answer models.SubmissionAnswer
+	if err := database.DB.Where("submission_id = ?", ctx.Param("id")).
+		First(&answer, ctx.Param("answer_id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "answer not found"})
+		return
+	}
+
+	var submission models.Submission
+	if err := database.DB.Preload("Assignment").First(&submission, answer.SubmissionID).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "submission not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, submission.Assignment) {
+		ctx.JSON(403, gin.H{"error": "only the course teacher or an admin can override scores"})
+		return
+	}
+
+	if *input.Points < 0 || *input.Points > answer.MaxPoints {
+		ctx.JSON(400, gin.H{"error": fmt.Sprintf("points must be between 0 and %d", answer.MaxPoints)})
+		return
+	}
+
+	graderID, _ := getContextUserID(ctx)
+	now := time.Now()
+	answer.PointsAwarded = *input.Points
+	answer.GradingMode = models.GradingManual
+	answer.NeedsGrading = false
+	answer.OverrideReason = input.Reason
+	answer.OverriddenBy = &graderID
+	answer.OverriddenAt = &now
+
+	var updated models.Submission
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&answer).Error; err != nil {
+			return err
+		}
+		var err error
+		updated, err = recalculateSubmission(tx, submission.ID)
+		return err
 	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to override score", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"message": "answer score updated", "answer": answer, "submission": updated})
 }
 
 // GetSubmissionsByUser → GET /submissions/user/:id
@@ -78,12 +229,20 @@ func GetSubmissionsByUser(ctx *gin.Context) {
 	ctx.JSON(200, gin.H{"submissions": submissions})
 }
 
-// GetSubmissionsByAssignment → GET /submissions/assignment/:id
+// GetSubmissionsByAssignment → GET /submissions/assignment/:id (course teacher/admin)
 func GetSubmissionsByAssignment(ctx *gin.Context) {
-	assignmentID := ctx.Param("id")
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only list submissions of your own assignments"})
+		return
+	}
 	var submissions []models.Submission
 
-	if err := database.DB.Where("assignment_id = ?", assignmentID).Find(&submissions).Error; err != nil {
+	if err := database.DB.Where("assignment_id = ?", assignment.ID).Find(&submissions).Error; err != nil {
 		ctx.JSON(500, gin.H{"error": "failed to fetch submissions"})
 		return
 	}
@@ -0,0 +1,333 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// Share of students in each of the upper / lower groups for the discrimination index
+	discriminationGroup = 0.27
+	// Fewer students than this make upper/lower groups meaningless
+	minStudentsForDiscrimination = 4
+	// Distractors picked by fewer than this share of students are not doing their job
+	nonFunctioningShare = 0.05
+)
+
+// Distractor flags
+const (
+	flagNonFunctioning = "non_functioning" // almost nobody picks this wrong option
+	flagMisleading     = "misleading"      // strong students pick this wrong option more than weak ones
+	flagNegativeKey    = "negative_key"    // weak students pick the correct option more than strong ones
+)
+
+type assignmentAnalytics struct {
+	AssignmentID   uint                 `json:"assignment_id"`
+	Submissions    int                  `json:"submissions"`
+	Students       int                  `json:"students"` // only each student's latest submission is analysed
+	PendingGrading int                  `json:"pending_grading"`
+	Score          scoreSummary         `json:"score"`
+	Histogram      []utils.HistogramBin `json:"histogram"` // percentage scores in 10 bins
+	Questions      []questionAnalysis   `json:"questions"`
+	Reliability    reliabilityEstimate  `json:"reliability"`
+	ComputedAt     time.Time            `json:"computed_at"`
+}
+
+// scoreSummary is in percent so papers with different max scores compare
+type scoreSummary struct {
+	Mean   float64 `json:"mean"`
+	Median float64 `json:"median"`
+	StdDev float64 `json:"std_dev"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+type questionAnalysis struct {
+	QuestionID uint   `json:"question_id"`
+	Text       string `json:"question_text"`
+	Type       string `json:"type"`
+	Responses  int    `json:"responses"`
+
+	// Share of available points earned (0..1); higher means easier
+	DifficultyIndex float64 `json:"difficulty_index"`
+	// Difficulty in the upper group minus difficulty in the lower group (-1..1); nil when not computable
+	DiscriminationIndex *float64 `json:"discrimination_index"`
+
+	Options []optionAnalysis `json:"options,omitempty"`
+}
+
+type optionAnalysis struct {
+	OptionID  uint    `json:"option_id"`
+	Text      string  `json:"text"`
+	IsCorrect bool    `json:"is_correct"`
+	Count     int     `json:"count"`
+	Share     float64 `json:"share"`
+	Upper     int     `json:"upper_count"`
+	Lower     int     `json:"lower_count"`
+	Flag      string  `json:"flag,omitempty"`
+}
+
+// reliabilityEstimate → Cronbach's alpha over the questions every analysed student answered
+type reliabilityEstimate struct {
+	CronbachAlpha *float64 `json:"cronbach_alpha"`
+	Items         int      `json:"items"`
+	Respondents   int      `json:"respondents"`
+	Note          string   `json:"note,omitempty"`
+}
+
+// GetAssignmentAnalytics → GET /assignments/:id/analytics
+// Results are cached and recomputed only when submissions were added or scores changed.
+func GetAssignmentAnalytics(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only view analytics of your own assignments"})
+		return
+	}
+
+	var fp struct {
+		Count int64
+		MaxID uint
+	}
+	if err := database.DB.Model(&models.Submission{}).
+		Select("COUNT(*) AS count, COALESCE(MAX(id), 0) AS max_id").
+		Where("assignment_id = ?", assignment.ID).Scan(&fp).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch submissions", "details": err.Error()})
+		return
+	}
+	fingerprint := fmt.Sprintf("%d:%d", fp.Count, fp.MaxID)
+
+	var cache models.AssignmentStats
+	if err := database.DB.Where("assignment_id = ?", assignment.ID).First(&cache).Error; err == nil && cache.Fingerprint == fingerprint {
+		var analytics assignmentAnalytics
+		if json.Unmarshal([]byte(cache.Data), &analytics) == nil {
+			ctx.JSON(200, gin.H{"analytics": analytics, "cached": true})
+			return
+		}
+	}
+
+	analytics, err := computeAnalytics(assignment.ID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to compute analytics", "details": err.Error()})
+		return
+	}
+
+	if data, err := json.Marshal(analytics); err == nil {
+		cache.AssignmentID = assignment.ID
+		cache.Fingerprint = fingerprint
+		cache.Data = string(data)
+		cache.ComputedAt = analytics.ComputedAt
+		if err := database.DB.Save(&cache).Error; err != nil {
+			fmt.Println("ERROR: failed to cache analytics for assignment", assignment.ID, ":", err)
+		}
+	}
+
+	ctx.JSON(200, gin.H{"analytics": analytics, "cached": false})
+}
+
+// computeAnalytics builds the item analysis from stored per-answer data
+func computeAnalytics(assignmentID uint) (assignmentAnalytics, error) {
+	analytics := assignmentAnalytics{
+		AssignmentID: assignmentID,
+		Questions:    []questionAnalysis{},
+		ComputedAt:   time.Now(),
+	}
+
+	latest, total, err := latestSubmissions(assignmentID, true)
+	if err != nil {
+		return analytics, err
+	}
+	analytics.Submissions = total
+	analytics.Students = len(latest)
+	if len(latest) == 0 {
+		analytics.Reliability.Note = "no submissions yet"
+		return analytics, nil
+	}
+
+	percent := func(s models.Submission) float64 {
+		if s.MaxScore <= 0 {
+			return 0
+		}
+		return float64(s.Score) / float64(s.MaxScore) * 100
+	}
+	// Best first, so the upper/lower groups are the two ends of the slice
+	sort.SliceStable(latest, func(i, j int) bool { return percent(latest[i]) > percent(latest[j]) })
+
+	scores := make([]float64, len(latest))
+	for i, s := range latest {
+		scores[i] = percent(s)
+	}
+	analytics.Score = scoreSummary{
+		Mean:   utils.Mean(scores),
+		Median: utils.Median(scores),
+		StdDev: utils.StdDev(scores),
+		Min:    scores[len(scores)-1],
+		Max:    scores[0],
+	}
+	analytics.Histogram = utils.Histogram(scores, 0, 100, 10)
+
+	upper, lower := map[uint]bool{}, map[uint]bool{}
+	if len(latest) >= minStudentsForDiscrimination {
+		g := int(math.Round(discriminationGroup * float64(len(latest))))
+		for i := 0; i < g; i++ {
+			upper[latest[i].ID] = true
+			lower[latest[len(latest)-1-i].ID] = true
+		}
+	}
+
+	// Group the answers by question
+	byQuestion := map[uint][]models.SubmissionAnswer{}
+	for _, s := range latest {
+		for _, a := range s.Answers {
+			byQuestion[a.QuestionID] = append(byQuestion[a.QuestionID], a)
+			if a.NeedsGrading {
+				analytics.PendingGrading++
+			}
+		}
+	}
+	questionIDs := make([]uint, 0, len(byQuestion))
+	for id := range byQuestion {
+		questionIDs = append(questionIDs, id)
+	}
+	sort.Slice(questionIDs, func(i, j int) bool { return questionIDs[i] < questionIDs[j] })
+
+	var questions []models.Question
+	if len(questionIDs) > 0 {
+		if err := database.DB.Preload("Options").Where("id IN ?", questionIDs).Find(&questions).Error; err != nil {
+			return analytics, err
+		}
+	}
+	questionByID := make(map[uint]models.Question, len(questions))
+	for _, q := range questions {
+		questionByID[q.ID] = q
+	}
+
+	for _, id := range questionIDs {
+		analytics.Questions = append(analytics.Questions, analyseQuestion(questionByID[id], id, byQuestion[id], upper, lower))
+	}
+
+	analytics.Reliability = estimateReliability(latest, questionIDs)
+	return analytics, nil
+}
+
+// analyseQuestion computes difficulty, discrimination and distractor data for one question.
+// upper / lower hold submission IDs of the strongest and weakest groups.
+func analyseQuestion(q models.Question, id uint, answers []models.SubmissionAnswer, upper, lower map[uint]bool) questionAnalysis {
+	qa := questionAnalysis{QuestionID: id, Text: q.Text, Type: q.Type, Responses: len(answers)}
+
+	share := func(in func(models.SubmissionAnswer) bool) (float64, bool) {
+		earned, max := 0, 0
+		for _, a := range answers {
+			if in(a) {
+				earned += a.PointsAwarded
+				max += a.MaxPoints
+			}
+		}
+		if max == 0 {
+			return 0, false
+		}
+		return float64(earned) / float64(max), true
+	}
+
+	qa.DifficultyIndex, _ = share(func(models.SubmissionAnswer) bool { return true })
+	pUpper, okUpper := share(func(a models.SubmissionAnswer) bool { return upper[a.SubmissionID] })
+	pLower, okLower := share(func(a models.SubmissionAnswer) bool { return lower[a.SubmissionID] })
+	if okUpper && okLower {
+		d := pUpper - pLower
+		qa.DiscriminationIndex = &d
+	}
+
+	if q.Type == models.QuestionTypeText || len(q.Options) == 0 {
+		return qa
+	}
+
+	for _, opt := range q.Options {
+		oa := optionAnalysis{OptionID: opt.ID, Text: opt.Text, IsCorrect: opt.IsCorrect}
+		for _, a := range answers {
+			for _, sel := range a.SelectedOptionIDs {
+				if sel != opt.ID {
+					continue
+				}
+				oa.Count++
+				if upper[a.SubmissionID] {
+					oa.Upper++
+				}
+				if lower[a.SubmissionID] {
+					oa.Lower++
+				}
+			}
+		}
+		if len(answers) > 0 {
+			oa.Share = float64(oa.Count) / float64(len(answers))
+		}
+
+		switch {
+		case opt.IsCorrect && oa.Lower > oa.Upper:
+			oa.Flag = flagNegativeKey
+		case !opt.IsCorrect && oa.Share < nonFunctioningShare:
+			oa.Flag = flagNonFunctioning
+		case !opt.IsCorrect && oa.Upper > oa.Lower:
+			oa.Flag = flagMisleading
+		}
+		qa.Options = append(qa.Options, oa)
+	}
+	return qa
+}
+
+// estimateReliability runs Cronbach's alpha over the questions answered in every analysed submission.
+// Randomised papers only share some questions, so the item set can be smaller than the paper.
+func estimateReliability(submissions []models.Submission, questionIDs []uint) reliabilityEstimate {
+	points := make([]map[uint]float64, len(submissions))
+	for i, s := range submissions {
+		points[i] = map[uint]float64{}
+		for _, a := range s.Answers {
+			points[i][a.QuestionID] = float64(a.PointsAwarded)
+		}
+	}
+
+	var common []uint
+	for _, id := range questionIDs {
+		everyone := true
+		for _, p := range points {
+			if _, ok := p[id]; !ok {
+				everyone = false
+				break
+			}
+		}
+		if everyone {
+			common = append(common, id)
+		}
+	}
+
+	est := reliabilityEstimate{Items: len(common), Respondents: len(submissions)}
+	matrix := make([][]float64, len(submissions))
+	for i, p := range points {
+		for _, id := range common {
+			matrix[i] = append(matrix[i], p[id])
+		}
+	}
+
+	alpha, ok := utils.CronbachAlpha(matrix)
+	switch {
+	case ok:
+		est.CronbachAlpha = &alpha
+	case len(common) < 2:
+		est.Note = "fewer than 2 questions were answered by every student"
+	case len(submissions) < 2:
+		est.Note = "at least 2 students are needed"
+	default:
+		est.Note = "all students have the same total score"
+	}
+	return est
+}
@@ -0,0 +1,366 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// errAttemptNotStarted is returned when a rule-based assignment is submitted without a drawn paper
+var errAttemptNotStarted = errors.New("assignment attempt not started")
+
+// CreateAssignmentRule → POST /assignments/:id/rules
+func CreateAssignmentRule(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.Preload("Course").First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only add rules to your own assignments"})
+		return
+	}
+
+	var input struct {
+		BankID     uint   `json:"bank_id" binding:"required"`
+		Count      int    `json:"count" binding:"required,min=1"`
+		Difficulty string `json:"difficulty"`
+		Tag        string `json:"tag"`
+		OrderIndex int    `json:"order_index"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	rule := models.AssignmentRule{
+		AssignmentID: assignment.ID,
+		BankID:       input.BankID,
+		Count:        input.Count,
+		Difficulty:   input.Difficulty,
+		Tag:          input.Tag,
+		OrderIndex:   input.OrderIndex,
+	}
+
+	if rule.Difficulty != "" && !validDifficulties[rule.Difficulty] {
+		ctx.JSON(400, gin.H{"error": "difficulty must be one of easy, medium, hard"})
+		return
+	}
+
+	// Bank must belong to the assignment's course or to that course's department
+	var bank models.QuestionBank
+	if err := database.DB.First(&bank, rule.BankID).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "question bank not found"})
+		return
+	}
+	sameCourse := bank.CourseID != nil && *bank.CourseID == assignment.CourseID
+	sameDept := bank.DepartmentID != nil && *bank.DepartmentID == assignment.Course.DepartmentID
+	if !sameCourse && !sameDept {
+		ctx.JSON(400, gin.H{"error": "question bank does not belong to this assignment's course or department"})
+		return
+	}
+
+	// Make sure the bank can actually satisfy the rule
+	candidates, err := ruleCandidates(rule)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to check bank questions", "details": err.Error()})
+		return
+	}
+	if len(candidates) < rule.Count {
+		ctx.JSON(400, gin.H{
+			"error":   "not enough matching questions in bank",
+			"details": fmt.Sprintf("rule needs %d questions, bank has %d matching", rule.Count, len(candidates)),
+		})
+		return
+	}
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to create rule", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(201, gin.H{"message": "rule added successfully", "rule": rule})
+}
+
+// GetAssignmentRules → GET /assignments/:id/rules
+func GetAssignmentRules(ctx *gin.Context) {
+	var rules []models.AssignmentRule
+	if err := database.DB.Where("assignment_id = ?", ctx.Param("id")).
+		Order("order_index, id").Find(&rules).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch rules", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"rules": rules})
+}
+
+// DeleteAssignmentRule → DELETE /assignments/:id/rules/:rule_id
+func DeleteAssignmentRule(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only remove rules from your own assignments"})
+		return
+	}
+
+	var rule models.AssignmentRule
+	if err := database.DB.Where("assignment_id = ?", ctx.Param("id")).
+		First(&rule, ctx.Param("rule_id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "rule not found"})
+		return
+	}
+
+	if err := database.DB.Delete(&rule).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to delete rule", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"message": "rule deleted successfully"})
+}
+
+// StartAttempt → POST /assignments/:id/attempt
+// Draws (or re-opens) the logged-in student's paper and returns it without the answer key.
+// A new paper is drawn only for students actively enrolled in the assignment's course.
+func StartAttempt(ctx *gin.Context) {
+	userID, ok := getContextUserID(ctx)
+	if !ok {
+		ctx.JSON(401, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+
+	var attempt models.AssignmentAttempt
+	err := database.DB.Where("assignment_id = ? AND user_id = ?", assignment.ID, userID).First(&attempt).Error
+	if err != nil {
+		var enrolled int64
+		if err := database.DB.Model(&models.Enrollment{}).
+			Where("user_id = ? AND course_id = ? AND status = ?", userID, assignment.CourseID, models.EnrollmentActive).
+			Count(&enrolled).Error; err != nil {
+			ctx.JSON(500, gin.H{"error": "failed to check enrollment", "details": err.Error()})
+			return
+		}
+		if enrolled == 0 {
+			ctx.JSON(403, gin.H{"error": "you are not enrolled in this course"})
+			return
+		}
+
+		attempt, err = drawAttempt(assignment, userID)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "failed to generate paper", "details": err.Error()})
+			return
+		}
+		if err := database.DB.Create(&attempt).Error; err != nil {
+			ctx.JSON(500, gin.H{"error": "failed to save attempt", "details": err.Error()})
+			return
+		}
+	}
+
+	questions, err := attemptQuestions(attempt)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to load paper", "details": err.Error()})
+		return
+	}
+
+	// The paper never carries the answer key
+	stripAnswerKey(questions)
+
+	ctx.JSON(200, gin.H{"attempt": attempt, "questions": questions})
+}
+
+// ruleCandidates → bank questions matching a rule's difficulty and tag, ordered by ID
+func ruleCandidates(rule models.AssignmentRule) ([]uint, error) {
+	query := database.DB.Model(&models.Question{}).Where("bank_id = ?", rule.BankID)
+	if rule.Difficulty != "" {
+		query = query.Where("difficulty = ?", rule.Difficulty)
+	}
+
+	var questions []models.Question
+	if err := query.Select("id", "tags").Order("id").Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	var ids []uint
+	for _, q := range questions {
+		if rule.Tag == "" || utils.HasTag(q.Tags, rule.Tag) {
+			ids = append(ids, q.ID)
+		}
+	}
+	return ids, nil
+}
+
+// drawAttempt builds a student's paper: the assignment's fixed questions followed by
+// random draws for every rule, optionally shuffled. The seed is derived from the
+// assignment and user so the draw is reproducible.
+func drawAttempt(assignment models.Assignment, userID uint) (models.AssignmentAttempt, error) {
+	seed := utils.AttemptSeed(assignment.ID, userID)
+	rng := rand.New(rand.NewSource(seed))
+
+	var questionIDs []uint
+	if err := database.DB.Model(&models.Question{}).Where("assignment_id = ?", assignment.ID).
+		Order("id").Pluck("id", &questionIDs).Error; err != nil {
+		return models.AssignmentAttempt{}, err
+	}
+
+	var rules []models.AssignmentRule
+	if err := database.DB.Where("assignment_id = ?", assignment.ID).
+		Order("order_index, id").Find(&rules).Error; err != nil {
+		return models.AssignmentAttempt{}, err
+	}
+
+	chosen := make(map[uint]bool)
+	for _, id := range questionIDs {
+		chosen[id] = true
+	}
+	for _, rule := range rules {
+		candidates, err := ruleCandidates(rule)
+		if err != nil {
+			return models.AssignmentAttempt{}, err
+		}
+		// A question already drawn by an earlier rule can't be drawn twice
+		available := candidates[:0]
+		for _, id := range candidates {
+			if !chosen[id] {
+				available = append(available, id)
+			}
+		}
+		if len(available) < rule.Count {
+			return models.AssignmentAttempt{}, fmt.Errorf("rule %d needs %d questions, only %d available", rule.ID, rule.Count, len(available))
+		}
+		utils.ShuffleIDs(rng, available)
+		for _, id := range available[:rule.Count] {
+			chosen[id] = true
+			questionIDs = append(questionIDs, id)
+		}
+	}
+
+	if len(questionIDs) == 0 {
+		return models.AssignmentAttempt{}, errors.New("assignment has no questions")
+	}
+	if assignment.ShuffleQuestions {
+		utils.ShuffleIDs(rng, questionIDs)
+	}
+
+	var options []models.Option
+	if err := database.DB.Where("question_id IN ?", questionIDs).Order("id").Find(&options).Error; err != nil {
+		return models.AssignmentAttempt{}, err
+	}
+	optionOrder := make(map[uint][]uint)
+	for _, o := range options {
+		optionOrder[o.QuestionID] = append(optionOrder[o.QuestionID], o.ID)
+	}
+	if assignment.ShuffleOptions {
+		// Walk questions in paper order so the rng sequence is stable
+		for _, qid := range questionIDs {
+			utils.ShuffleIDs(rng, optionOrder[qid])
+		}
+	}
+
+	return models.AssignmentAttempt{
+		AssignmentID: assignment.ID,
+		UserID:       userID,
+		Seed:         seed,
+		QuestionIDs:  questionIDs,
+		OptionOrder:  optionOrder,
+		StartedAt:    time.Now(),
+	}, nil
+}
+
+// attemptQuestions loads an attempt's questions (with options) in the stored display order.
+// Options added after the draw are appended at the end.
+func attemptQuestions(attempt models.AssignmentAttempt) ([]models.Question, error) {
+	var questions []models.Question
+	if err := database.DB.Preload("Options").Where("id IN ?", attempt.QuestionIDs).Find(&questions).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Question, len(questions))
+	for _, q := range questions {
+		position := make(map[uint]int)
+		for i, id := range attempt.OptionOrder[q.ID] {
+			position[id] = i
+		}
+		sort.SliceStable(q.Options, func(i, j int) bool {
+			pi, iok := position[q.Options[i].ID]
+			pj, jok := position[q.Options[j].ID]
+			if iok != jok {
+				return iok
+			}
+			return pi < pj
+		})
+		byID[q.ID] = q
+	}
+
+	ordered := make([]models.Question, 0, len(attempt.QuestionIDs))
+	for _, id := range attempt.QuestionIDs {
+		if q, ok := byID[id]; ok {
+			ordered = append(ordered, q)
+		}
+	}
+	return ordered, nil
+}
+
+// submissionQuestions → the questions a student's submission is graded against.
+// Rule-based or shuffled assignments use the stored attempt; plain assignments use their fixed questions.
+func submissionQuestions(assignmentID, userID uint) ([]models.Question, *models.AssignmentAttempt, error) {
+	var attempt models.AssignmentAttempt
+	if err := database.DB.Where("assignment_id = ? AND user_id = ?", assignmentID, userID).First(&attempt).Error; err == nil {
+		questions, err := attemptQuestions(attempt)
+		return questions, &attempt, err
+	}
+
+	var ruleCount int64
+	if err := database.DB.Model(&models.AssignmentRule{}).Where("assignment_id = ?", assignmentID).Count(&ruleCount).Error; err != nil {
+		return nil, nil, err
+	}
+	if ruleCount > 0 {
+		return nil, nil, errAttemptNotStarted
+	}
+
+	var questions []models.Question
+	if err := database.DB.Preload("Options").Where("assignment_id = ?", assignmentID).Find(&questions).Error; err != nil {
+		return nil, nil, err
+	}
+	return questions, nil, nil
+}
+
+// assignmentQuestionPool → every question an assignment can put on a paper: its own questions
+// and all bank questions its rules can draw from, by ID
+func assignmentQuestionPool(assignmentID uint) ([]models.Question, error) {
+	var ids []uint
+	if err := database.DB.Model(&models.Question{}).Where("assignment_id = ?", assignmentID).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	var rules []models.AssignmentRule
+	if err := database.DB.Where("assignment_id = ?", assignmentID).Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	for _, rule := range rules {
+		candidates, err := ruleCandidates(rule)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, candidates...)
+	}
+
+	questions := []models.Question{}
+	if len(ids) == 0 {
+		return questions, nil
+	}
+	err := database.DB.Preload("Options").Where("id IN ?", ids).Order("id").Find(&questions).Error
+	return questions, err
+}
@@ -15,6 +15,19 @@ func CreateAssignment(ctx *gin.Context) {
 		return
 	}
 
+	if assignment.ReviewPolicy == "" {
+		assignment.ReviewPolicy = models.ReviewNever
+	}
+	if !validReviewPolicies[assignment.ReviewPolicy] {
+		ctx.JSON(400, gin.H{"error": "review_policy must be one of never, after_submission, after_due_date"})
+		return
+	}
+
+	if !validCategory(assignment.CourseID, assignment.CategoryID) {
+		ctx.JSON(400, gin.H{"error": "category_id must be a grade category of the assignment's course"})
+		return
+	}
+
 	if err := database.DB.Create(&assignment).Error; err != nil {
 		ctx.JSON(500, gin.H{"error": "Failed to create assignment", "details": err.Error()})
 		return
@@ -31,6 +44,13 @@ func GetAllAssignments(ctx *gin.Context) {
 		return
 	}
 
+	// Only the course teacher and admins see an assignment's answer key
+	for i := range assignments {
+		if !canSeeAnswerKey(ctx, assignments[i]) {
+			stripAnswerKey(assignments[i].Questions)
+		}
+	}
+
 	ctx.JSON(200, gin.H{"assignments": assignments})
 }
 
@@ -43,6 +63,10 @@ func GetAssignmentByID(ctx *gin.Context) {
 		return
 	}
 
+	if !canSeeAnswerKey(ctx, assignment) {
+		stripAnswerKey(assignment.Questions)
+	}
+
 	ctx.JSON(200, gin.H{"assignment": assignment})
 }
 
@@ -65,6 +89,22 @@ func UpdateAssignment(ctx *gin.Context) {
 	assignment.Title = input.Title
 	assignment.Description = input.Description
 	assignment.CourseID = input.CourseID
+	assignment.ShuffleQuestions = input.ShuffleQuestions
+	assignment.ShuffleOptions = input.ShuffleOptions
+	assignment.DueDate = input.DueDate
+	assignment.Required = input.Required
+	if !validCategory(assignment.CourseID, input.CategoryID) {
+		ctx.JSON(400, gin.H{"error": "category_id must be a grade category of the assignment's course"})
+		return
+	}
+	assignment.CategoryID = input.CategoryID
+	if input.ReviewPolicy != "" {
+		if !validReviewPolicies[input.ReviewPolicy] {
+			ctx.JSON(400, gin.H{"error": "review_policy must be one of never, after_submission, after_due_date"})
+			return
+		}
+		assignment.ReviewPolicy = input.ReviewPolicy
+	}
 
 	if err := database.DB.Save(&assignment).Error; err != nil {
 		ctx.JSON(500, gin.H{"error": "Failed to update assignment", "details": err.Error()})
@@ -0,0 +1,279 @@
+package controllers
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	jobTypeBulkCertificates = "bulk_certificates"
+
+	// ZIP archives produced by jobs
+	exportDir = "data/exports"
+)
+
+// What a bulk run does for one enrollment
+const (
+	bulkIssue         = "issue"
+	bulkAlreadyIssued = "already_issued"
+	bulkSkip          = "skip"
+)
+
+// bulkCandidate is one enrollment in a bulk issuance and why it does or does not get a certificate
+type bulkCandidate struct {
+	EnrollmentID uint     `json:"enrollment_id"`
+	UserID       uint     `json:"user_id"`
+	Name         string   `json:"name"`
+	Email        string   `json:"email"`
+	Eligible     bool     `json:"eligible"`
+	Action       string   `json:"action"`
+	Reasons      []string `json:"reasons,omitempty"`
+	Certificate  string   `json:"certificate_code,omitempty"` // the live certificate, if any
+}
+
+// bulkCandidates runs the completion rules for every enrollment in a course.
+// A student with a live certificate keeps it; one whose certificate was revoked is
+// skipped, since that needs an individual reissue.
+func bulkCandidates(courseID uint) ([]bulkCandidate, models.CompletionRule, error) {
+	rule, err := courseCompletionRule(courseID)
+	if err != nil {
+		return nil, rule, err
+	}
+
+	var enrollments []models.Enrollment
+	if err := database.DB.Preload("User").Where("course_id = ?", courseID).Order("id").Find(&enrollments).Error; err != nil {
+		return nil, rule, err
+	}
+
+	var finalGrades map[uint]*float64
+	if rule.MinFinalGrade != nil {
+		if finalGrades, err = courseFinalGrades(courseID, nil); err != nil {
+			return nil, rule, err
+		}
+	}
+
+	var certs []models.Certificate
+	if err := database.DB.Where("course_id = ?", courseID).Find(&certs).Error; err != nil {
+		return nil, rule, err
+	}
+	live, everIssued := map[uint]string{}, map[uint]bool{}
+	for _, c := range certs {
+		everIssued[c.UserID] = true
+		if certificateStatus(c) == certificateValid {
+			live[c.UserID] = c.CertCode
+		}
+	}
+
+	candidates := make([]bulkCandidate, 0, len(enrollments))
+	for _, e := range enrollments {
+		c := bulkCandidate{EnrollmentID: e.ID, UserID: e.UserID, Action: bulkSkip}
+		if e.User != nil {
+			c.Name, c.Email = e.User.Name, e.User.Email
+		}
+
+		checks, met, err := evaluateCompletion(rule, e, finalGrades)
+		if err != nil {
+			return nil, rule, err
+		}
+		switch {
+		case live[e.UserID] != "":
+			c.Eligible, c.Action, c.Certificate = true, bulkAlreadyIssued, live[e.UserID]
+		case e.Status == models.EnrollmentSuspended:
+			c.Reasons = []string{"enrollment is suspended after a refund"}
+		case !met:
+			for _, check := range checks {
+				if !check.Met {
+					c.Reasons = append(c.Reasons, fmt.Sprintf("%s: %s (needs %s)", check.Rule, check.Actual, check.Required))
+				}
+			}
+		case everIssued[e.UserID]:
+			c.Reasons = []string{"certificate was revoked; reissue it individually"}
+		default:
+			c.Eligible, c.Action = true, bulkIssue
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rule, nil
+}
+
+// BulkIssueCertificates → POST /courses/:id/certificates/bulk?dry_run=true (admin)
+// Dry run lists who qualifies and why others do not. Otherwise a background job issues the
+// certificates and builds a ZIP of every PDF plus manifest.csv; poll GET /jobs/:id and
+// download from GET /jobs/:id/artifact.
+func BulkIssueCertificates(ctx *gin.Context) {
+	var course models.Course
+	if err := database.DB.First(&course, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "course not found"})
+		return
+	}
+
+	candidates, rule, err := bulkCandidates(course.ID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to evaluate enrollments", "details": err.Error()})
+		return
+	}
+
+	if ctx.Query("dry_run") == "true" {
+		counts := map[string]int{}
+		for _, c := range candidates {
+			counts[c.Action]++
+		}
+		ctx.JSON(200, gin.H{
+			"dry_run":        true,
+			"course_id":      course.ID,
+			"rule":           rule,
+			"to_issue":       counts[bulkIssue],
+			"already_issued": counts[bulkAlreadyIssued],
+			"ineligible":     counts[bulkSkip],
+			"candidates":     candidates,
+		})
+		return
+	}
+
+	adminID, _ := getContextUserID(ctx)
+	job, err := startJob(jobTypeBulkCertificates, adminID, func(job *models.Job) (string, error) {
+		return runBulkIssue(job, course, rule, candidates, adminID)
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to start bulk issuance", "details": err.Error()})
+		return
+	}
+	ctx.JSON(202, gin.H{"message": "bulk certificate issuance started", "job": job, "candidates": len(candidates)})
+}
+
+func runBulkIssue(job *models.Job, course models.Course, rule models.CompletionRule, candidates []bulkCandidate, adminID uint) (string, error) {
+	job.Total = len(candidates)
+
+	if err := os.MkdirAll(exportDir, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(exportDir, "bulk-*.zip.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	archive := zip.NewWriter(tmp)
+
+	manifest := [][]string{{"enrollment_id", "user_id", "name", "email", "status", "certificate_code", "issued_at", "verify_url", "reason"}}
+	issued, included, skipped, failed := 0, 0, 0, 0
+
+	for i, c := range candidates {
+		row := []string{fmt.Sprint(c.EnrollmentID), fmt.Sprint(c.UserID), c.Name, c.Email, c.Action, "", "", "", strings.Join(c.Reasons, "; ")}
+
+		var cert *models.Certificate
+		switch c.Action {
+		case bulkIssue:
+			_, cert, err = completeEnrollment(c.EnrollmentID, true, &adminID, "bulk issuance")
+			if err == nil && cert == nil {
+				// Issued by someone else since the candidates were listed
+				cert, err = liveCertificate(c.UserID, course.ID)
+				row[4] = bulkAlreadyIssued
+			}
+			switch {
+			case err != nil:
+			case row[4] == bulkAlreadyIssued:
+				included++
+			default:
+				issued++
+				if rule.EmailCertificate {
+					go emailCertificate(cert.ID)
+				}
+			}
+		case bulkAlreadyIssued:
+			cert, err = liveCertificate(c.UserID, course.ID)
+			if err == nil {
+				included++
+			}
+		default:
+			skipped++
+		}
+
+		if err == nil && cert != nil {
+			err = addCertificateToZip(archive, cert.ID)
+		}
+		if err != nil {
+			failed++
+			row[4], row[8] = "failed", err.Error()
+			fmt.Println("ERROR: bulk certificate for enrollment", c.EnrollmentID, ":", err)
+			err = nil
+		}
+		if cert != nil {
+			row[5], row[6], row[7] = cert.CertCode, cert.IssuedAt.UTC().Format(time.RFC3339), utils.CertificateVerifyURL(cert.CertCode)
+		}
+		manifest = append(manifest, row)
+		jobProgress(job, i+1)
+	}
+
+	w, err := archive.Create("manifest.csv")
+	if err != nil {
+		return "", err
+	}
+	csvWriter := csv.NewWriter(w)
+	csvWriter.WriteAll(manifest)
+	if err := csvWriter.Error(); err != nil {
+		return "", err
+	}
+	if err := archive.Close(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(exportDir, fmt.Sprintf("job-%d.zip", job.ID))
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	if err := jobArtifact(job, path, fmt.Sprintf("certificates-%s.zip", course.Code)); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d issued, %d already issued, %d ineligible, %d failed", issued, included, skipped, failed), nil
+}
+
+// liveCertificate → the student's current certificate for a course
+func liveCertificate(userID, courseID uint) (*models.Certificate, error) {
+	cert, found, err := activeCertificate(database.DB, models.Enrollment{UserID: userID, CourseID: courseID})
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no live certificate found")
+	}
+	return &cert, nil
+}
+
+// addCertificateToZip renders (or reuses the cached) PDF and stores it as <code>.pdf
+func addCertificateToZip(archive *zip.Writer, certID uint) error {
+	var cert models.Certificate
+	if err := database.DB.Preload("User").Preload("Course.Department").First(&cert, certID).Error; err != nil {
+		return err
+	}
+	path, err := cachedCertificatePDF(cert)
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := archive.Create("certificates/" + cert.CertCode + ".pdf")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
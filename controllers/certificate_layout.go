@@ -0,0 +1,338 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+)
+
+// Where template assets and generated PDFs live on disk
+const (
+	certificateAssetDir = "data/certificate_templates"
+	certificateCacheDir = "data/certificate_cache"
+)
+
+// Fonts built into every PDF; anything else must be an uploaded font asset
+var coreFonts = map[string]bool{"arial": true, "helvetica": true, "times": true, "courier": true}
+
+// certificateTemplateData is what placeholders such as {{.User.Name}} can refer to
+type certificateTemplateData struct {
+	User         models.User
+	Course       models.Course
+	Department   models.Department
+	Code         string
+	IssuedAt     time.Time
+	IssuedDate   string // 02 Jan 2006
+	SignedAt     string // issue time exactly as signed (RFC 3339 UTC), needed to check the signature
+	VerifyURL    string
+	Signature    string
+	SigningKeyID string
+}
+
+func newCertificateTemplateData(cert models.Certificate) certificateTemplateData {
+	data := certificateTemplateData{
+		Code:         cert.CertCode,
+		IssuedAt:     cert.IssuedAt,
+		IssuedDate:   cert.IssuedAt.Format("02 Jan 2006"),
+		SignedAt:     certificatePayload(cert).IssuedAt,
+		VerifyURL:    utils.CertificateVerifyURL(cert.CertCode),
+		Signature:    cert.Signature,
+		SigningKeyID: cert.SigningKeyID,
+	}
+	if cert.User != nil {
+		data.User = *cert.User
+	}
+	if cert.Course != nil {
+		data.Course = *cert.Course
+		if cert.Course.Department != nil {
+			data.Department = *cert.Course.Department
+		}
+	}
+	// Print the names the signature covers, not whatever the account or course is called today
+	if cert.HolderName != "" {
+		data.User.Name = cert.HolderName
+	}
+	if cert.CourseTitle != "" {
+		data.Course.Title = cert.CourseTitle
+	}
+	return data
+}
+
+// sampleCertificateData fills previews and checks placeholders when a template is saved
+func sampleCertificateData() certificateTemplateData {
+	issued := time.Now().Truncate(time.Second)
+	return certificateTemplateData{
+		User:         models.User{Name: "Jane Student", Email: "jane.student@example.com"},
+		Course:       models.Course{Title: "Introduction to Programming", Code: "CS101", Credits: 4},
+		Department:   models.Department{Name: "Computer Science"},
+		Code:         "CERT-0000-SAMPLE",
+		IssuedAt:     issued,
+		IssuedDate:   issued.Format("02 Jan 2006"),
+		SignedAt:     utils.NewCertificatePayload("", "", issued, "").IssuedAt,
+		VerifyURL:    utils.CertificateVerifyURL("CERT-0000-SAMPLE"),
+		Signature:    strings.Repeat("x", 86),
+		SigningKeyID: "0000000000000000",
+	}
+}
+
+// builtinCertificateTemplate is the original fixed layout, used until an admin configures a template
+func builtinCertificateTemplate() models.CertificateTemplate {
+	return models.CertificateTemplate{
+		Name:        "Built-in",
+		Orientation: models.OrientationPortrait,
+		Elements: []models.CertificateTemplateElement{
+			{Type: models.ElementText, X: 10, Y: 20, Width: 190, Content: "Certificate of Completion", Font: "Arial", Style: "B", FontSize: 24, Align: "C"},
+			{Type: models.ElementText, X: 10, Y: 60, Width: 190, FontSize: 14, Align: "C",
+				Content: "This is to certify that {{.User.Name}} has successfully completed the course \"{{.Course.Title}}\" on {{.IssuedDate}}.\n\nCertificate Code: {{.Code}}"},
+			{Type: models.ElementText, X: 10, Y: 130, Width: 190, Content: "______________________\nInstructor / Admin Signature", Style: "I", FontSize: 12, Align: "R"},
+			{Type: models.ElementQR, X: 150, Y: 250, Width: 40, Height: 40},
+			{Type: models.ElementText, X: 10, Y: 255, Width: 135, Font: "Courier", FontSize: 6,
+				Content: "{{if .Signature}}Ed25519 signature (key {{.SigningKeyID}}), issued_at {{.SignedAt}}:\n{{.Signature}}{{end}}"},
+		},
+	}
+}
+
+// resolveCertificateTemplate picks the course's template, else its department's, else the platform default
+func resolveCertificateTemplate(course models.Course) (models.CertificateTemplate, error) {
+	scopes := []func(*gorm.DB) *gorm.DB{
+		func(db *gorm.DB) *gorm.DB { return db.Where("course_id = ?", course.ID) },
+		func(db *gorm.DB) *gorm.DB {
+			return db.Where("department_id = ? AND course_id IS NULL", course.DepartmentID)
+		},
+		func(db *gorm.DB) *gorm.DB { return db.Where("course_id IS NULL AND department_id IS NULL") },
+	}
+	for _, scope := range scopes {
+		var tmpl models.CertificateTemplate
+		err := loadCertificateTemplate(database.DB.Scopes(scope)).First(&tmpl).Error
+		if err == nil {
+			return tmpl, nil
+		}
+		if err != gorm.ErrRecordNotFound {
+			return tmpl, err
+		}
+	}
+	return builtinCertificateTemplate(), nil
+}
+
+// loadCertificateTemplate preloads what rendering needs
+func loadCertificateTemplate(db *gorm.DB) *gorm.DB {
+	return db.Preload("Elements", func(db *gorm.DB) *gorm.DB {
+		return db.Order("order_index, id")
+	}).Preload("Assets")
+}
+
+// renderCertificatePDF lays out a template for one certificate's data
+func renderCertificatePDF(tmpl models.CertificateTemplate, data certificateTemplateData, w io.Writer) error {
+	orientation := "P"
+	if tmpl.Orientation == models.OrientationLandscape {
+		orientation = "L"
+	}
+	pdf := gofpdf.New(orientation, "mm", "A4", "")
+	pdf.SetMargins(0, 0, 0)
+	pdf.SetAutoPageBreak(false, 0)
+	pdf.AddPage()
+	pageW, pageH := pdf.GetPageSize()
+
+	assets := map[string]models.CertificateTemplateAsset{}
+	for _, a := range tmpl.Assets {
+		assets[a.Name] = a
+	}
+
+	if tmpl.Background != "" {
+		bg, ok := assets[tmpl.Background]
+		if !ok {
+			return fmt.Errorf("background asset %q not found", tmpl.Background)
+		}
+		pdf.ImageOptions(bg.Path, 0, 0, pageW, pageH, false, imageOptions(bg.Path), 0, "")
+	}
+
+	cp1252 := pdf.UnicodeTranslatorFromDescriptor("")
+	registered := map[string]bool{}
+
+	for _, el := range tmpl.Elements {
+		switch el.Type {
+		case models.ElementText:
+			text, err := executePlaceholders(el.Content, data)
+			if err != nil {
+				return err
+			}
+			family, style := el.Font, strings.ToUpper(el.Style)
+			if family == "" {
+				family = "Arial"
+			}
+			size := el.FontSize
+			if size <= 0 {
+				size = 12
+			}
+			if font, ok := assets[family]; ok {
+				key := family + strings.ReplaceAll(style, "U", "")
+				if !registered[key] {
+					pdf.AddUTF8Font(family, strings.ReplaceAll(style, "U", ""), font.Path)
+					registered[key] = true
+				}
+			} else {
+				text = cp1252(text)
+			}
+			pdf.SetFont(family, style, size)
+			r, g, b := parseHexColor(el.Color)
+			pdf.SetTextColor(r, g, b)
+
+			width := el.Width
+			if width <= 0 {
+				width = pageW - el.X
+			}
+			align := el.Align
+			if align == "" {
+				align = "L"
+			}
+			pdf.SetXY(el.X, el.Y)
+			pdf.MultiCell(width, size*0.3528*1.3, text, "", align, false)
+
+		case models.ElementImage:
+			img, ok := assets[el.Asset]
+			if !ok {
+				return fmt.Errorf("image asset %q not found", el.Asset)
+			}
+			pdf.ImageOptions(img.Path, el.X, el.Y, el.Width, el.Height, false, imageOptions(img.Path), 0, "")
+
+		case models.ElementQR:
+			// The signature rides along in the QR so the certificate can be checked offline
+			qrData := data.VerifyURL
+			if data.Signature != "" {
+				qrData += "?sig=" + data.Signature
+			}
+			code, err := qr.Encode(qrData, qr.M, qr.Auto)
+			if err != nil {
+				return err
+			}
+			if code, err = barcode.Scale(code, 300, 300); err != nil {
+				return err
+			}
+			// barcode images encode as 16-bit PNG, which the PDF writer cannot embed
+			gray := image.NewGray(code.Bounds())
+			draw.Draw(gray, gray.Bounds(), code, code.Bounds().Min, draw.Src)
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, gray); err != nil {
+				return err
+			}
+			name := fmt.Sprintf("qr-%d-%v-%v", el.ID, el.X, el.Y)
+			size := el.Width
+			if size <= 0 {
+				size = 40
+			}
+			height := el.Height
+			if height <= 0 {
+				height = size
+			}
+			pdf.RegisterImageOptionsReader(name, gofpdf.ImageOptions{ImageType: "PNG"}, &buf)
+			pdf.ImageOptions(name, el.X, el.Y, size, height, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		}
+
+		if pdf.Err() {
+			return pdf.Error()
+		}
+	}
+
+	return pdf.Output(w)
+}
+
+func executePlaceholders(content string, data certificateTemplateData) (string, error) {
+	t, err := template.New("element").Parse(content)
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err := t.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func imageOptions(path string) gofpdf.ImageOptions {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	if ext == "jpeg" {
+		ext = "jpg"
+	}
+	return gofpdf.ImageOptions{ImageType: ext, ReadDpi: true}
+}
+
+// parseHexColor reads #RRGGBB; anything else is black
+func parseHexColor(s string) (int, int, int) {
+	var r, g, b int
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return 0, 0, 0
+	}
+	return r, g, b
+}
+
+// certificateCachePath → where a certificate's PDF for a template version is kept.
+// The template's UpdatedAt and the signed fields are part of the name, so edits never serve a stale PDF.
+func certificateCachePath(tmpl models.CertificateTemplate, cert models.Certificate, data certificateTemplateData) string {
+	dir := "builtin"
+	if tmpl.ID != 0 {
+		dir = fmt.Sprintf("template-%d", tmpl.ID)
+	}
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		tmpl.UpdatedAt.UTC().Format(time.RFC3339Nano),
+		cert.CertCode, cert.Signature, data.User.Name, data.User.Email, data.Course.Title, data.Department.Name,
+	}, "\x00")))
+	return filepath.Join(certificateCacheDir, dir, fmt.Sprintf("%d-%s.pdf", cert.ID, hex.EncodeToString(sum[:8])))
+}
+
+// cachedCertificatePDF returns the path of the certificate's PDF, rendering it on a cache miss
+func cachedCertificatePDF(cert models.Certificate) (string, error) {
+	if cert.Course == nil {
+		return "", fmt.Errorf("certificate course not loaded")
+	}
+	tmpl, err := resolveCertificateTemplate(*cert.Course)
+	if err != nil {
+		return "", err
+	}
+	data := newCertificateTemplateData(cert)
+
+	path := certificateCachePath(tmpl, cert, data)
+	if _, err := os.Stat(path); err == nil {
+		return path, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	// Render to a temporary file and rename, so a concurrent download never sees half a PDF
+	tmp, err := os.CreateTemp(filepath.Dir(path), "render-*.pdf")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if err := renderCertificatePDF(tmpl, data, tmp); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return path, os.Rename(tmp.Name(), path)
+}
+
+// invalidateCertificateCache drops every PDF rendered from a template
+func invalidateCertificateCache(templateID uint) error {
+	return os.RemoveAll(filepath.Join(certificateCacheDir, fmt.Sprintf("template-%d", templateID)))
+}
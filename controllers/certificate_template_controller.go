@@ -0,0 +1,423 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxTemplateAssetSize caps uploaded images and fonts
+const maxTemplateAssetSize = 5 << 20
+
+var (
+	validOrientations = map[string]bool{models.OrientationPortrait: true, models.OrientationLandscape: true}
+	validElementTypes = map[string]bool{models.ElementText: true, models.ElementImage: true, models.ElementQR: true}
+	assetExtensions   = map[string]map[string]bool{
+		models.AssetImage: {".png": true, ".jpg": true, ".jpeg": true, ".gif": true},
+		models.AssetFont:  {".ttf": true},
+	}
+	assetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+)
+
+type certificateTemplateInput struct {
+	Name         string                              `json:"name" binding:"required"`
+	CourseID     *uint                               `json:"course_id"`
+	DepartmentID *uint                               `json:"department_id"`
+	Orientation  string                              `json:"orientation"`
+	Background   string                              `json:"background"`
+	Elements     []models.CertificateTemplateElement `json:"elements"`
+}
+
+// apply copies the input onto a template, keeping its id and assets
+func (in certificateTemplateInput) apply(tmpl *models.CertificateTemplate) {
+	tmpl.Name = in.Name
+	tmpl.CourseID, tmpl.DepartmentID = in.CourseID, in.DepartmentID
+	tmpl.Orientation = in.Orientation
+	if tmpl.Orientation == "" {
+		tmpl.Orientation = models.OrientationLandscape
+	}
+	tmpl.Background = in.Background
+	tmpl.Elements = in.Elements
+	for i := range tmpl.Elements {
+		tmpl.Elements[i].ID = 0
+		tmpl.Elements[i].TemplateID = tmpl.ID
+		tmpl.Elements[i].OrderIndex = i
+	}
+}
+
+// validateCertificateTemplate checks scope, assets, fonts and that every placeholder renders
+func validateCertificateTemplate(tmpl models.CertificateTemplate) error {
+	if tmpl.CourseID != nil && tmpl.DepartmentID != nil {
+		return fmt.Errorf("set course_id or department_id, not both")
+	}
+	if tmpl.CourseID != nil {
+		var course models.Course
+		if err := database.DB.First(&course, *tmpl.CourseID).Error; err != nil {
+			return fmt.Errorf("course not found")
+		}
+	}
+	if tmpl.DepartmentID != nil {
+		var dept models.Department
+		if err := database.DB.First(&dept, *tmpl.DepartmentID).Error; err != nil {
+			return fmt.Errorf("department not found")
+		}
+	}
+
+	// One template per course, per department and one platform default
+	scope := database.DB.Model(&models.CertificateTemplate{}).Where("id <> ?", tmpl.ID)
+	switch {
+	case tmpl.CourseID != nil:
+		scope = scope.Where("course_id = ?", *tmpl.CourseID)
+	case tmpl.DepartmentID != nil:
+		scope = scope.Where("department_id = ?", *tmpl.DepartmentID)
+	default:
+		scope = scope.Where("course_id IS NULL AND department_id IS NULL")
+	}
+	var taken int64
+	if err := scope.Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return fmt.Errorf("a template already exists for this course, department or as the default")
+	}
+
+	if !validOrientations[tmpl.Orientation] {
+		return fmt.Errorf("orientation must be portrait or landscape")
+	}
+
+	assets := map[string]string{}
+	for _, a := range tmpl.Assets {
+		assets[a.Name] = a.Kind
+	}
+	if tmpl.Background != "" && assets[tmpl.Background] != models.AssetImage {
+		return fmt.Errorf("background %q is not an uploaded image", tmpl.Background)
+	}
+
+	sample := sampleCertificateData()
+	for i, el := range tmpl.Elements {
+		if !validElementTypes[el.Type] {
+			return fmt.Errorf("element %d: type must be one of text, image, qr", i)
+		}
+		if el.X < 0 || el.Y < 0 || el.Width < 0 || el.Height < 0 {
+			return fmt.Errorf("element %d: position and size cannot be negative", i)
+		}
+		switch el.Type {
+		case models.ElementText:
+			if el.Font != "" && !coreFonts[strings.ToLower(el.Font)] && assets[el.Font] != models.AssetFont {
+				return fmt.Errorf("element %d: font %q is neither Arial, Helvetica, Times, Courier nor an uploaded font", i, el.Font)
+			}
+			if strings.Trim(strings.ToUpper(el.Style), "BIU") != "" {
+				return fmt.Errorf("element %d: style may only contain B, I and U", i)
+			}
+			if el.Align != "" && !strings.Contains("LCR", el.Align) {
+				return fmt.Errorf("element %d: align must be L, C or R", i)
+			}
+			if el.Color != "" {
+				var r, g, b int
+				if _, err := fmt.Sscanf(el.Color, "#%02x%02x%02x", &r, &g, &b); err != nil || len(el.Color) != 7 {
+					return fmt.Errorf("element %d: color must look like #1F3A5F", i)
+				}
+			}
+			if _, err := executePlaceholders(el.Content, sample); err != nil {
+				return fmt.Errorf("element %d: %v", i, err)
+			}
+		case models.ElementImage:
+			if assets[el.Asset] != models.AssetImage {
+				return fmt.Errorf("element %d: asset %q is not an uploaded image", i, el.Asset)
+			}
+		}
+	}
+	return nil
+}
+
+// touchCertificateTemplate bumps the template's version and drops its cached PDFs
+func touchCertificateTemplate(tx *gorm.DB, tmpl *models.CertificateTemplate) error {
+	tmpl.UpdatedAt = time.Now()
+	if err := tx.Model(tmpl).UpdateColumn("updated_at", tmpl.UpdatedAt).Error; err != nil {
+		return err
+	}
+	return invalidateCertificateCache(tmpl.ID)
+}
+
+// CreateCertificateTemplate → POST /certificate-templates
+// Upload assets afterwards, then reference them from the layout with PUT.
+func CreateCertificateTemplate(ctx *gin.Context) {
+	var input certificateTemplateInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	var tmpl models.CertificateTemplate
+	input.apply(&tmpl)
+	if err := validateCertificateTemplate(tmpl); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid template", "details": err.Error()})
+		return
+	}
+	tmpl.CreatedBy, _ = getContextUserID(ctx)
+
+	if err := database.DB.Create(&tmpl).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to create template", "details": err.Error()})
+		return
+	}
+	ctx.JSON(201, gin.H{"message": "certificate template created successfully", "template": tmpl})
+}
+
+// GetCertificateTemplates → GET /certificate-templates?course_id=&department_id=
+func GetCertificateTemplates(ctx *gin.Context) {
+	query := database.DB.Order("id")
+	if courseID := ctx.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if deptID := ctx.Query("department_id"); deptID != "" {
+		query = query.Where("department_id = ?", deptID)
+	}
+
+	var templates []models.CertificateTemplate
+	if err := query.Find(&templates).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch templates", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"templates": templates})
+}
+
+// GetCertificateTemplateByID → GET /certificate-templates/:id
+func GetCertificateTemplateByID(ctx *gin.Context) {
+	var tmpl models.CertificateTemplate
+	if err := loadCertificateTemplate(database.DB).First(&tmpl, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "template not found"})
+		return
+	}
+	ctx.JSON(200, gin.H{"template": tmpl})
+}
+
+// UpdateCertificateTemplate → PUT /certificate-templates/:id
+// Replaces the layout; PDFs rendered from the old version are discarded.
+func UpdateCertificateTemplate(ctx *gin.Context) {
+	var tmpl models.CertificateTemplate
+	if err := database.DB.Preload("Assets").First(&tmpl, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "template not found"})
+		return
+	}
+
+	var input certificateTemplateInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	input.apply(&tmpl)
+	if err := validateCertificateTemplate(tmpl); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid template", "details": err.Error()})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", tmpl.ID).Delete(&models.CertificateTemplateElement{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Omit("Elements", "Assets").Save(&tmpl).Error; err != nil {
+			return err
+		}
+		if len(tmpl.Elements) > 0 {
+			if err := tx.Create(&tmpl.Elements).Error; err != nil {
+				return err
+			}
+		}
+		return invalidateCertificateCache(tmpl.ID)
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to update template", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "certificate template updated successfully", "template": tmpl})
+}
+
+// DeleteCertificateTemplate → DELETE /certificate-templates/:id
+// Certificates it covered fall back to the next less specific template.
+func DeleteCertificateTemplate(ctx *gin.Context) {
+	var tmpl models.CertificateTemplate
+	if err := database.DB.First(&tmpl, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "template not found"})
+		return
+	}
+
+	// Elements and assets go with it through the cascading foreign keys
+	if err := database.DB.Delete(&tmpl).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to delete template", "details": err.Error()})
+		return
+	}
+	invalidateCertificateCache(tmpl.ID)
+	os.RemoveAll(filepath.Join(certificateAssetDir, fmt.Sprint(tmpl.ID)))
+
+	ctx.JSON(200, gin.H{"message": "certificate template deleted successfully"})
+}
+
+// UploadCertificateTemplateAsset → POST /certificate-templates/:id/assets (multipart: file, kind, name)
+// kind is image (background, logo, signature) or font (TrueType). Re-uploading a name replaces it.
+func UploadCertificateTemplateAsset(ctx *gin.Context) {
+	var tmpl models.CertificateTemplate
+	if err := database.DB.First(&tmpl, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "template not found"})
+		return
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "file is required", "details": err.Error()})
+		return
+	}
+	kind := ctx.DefaultPostForm("kind", models.AssetImage)
+	extensions, ok := assetExtensions[kind]
+	if !ok {
+		ctx.JSON(400, gin.H{"error": "kind must be image or font"})
+		return
+	}
+	name := ctx.DefaultPostForm("name", fileHeader.Filename)
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if !extensions[ext] {
+		ctx.JSON(400, gin.H{"error": fmt.Sprintf("unsupported %s file type %q", kind, ext)})
+		return
+	}
+	if !assetNamePattern.MatchString(name) {
+		ctx.JSON(400, gin.H{"error": "name may only contain letters, digits, dots, dashes and underscores"})
+		return
+	}
+	if fileHeader.Size > maxTemplateAssetSize {
+		ctx.JSON(400, gin.H{"error": "file is larger than 5 MB"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "failed to read file", "details": err.Error()})
+		return
+	}
+	content, err := io.ReadAll(io.LimitReader(file, maxTemplateAssetSize+1))
+	file.Close()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "failed to read file", "details": err.Error()})
+		return
+	}
+
+	// Reject files the PDF writer would choke on at download time
+	switch kind {
+	case models.AssetImage:
+		if _, _, err := image.DecodeConfig(bytes.NewReader(content)); err != nil {
+			ctx.JSON(400, gin.H{"error": "file is not a valid image", "details": err.Error()})
+			return
+		}
+	case models.AssetFont:
+		if len(content) < 4 || !(bytes.Equal(content[:4], []byte{0, 1, 0, 0}) || string(content[:4]) == "true") {
+			ctx.JSON(400, gin.H{"error": "file is not a TrueType font"})
+			return
+		}
+	}
+
+	// Stored under the asset name with the upload's extension, so the PDF writer knows the image type
+	dir := filepath.Join(certificateAssetDir, fmt.Sprint(tmpl.ID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to store file", "details": err.Error()})
+		return
+	}
+	path := filepath.Join(dir, strings.TrimSuffix(name, filepath.Ext(name))+ext)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to store file", "details": err.Error()})
+		return
+	}
+
+	var asset models.CertificateTemplateAsset
+	database.DB.Where("template_id = ? AND name = ?", tmpl.ID, name).First(&asset)
+	if asset.Path != "" && asset.Path != path {
+		os.Remove(asset.Path)
+	}
+	asset.TemplateID, asset.Kind, asset.Name, asset.Path, asset.Size = tmpl.ID, kind, name, path, int64(len(content))
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&asset).Error; err != nil {
+			return err
+		}
+		return touchCertificateTemplate(tx, &tmpl)
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to save asset", "details": err.Error()})
+		return
+	}
+	ctx.JSON(201, gin.H{"message": "asset uploaded successfully", "asset": asset})
+}
+
+// DeleteCertificateTemplateAsset → DELETE /certificate-templates/:id/assets/:asset_id
+func DeleteCertificateTemplateAsset(ctx *gin.Context) {
+	var tmpl models.CertificateTemplate
+	if err := database.DB.Preload("Elements").First(&tmpl, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "template not found"})
+		return
+	}
+	var asset models.CertificateTemplateAsset
+	if err := database.DB.Where("template_id = ?", tmpl.ID).First(&asset, ctx.Param("asset_id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "asset not found"})
+		return
+	}
+
+	inUse := tmpl.Background == asset.Name
+	for _, el := range tmpl.Elements {
+		inUse = inUse || el.Asset == asset.Name || (el.Type == models.ElementText && el.Font == asset.Name)
+	}
+	if inUse {
+		ctx.JSON(409, gin.H{"error": "asset is used by the template layout, remove it from the layout first"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&asset).Error; err != nil {
+			return err
+		}
+		return touchCertificateTemplate(tx, &tmpl)
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to delete asset", "details": err.Error()})
+		return
+	}
+	os.Remove(asset.Path)
+	ctx.JSON(200, gin.H{"message": "asset deleted successfully"})
+}
+
+// PreviewCertificateTemplate → GET /certificate-templates/:id/preview?certificate_id=
+// Renders the template with sample data, or with a real certificate's data; never cached.
+func PreviewCertificateTemplate(ctx *gin.Context) {
+	var tmpl models.CertificateTemplate
+	if err := loadCertificateTemplate(database.DB).First(&tmpl, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "template not found"})
+		return
+	}
+
+	data := sampleCertificateData()
+	if certID := ctx.Query("certificate_id"); certID != "" {
+		var cert models.Certificate
+		if err := database.DB.Preload("User").Preload("Course.Department").First(&cert, certID).Error; err != nil {
+			ctx.JSON(404, gin.H{"error": "certificate not found"})
+			return
+		}
+		data = newCertificateTemplateData(cert)
+	}
+
+	var buf bytes.Buffer
+	if err := renderCertificatePDF(tmpl, data, &buf); err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to render preview", "details": err.Error()})
+		return
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=template-%d-preview.pdf", tmpl.ID))
+	ctx.Data(200, "application/pdf", buf.Bytes())
+}
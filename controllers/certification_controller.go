@@ -1,20 +1,28 @@
 package controllers
 
 import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/ayushwar/major/database"
 	"github.com/ayushwar/major/models"
 	"github.com/ayushwar/major/utils"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // IssueCertificate → POST /certificates/issue
-// Auto-issue only when course progress is 100%
+// Issues only when the course's completion rules are met (progress, final grade, required
+// assignments) and the enrollment is not suspended, completing the enrollment as well. Students request their own certificate; the
+// course teacher or an admin may name another student with user_id. Only one certificate is ever
+// issued per student and course: a revoked one is not replaced here (an admin reissues instead).
 func IssueCertificate(ctx *gin.Context) {
 	var req struct {
 		UserID   uint `json:"user_id"`
-		CourseID uint `json:"course_id"`
+		CourseID uint `json:"course_id" binding:"required"`
 	}
 
 	if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -22,6 +30,30 @@ func IssueCertificate(ctx *gin.Context) {
 		return
 	}
 
+	callerID, ok := getContextUserID(ctx)
+	if !ok {
+		ctx.JSON(401, gin.H{"error": "user not authenticated"})
+		return
+	}
+	if req.UserID == 0 {
+		req.UserID = callerID
+	}
+	if req.UserID != callerID && !canManageCourse(ctx, req.CourseID) {
+		ctx.JSON(403, gin.H{"error": "you can only request your own certificate"})
+		return
+	}
+
+	// Any earlier certificate counts, including revoked and superseded ones
+	var previous models.Certificate
+	if err := database.DB.Where("user_id = ? AND course_id = ?", req.UserID, req.CourseID).
+		Order("id DESC").First(&previous).Error; err == nil {
+		ctx.JSON(409, gin.H{"error": "a certificate has already been issued for this course", "certificate": previous, "status": certificateStatus(previous)})
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		ctx.JSON(500, gin.H{"error": "failed to check existing certificates", "details": err.Error()})
+		return
+	}
+
 	// Check enrollment
 	var enrollment models.Enrollment
 	if err := database.DB.Where("user_id = ? AND course_id = ?", req.UserID, req.CourseID).
@@ -30,24 +62,40 @@ func IssueCertificate(ctx *gin.Context) {
 		return
 	}
 
-	// Ensure course is fully completed
-	if enrollment.Progress < 100 {
-		ctx.JSON(400, gin.H{"error": "course not completed, certificate cannot be issued"})
+	// Same rules as automatic completion
+	rule, err := courseCompletionRule(enrollment.CourseID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch completion rule", "details": err.Error()})
 		return
 	}
-
-	// Create certificate
-	cert := models.Certificate{
-		UserID:   req.UserID,
-		CourseID: req.CourseID,
-		IssuedAt: time.Now(),
-		CertCode: utils.GenerateCertificateCode(), // util function
+	checks, met, err := evaluateCompletion(rule, enrollment, nil)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to evaluate completion", "details": err.Error()})
+		return
+	}
+	if !met {
+		ctx.JSON(400, gin.H{"error": "course not completed, certificate cannot be issued", "checks": checks})
+		return
+	}
+	if enrollment.Status == models.EnrollmentSuspended {
+		ctx.JSON(400, gin.H{"error": "enrollment is suspended, certificate cannot be issued"})
+		return
 	}
 
-	if err := database.DB.Create(&cert).Error; err != nil {
-		ctx.JSON(500, gin.H{"error": "failed to issue certificate"})
+	_, cert, err := completeEnrollment(enrollment.ID, true, &callerID, "requested after completion")
+	if errors.Is(err, errCertificateExists) || (err == nil && cert == nil) {
+		// Issued concurrently (e.g. by the completion engine)
+		existing, _, _ := activeCertificate(database.DB, enrollment)
+		ctx.JSON(409, gin.H{"error": errCertificateExists.Error(), "certificate": existing})
+		return
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to issue certificate", "details": err.Error()})
 		return
 	}
+	if rule.EmailCertificate {
+		go emailCertificate(cert.ID)
+	}
 
 	ctx.JSON(201, gin.H{"message": "certificate issued successfully", "certificate": cert})
 }
@@ -77,3 +125,371 @@ func GetCertificateByID(ctx *gin.Context) {
 
 	ctx.JSON(200, gin.H{"certificate": cert})
 }
+
+// certificatePayload → the canonical payload a certificate's signature covers, from the names stored at signing
+func certificatePayload(cert models.Certificate) utils.CertificatePayload {
+	return utils.NewCertificatePayload(cert.HolderName, cert.CourseTitle, cert.IssuedAt, cert.CertCode)
+}
+
+// loadCertificateSubjects loads a certificate's User and Course if the caller has not
+func loadCertificateSubjects(tx *gorm.DB, cert *models.Certificate) error {
+	if cert.User == nil {
+		var user models.User
+		if err := tx.First(&user, cert.UserID).Error; err != nil {
+			return fmt.Errorf("load user: %w", err)
+		}
+		cert.User = &user
+	}
+	if cert.Course == nil {
+		var course models.Course
+		if err := tx.First(&course, cert.CourseID).Error; err != nil {
+			return fmt.Errorf("load course: %w", err)
+		}
+		cert.Course = &course
+	}
+	return nil
+}
+
+// signCertificate copies the current holder name and course title onto the certificate and signs it
+func signCertificate(tx *gorm.DB, cert *models.Certificate) error {
+	if err := loadCertificateSubjects(tx, cert); err != nil {
+		return err
+	}
+	cert.HolderName, cert.CourseTitle = cert.User.Name, cert.Course.Title
+
+	signature, keyID, err := utils.Sign(certificatePayload(*cert).Bytes())
+	if err != nil {
+		return fmt.Errorf("sign certificate: %w", err)
+	}
+	cert.Signature, cert.SigningKeyID = signature, keyID
+	return nil
+}
+
+// prepareCertificate gives a new certificate an unused code and signs it.
+// UserID, CourseID and IssuedAt must be set; User and Course are loaded for the payload.
+func prepareCertificate(tx *gorm.DB, cert *models.Certificate) error {
+	// The database keeps whole seconds; sign exactly what will be stored
+	cert.IssuedAt = cert.IssuedAt.Truncate(time.Second)
+
+	cert.CertCode = ""
+	for i := 0; i < 5 && cert.CertCode == ""; i++ {
+		code := utils.GenerateCertificateCode()
+		var taken int64
+		if err := tx.Model(&models.Certificate{}).Where("cert_code = ?", code).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken == 0 {
+			cert.CertCode = code
+		}
+	}
+	if cert.CertCode == "" {
+		return fmt.Errorf("could not find an unused certificate code")
+	}
+
+	return signCertificate(tx, cert)
+}
+
+// GetCertificatePublicKey → GET /certificates/public-key (public)
+// Third parties verify a certificate offline by rebuilding the payload and checking the signature.
+func GetCertificatePublicKey(ctx *gin.Context) {
+	key, err := utils.SigningKey()
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "signing key unavailable", "details": err.Error()})
+		return
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	pemKey, err := utils.PublicKeyPEM(pub)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to encode public key", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"algorithm":          "Ed25519",
+		"key_id":             utils.SigningKeyID(pub),
+		"public_key":         base64.StdEncoding.EncodeToString(pub),
+		"public_key_pem":     pemKey,
+		"signature_encoding": "base64url without padding",
+		"payload_format":     `compact JSON without HTML escaping, keys in this order: {"holder":<name>,"course":<course title>,"issued_at":<RFC 3339 UTC, seconds>,"code":<certificate code>}`,
+		"issued_at_note":     "issued_at is the signed_issued_at value from the verify endpoint, also printed under the signature on the PDF",
+	})
+}
+
+// errCertificateExists → the enrollment already has a live certificate
+var errCertificateExists = errors.New("a certificate has already been issued for this enrollment")
+
+// activeCertificate finds the live (not revoked, not superseded) certificate for an enrollment's user and course.
+// Matching on user and course also catches certificates issued before they were linked to enrollments.
+func activeCertificate(tx *gorm.DB, enrollment models.Enrollment) (models.Certificate, bool, error) {
+	var cert models.Certificate
+	err := tx.Where("user_id = ? AND course_id = ? AND revoked_at IS NULL AND superseded_at IS NULL",
+		enrollment.UserID, enrollment.CourseID).First(&cert).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return cert, false, nil
+	}
+	return cert, err == nil, err
+}
+
+// issueCertificate creates and signs the certificate for an enrollment, records it in the history and
+// links it from the enrollment. replaces is the certificate being reissued, already marked superseded.
+func issueCertificate(tx *gorm.DB, enrollment models.Enrollment, actorID *uint, replaces *models.Certificate, reason string) (models.Certificate, error) {
+	return issueCertificateAt(tx, enrollment, time.Now(), actorID, replaces, reason)
+}
+
+// issueCertificateAt is issueCertificate with the issue time given (re-coding keeps the original one)
+func issueCertificateAt(tx *gorm.DB, enrollment models.Enrollment, issuedAt time.Time, actorID *uint, replaces *models.Certificate, reason string) (models.Certificate, error) {
+	if _, exists, err := activeCertificate(tx, enrollment); err != nil {
+		return models.Certificate{}, err
+	} else if exists {
+		return models.Certificate{}, errCertificateExists
+	}
+
+	cert := models.Certificate{
+		UserID:             enrollment.UserID,
+		CourseID:           enrollment.CourseID,
+		IssuedAt:           issuedAt,
+		EnrollmentID:       &enrollment.ID,
+		ActiveEnrollmentID: &enrollment.ID,
+	}
+	event := models.CertificateEvent{Type: models.CertificateIssued, Reason: reason, ActorID: actorID}
+	if replaces != nil {
+		cert.ReplacesID = &replaces.ID
+		event.Type, event.RelatedCode = models.CertificateReissued, replaces.CertCode
+	}
+
+	if err := prepareCertificate(tx, &cert); err != nil {
+		return cert, err
+	}
+	if err := tx.Omit("User", "Course", "SupersededBy", "Events").Create(&cert).Error; err != nil {
+		// The unique index on active_enrollment_id loses a race with a concurrent issue
+		if _, exists, _ := activeCertificate(tx, enrollment); exists {
+			return cert, errCertificateExists
+		}
+		return cert, err
+	}
+
+	event.CertificateID = cert.ID
+	if err := tx.Create(&event).Error; err != nil {
+		return cert, err
+	}
+	if err := tx.Model(&models.Enrollment{}).Where("id = ?", enrollment.ID).
+		Update("certificate_id", cert.CertCode).Error; err != nil {
+		return cert, err
+	}
+	cert.Events = []models.CertificateEvent{event}
+	return cert, nil
+}
+
+// RevokeCertificate → POST /certificates/:id/revoke (admin)
+// The certificate stays verifiable, reported as revoked with the reason.
+func RevokeCertificate(ctx *gin.Context) {
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request, reason is required", "details": err.Error()})
+		return
+	}
+
+	var cert models.Certificate
+	if err := database.DB.First(&cert, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "certificate not found"})
+		return
+	}
+	if cert.RevokedAt != nil {
+		ctx.JSON(409, gin.H{"error": "certificate is already revoked"})
+		return
+	}
+	if cert.SupersededAt != nil {
+		ctx.JSON(409, gin.H{"error": "certificate has been superseded, revoke its replacement instead", "superseded_by_id": cert.SupersededByID})
+		return
+	}
+
+	actorID, _ := getContextUserID(ctx)
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&cert).Updates(map[string]interface{}{
+			"revoked_at":           now,
+			"revoked_reason":       input.Reason,
+			"active_enrollment_id": nil,
+		}).Error; err != nil {
+			return err
+		}
+		event := models.CertificateEvent{CertificateID: cert.ID, Type: models.CertificateRevoked, Reason: input.Reason, ActorID: &actorID}
+		if err := tx.Create(&event).Error; err != nil {
+			return err
+		}
+		// The enrollment no longer has a certificate to point at
+		return tx.Model(&models.Enrollment{}).Where("certificate_id = ?", cert.CertCode).
+			Update("certificate_id", nil).Error
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to revoke certificate", "details": err.Error()})
+		return
+	}
+
+	cert.RevokedAt, cert.RevokedReason, cert.ActiveEnrollmentID = &now, input.Reason, nil
+	ctx.JSON(200, gin.H{"message": "certificate revoked", "certificate": cert})
+}
+
+// ReissueCertificate → POST /certificates/:id/reissue (admin)
+// Issues a fresh certificate with a new code from the current user and course details (fix a misspelled
+// name on the account first); the old code stays verifiable as superseded and points to the new one.
+func ReissueCertificate(ctx *gin.Context) {
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request, reason is required", "details": err.Error()})
+		return
+	}
+
+	var old models.Certificate
+	if err := database.DB.First(&old, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "certificate not found"})
+		return
+	}
+	if old.SupersededAt != nil {
+		ctx.JSON(409, gin.H{"error": "certificate has already been reissued, reissue its replacement instead", "superseded_by_id": old.SupersededByID})
+		return
+	}
+
+	var enrollment models.Enrollment
+	if err := database.DB.Where("user_id = ? AND course_id = ?", old.UserID, old.CourseID).First(&enrollment).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "enrollment not found"})
+		return
+	}
+
+	actorID, _ := getContextUserID(ctx)
+	var replacement models.Certificate
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		replacement, err = supersedeCertificate(tx, &old, enrollment, time.Now(), &actorID, input.Reason)
+		return err
+	})
+	if errors.Is(err, errCertificateExists) {
+		ctx.JSON(409, gin.H{"error": "another live certificate exists for this enrollment, reissue that one instead"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to reissue certificate", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(201, gin.H{"message": "certificate reissued", "certificate": replacement, "superseded_code": old.CertCode})
+}
+
+// supersedeCertificate retires old and issues its replacement, issued at issuedAt, in the same history
+func supersedeCertificate(tx *gorm.DB, old *models.Certificate, enrollment models.Enrollment, issuedAt time.Time, actorID *uint, reason string) (models.Certificate, error) {
+	// Retire the old one first so the enrollment's single live slot is free
+	if err := tx.Model(old).Updates(map[string]interface{}{
+		"superseded_at":        time.Now(),
+		"active_enrollment_id": nil,
+	}).Error; err != nil {
+		return models.Certificate{}, err
+	}
+
+	replacement, err := issueCertificateAt(tx, enrollment, issuedAt, actorID, old, reason)
+	if err != nil {
+		return replacement, err
+	}
+
+	if err := tx.Model(old).Update("superseded_by_id", replacement.ID).Error; err != nil {
+		return replacement, err
+	}
+	return replacement, tx.Create(&models.CertificateEvent{
+		CertificateID: old.ID,
+		Type:          models.CertificateSuperseded,
+		Reason:        reason,
+		RelatedCode:   replacement.CertCode,
+		ActorID:       actorID,
+	}).Error
+}
+
+const jobTypeCertificateSigning = "certificate_signing"
+
+// legacyRecodeReason is recorded on certificates replaced by the signing backfill
+const legacyRecodeReason = "re-issued with an unguessable code and a digital signature"
+
+// signLegacyCertificates stores the signed names on certificates from before they were kept, signing
+// any that are unsigned or no longer match. Live certificates that were never signed carry an old,
+// guessable code, so they are re-coded: superseded by a signed copy with the same issue time, which
+// keeps the old code verifiable and pointing at the new one. Revoked and superseded ones keep their code.
+func signLegacyCertificates(job *models.Job) (string, error) {
+	var certs []models.Certificate
+	if err := database.DB.Preload("User").Preload("Course").
+		Where("holder_name IS NULL OR holder_name = '' OR signature IS NULL OR signature = ''").
+		Order("id").Find(&certs).Error; err != nil {
+		return "", err
+	}
+	job.Total = len(certs)
+
+	signed, recoded := 0, 0
+	for i := range certs {
+		cert := &certs[i]
+		legacy := cert.Signature == ""
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if legacy || cert.HolderName == "" {
+				if err := signCertificate(tx, cert); err != nil {
+					return err
+				}
+				if err := tx.Model(cert).Updates(map[string]interface{}{
+					"holder_name":    cert.HolderName,
+					"course_title":   cert.CourseTitle,
+					"signature":      cert.Signature,
+					"signing_key_id": cert.SigningKeyID,
+				}).Error; err != nil {
+					return err
+				}
+				signed++
+			}
+			if !legacy || cert.RevokedAt != nil || cert.SupersededAt != nil {
+				return nil
+			}
+
+			var enrollment models.Enrollment
+			err := tx.Where("user_id = ? AND course_id = ?", cert.UserID, cert.CourseID).First(&enrollment).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil // nothing to link a replacement to; the signed original stays live
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := supersedeCertificate(tx, cert, enrollment, cert.IssuedAt, nil, legacyRecodeReason); err != nil {
+				return err
+			}
+			recoded++
+			return nil
+		})
+		if err != nil {
+			return "", fmt.Errorf("certificate %d: %v", cert.ID, err)
+		}
+		jobProgress(job, i+1)
+	}
+	return fmt.Sprintf("%d certificates checked, %d signed, %d re-coded", len(certs), signed, recoded), nil
+}
+
+// SignLegacyCertificates → POST /certificates/backfill-signatures (admin)
+// One-off upgrade for certificates issued before signing; safe to run again.
+func SignLegacyCertificates(ctx *gin.Context) {
+	adminID, _ := getContextUserID(ctx)
+	job, err := startJob(jobTypeCertificateSigning, adminID, signLegacyCertificates)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to start certificate signing", "details": err.Error()})
+		return
+	}
+	ctx.JSON(202, gin.H{"message": "certificate signing started", "job": job})
+}
+
+// GetCertificateHistory → GET /certificates/:id/history (admin)
+func GetCertificateHistory(ctx *gin.Context) {
+	var cert models.Certificate
+	if err := database.DB.Preload("Events", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at, id")
+	}).Preload("SupersededBy").First(&cert, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "certificate not found"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"certificate": cert, "status": certificateStatus(cert), "events": cert.Events})
+}
@@ -0,0 +1,424 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	verificationDocumentDir = "data/verification_documents"
+	maxVerificationDocSize  = 5 << 20
+
+	// verificationRenewalWindow is how long before expiry a student may apply again
+	verificationRenewalWindow = 30 * 24 * time.Hour
+)
+
+// verificationDocumentTypes maps accepted (sniffed) content types to the stored extension
+var verificationDocumentTypes = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+}
+
+// academicYearEnd → end of the academic year containing t. ACADEMIC_YEAR_END is the last day
+// as MM-DD (default 06-30).
+func academicYearEnd(t time.Time) time.Time {
+	month, day := time.June, 30
+	if end, err := time.Parse("01-02", os.Getenv("ACADEMIC_YEAR_END")); err == nil {
+		month, day = end.Month(), end.Day()
+	}
+	end := time.Date(t.Year(), month, day, 23, 59, 59, 0, t.Location())
+	if t.After(end) {
+		end = end.AddDate(1, 0, 0)
+	}
+	return end
+}
+
+// activeVerification → the user's verified, unexpired request, if any
+func activeVerification(db *gorm.DB, userID uint) (models.CollegeVerification, bool) {
+	var v models.CollegeVerification
+	err := db.Where("user_id = ? AND status = ? AND (expires_at IS NULL OR expires_at > ?)", userID, models.VerificationVerified, time.Now()).
+		Order("expires_at DESC").First(&v).Error
+	return v, err == nil
+}
+
+// syncProfileVerification sets Profile.Verified from the user's verification requests
+func syncProfileVerification(tx *gorm.DB, userID uint) error {
+	_, verified := activeVerification(tx, userID)
+	return tx.Model(&models.Profile{}).Where("user_id = ?", userID).Update("verified", verified).Error
+}
+
+// notifyVerification emails the student about their request; failures are logged, not retried
+func notifyVerification(verificationID uint) {
+	var v models.CollegeVerification
+	if err := database.DB.Preload("User").First(&v, verificationID).Error; err != nil || v.User == nil {
+		return
+	}
+
+	var subject, body string
+	switch v.Status {
+	case models.VerificationVerified:
+		subject = "Your student verification was approved"
+		body = fmt.Sprintf("Hello %s,\n\nYour enrollment at %s has been verified. Student discounts apply to your purchases until %s.\n",
+			v.User.Name, v.College, v.ExpiresAt.Format("02 Jan 2006"))
+	case models.VerificationRejected:
+		subject = "Your student verification was not approved"
+		body = fmt.Sprintf("Hello %s,\n\nWe could not verify your enrollment at %s.\nReason: %s\n\nYou can submit a new document at any time.\n",
+			v.User.Name, v.College, v.RejectionReason)
+	case models.VerificationExpired:
+		subject = "Your student verification has expired"
+		body = fmt.Sprintf("Hello %s,\n\nYour student verification for %s expired at the end of the academic year.\n"+
+			"Upload a current ID card or enrollment letter to keep your student discount.\n", v.User.Name, v.College)
+	default:
+		return
+	}
+	if err := utils.SendEmail(v.User.Email, subject, body); err != nil {
+		fmt.Println("ERROR: failed to send verification email to", v.User.Email, ":", err)
+	}
+}
+
+// SubmitCollegeVerification → POST /verification (student, multipart: file, college, student_id)
+// The document is a PDF, JPEG or PNG of an ID card or enrollment letter, up to 5 MB. A new
+// request is allowed when nothing is pending and any current verification expires within 30 days.
+func SubmitCollegeVerification(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+	college := strings.TrimSpace(ctx.PostForm("college"))
+	studentID := strings.TrimSpace(ctx.PostForm("student_id"))
+	if college == "" || len(college) > 100 {
+		ctx.JSON(400, gin.H{"error": "college is required (up to 100 characters)"})
+		return
+	}
+	if len(studentID) > 20 {
+		ctx.JSON(400, gin.H{"error": "student_id must be at most 20 characters"})
+		return
+	}
+
+	var profile models.Profile
+	hasProfile := database.DB.Where("user_id = ?", userID).First(&profile).Error == nil
+	if !hasProfile && studentID == "" {
+		ctx.JSON(400, gin.H{"error": "student_id is required"})
+		return
+	}
+	if studentID != "" && studentID != profile.StudentID {
+		var taken int64
+		database.DB.Model(&models.Profile{}).Where("student_id = ? AND user_id <> ?", studentID, userID).Count(&taken)
+		if taken > 0 {
+			ctx.JSON(409, gin.H{"error": "student_id belongs to another account"})
+			return
+		}
+	}
+
+	var pending int64
+	database.DB.Model(&models.CollegeVerification{}).Where("user_id = ? AND status = ?", userID, models.VerificationPending).Count(&pending)
+	if pending > 0 {
+		ctx.JSON(409, gin.H{"error": "a verification request is already waiting for review"})
+		return
+	}
+	if current, ok := activeVerification(database.DB, userID); ok && current.ExpiresAt != nil &&
+		time.Until(*current.ExpiresAt) > verificationRenewalWindow {
+		ctx.JSON(409, gin.H{"error": "you are already verified", "expires_at": current.ExpiresAt})
+		return
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "file is required", "details": err.Error()})
+		return
+	}
+	if fileHeader.Size > maxVerificationDocSize {
+		ctx.JSON(400, gin.H{"error": "file is larger than 5 MB"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "failed to read file", "details": err.Error()})
+		return
+	}
+	content, err := io.ReadAll(io.LimitReader(file, maxVerificationDocSize+1))
+	file.Close()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "failed to read file", "details": err.Error()})
+		return
+	}
+	contentType := http.DetectContentType(content)
+	ext, ok := verificationDocumentTypes[contentType]
+	if !ok {
+		ctx.JSON(400, gin.H{"error": "document must be a PDF, JPEG or PNG file", "details": contentType})
+		return
+	}
+
+	dir := filepath.Join(verificationDocumentDir, fmt.Sprint(userID))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to store file", "details": err.Error()})
+		return
+	}
+	path := filepath.Join(dir, utils.RandomReference("doc-")+ext)
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to store file", "details": err.Error()})
+		return
+	}
+
+	verification := models.CollegeVerification{
+		UserID:       userID,
+		College:      college,
+		StudentID:    studentID,
+		Document:     path,
+		DocumentName: filepath.Base(fileHeader.Filename),
+		DocumentType: contentType,
+		Status:       models.VerificationPending,
+	}
+	if err := database.DB.Create(&verification).Error; err != nil {
+		os.Remove(path)
+		ctx.JSON(500, gin.H{"error": "failed to create verification request", "details": err.Error()})
+		return
+	}
+	ctx.JSON(201, gin.H{"message": "verification submitted for review", "verification": verification})
+}
+
+// GetMyCollegeVerification → GET /verification/me
+func GetMyCollegeVerification(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+	var requests []models.CollegeVerification
+	if err := database.DB.Where("user_id = ?", userID).Order("id DESC").Find(&requests).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch verification", "details": err.Error()})
+		return
+	}
+	response := gin.H{"verified": false, "requests": requests}
+	if current, ok := activeVerification(database.DB, userID); ok {
+		response["verified"], response["expires_at"] = true, current.ExpiresAt
+		response["can_renew"] = current.ExpiresAt == nil || time.Until(*current.ExpiresAt) <= verificationRenewalWindow
+	}
+	ctx.JSON(200, response)
+}
+
+// GetCollegeVerifications → GET /verification/requests?status=pending&user_id= (admin)
+// The review queue: oldest first, so requests are handled in the order they came in.
+func GetCollegeVerifications(ctx *gin.Context) {
+	query := database.DB.Preload("User").Order("created_at, id")
+	if status := ctx.DefaultQuery("status", models.VerificationPending); status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if userID := ctx.Query("user_id"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+	var requests []models.CollegeVerification
+	if err := query.Find(&requests).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch verification requests", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"requests": requests})
+}
+
+// GetCollegeVerification → GET /verification/requests/:id (admin)
+func GetCollegeVerification(ctx *gin.Context) {
+	var verification models.CollegeVerification
+	if err := database.DB.Preload("User").First(&verification, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "verification request not found"})
+		return
+	}
+	var profile models.Profile
+	database.DB.Where("user_id = ?", verification.UserID).First(&profile)
+	ctx.JSON(200, gin.H{"verification": verification, "profile": profile})
+}
+
+// GetCollegeVerificationDocument → GET /verification/requests/:id/document (admin, owner)
+func GetCollegeVerificationDocument(ctx *gin.Context) {
+	var verification models.CollegeVerification
+	if err := database.DB.First(&verification, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "verification request not found"})
+		return
+	}
+	userID, _ := getContextUserID(ctx)
+	if getUserRole(ctx) != "admin" && verification.UserID != userID {
+		ctx.JSON(404, gin.H{"error": "verification request not found"})
+		return
+	}
+	content, err := os.ReadFile(verification.Document)
+	if err != nil {
+		ctx.JSON(404, gin.H{"error": "document not found", "details": err.Error()})
+		return
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, strings.ReplaceAll(verification.DocumentName, `"`, "")))
+	ctx.Data(200, verification.DocumentType, content)
+}
+
+// reviewCollegeVerification locks a pending request and lets review change it; an approval
+// re-syncs the student's profile in the same transaction. The outcome is emailed.
+func reviewCollegeVerification(ctx *gin.Context, review func(tx *gorm.DB, v *models.CollegeVerification) error) {
+	adminID, _ := getContextUserID(ctx)
+	var verification models.CollegeVerification
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&verification, ctx.Param("id")).Error; err != nil {
+			return err
+		}
+		if verification.Status != models.VerificationPending {
+			return fmt.Errorf("request is already %s", verification.Status)
+		}
+		now := time.Now()
+		verification.ReviewedBy, verification.ReviewedAt = &adminID, &now
+		if err := review(tx, &verification); err != nil {
+			return err
+		}
+		if err := tx.Save(&verification).Error; err != nil {
+			return err
+		}
+		// A rejection leaves any earlier verification (and the profile) as it was
+		if verification.Status != models.VerificationVerified {
+			return nil
+		}
+		return syncProfileVerification(tx, verification.UserID)
+	})
+	if err == gorm.ErrRecordNotFound {
+		ctx.JSON(404, gin.H{"error": "verification request not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(409, gin.H{"error": "verification cannot be reviewed", "details": err.Error()})
+		return
+	}
+	go notifyVerification(verification.ID)
+	ctx.JSON(200, gin.H{"verification": verification})
+}
+
+// ApproveCollegeVerification → POST /verification/requests/:id/approve {note, expires_at} (admin)
+// Verified until the end of the academic year (after the current one's end, for a renewal)
+// unless expires_at is given. Creates the student's profile if they have none.
+func ApproveCollegeVerification(ctx *gin.Context) {
+	var input struct {
+		Note      string     `json:"note"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	if input.ExpiresAt != nil && !input.ExpiresAt.After(time.Now()) {
+		ctx.JSON(400, gin.H{"error": "expires_at must be in the future"})
+		return
+	}
+
+	reviewCollegeVerification(ctx, func(tx *gorm.DB, v *models.CollegeVerification) error {
+		now := time.Now()
+		expires := academicYearEnd(now)
+		if current, ok := activeVerification(tx, v.UserID); ok && current.ExpiresAt != nil && !current.ExpiresAt.Before(expires) {
+			expires = academicYearEnd(current.ExpiresAt.Add(time.Second))
+		}
+		if input.ExpiresAt != nil {
+			expires = *input.ExpiresAt
+		}
+		v.Status, v.VerifiedAt, v.ExpiresAt, v.ReviewNote, v.RejectionReason = models.VerificationVerified, &now, &expires, input.Note, ""
+
+		// The renewal replaces the verification it extends
+		if err := tx.Model(&models.CollegeVerification{}).
+			Where("user_id = ? AND status = ? AND id <> ?", v.UserID, models.VerificationVerified, v.ID).
+			Update("status", models.VerificationExpired).Error; err != nil {
+			return err
+		}
+
+		var profile models.Profile
+		err := tx.Where("user_id = ?", v.UserID).First(&profile).Error
+		if err == gorm.ErrRecordNotFound {
+			if v.StudentID == "" {
+				return fmt.Errorf("student has no profile and the request has no student_id")
+			}
+			return tx.Create(&models.Profile{UserID: v.UserID, College: v.College, StudentID: v.StudentID}).Error
+		}
+		if err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"college": v.College}
+		if v.StudentID != "" {
+			updates["student_id"] = v.StudentID
+		}
+		return tx.Model(&profile).Updates(updates).Error
+	})
+}
+
+// RejectCollegeVerification → POST /verification/requests/:id/reject {reason} (admin)
+// The reason is emailed to the student, who can submit again.
+func RejectCollegeVerification(ctx *gin.Context) {
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	reviewCollegeVerification(ctx, func(tx *gorm.DB, v *models.CollegeVerification) error {
+		v.Status, v.RejectionReason = models.VerificationRejected, input.Reason
+		return nil
+	})
+}
+
+// expireCollegeVerifications marks verifications past their expiry expired, clears the
+// students' Profile.Verified and asks them to verify again
+func expireCollegeVerifications() (int, error) {
+	var due []models.CollegeVerification
+	if err := database.DB.Where("status = ? AND expires_at <= ?", models.VerificationVerified, time.Now()).Find(&due).Error; err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, v := range due {
+		changed := false
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// A renewal approved meanwhile has already retired it
+			res := tx.Model(&models.CollegeVerification{}).Where("id = ? AND status = ?", v.ID, models.VerificationVerified).
+				Update("status", models.VerificationExpired)
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			changed = true
+			return syncProfileVerification(tx, v.UserID)
+		})
+		if err != nil {
+			return expired, err
+		}
+		if changed {
+			expired++
+			go notifyVerification(v.ID)
+		}
+	}
+	return expired, nil
+}
+
+// clearUnbackedProfileVerification unsets Profile.Verified for students with no active verification.
+// It catches profiles marked verified directly in the database before verification requests
+// existed, which nothing else would ever expire.
+func clearUnbackedProfileVerification() (int64, error) {
+	active := database.DB.Model(&models.CollegeVerification{}).Select("user_id").
+		Where("status = ? AND (expires_at IS NULL OR expires_at > ?)", models.VerificationVerified, time.Now())
+	res := database.DB.Model(&models.Profile{}).Where("verified = ? AND user_id NOT IN (?)", true, active).
+		Update("verified", false)
+	return res.RowsAffected, res.Error
+}
+
+// StartVerificationExpiry expires college verifications now and every hour
+func StartVerificationExpiry() {
+	go func() {
+		for {
+			if n, err := expireCollegeVerifications(); err != nil {
+				fmt.Println("ERROR: expiring college verifications:", err)
+			} else if n > 0 {
+				fmt.Println("expired", n, "college verifications")
+			}
+			if n, err := clearUnbackedProfileVerification(); err != nil {
+				fmt.Println("ERROR: clearing unverified profiles:", err)
+			} else if n > 0 {
+				fmt.Println("cleared verified flag on", n, "profiles without an active verification")
+			}
+			time.Sleep(time.Hour)
+		}
+	}()
+}
@@ -0,0 +1,396 @@
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const jobTypeCompletion = "completion"
+
+// completionCheck is one rule and whether the student meets it
+type completionCheck struct {
+	Rule     string `json:"rule"`
+	Met      bool   `json:"met"`
+	Required string `json:"required"`
+	Actual   string `json:"actual"`
+}
+
+// completionOutcome is the result of running the engine for one enrollment
+type completionOutcome struct {
+	EnrollmentID  uint                `json:"enrollment_id"`
+	Checks        []completionCheck   `json:"checks"`
+	RulesMet      bool                `json:"rules_met"`
+	Status        string              `json:"status"`
+	JustCompleted bool                `json:"just_completed"`
+	Certificate   *models.Certificate `json:"certificate,omitempty"` // issued by this run
+}
+
+// courseCompletionRule → the course's rule, or the defaults when none is configured
+func courseCompletionRule(courseID uint) (models.CompletionRule, error) {
+	var rule models.CompletionRule
+	err := database.DB.Where("course_id = ?", courseID).First(&rule).Error
+	if err == gorm.ErrRecordNotFound {
+		return models.CompletionRule{CourseID: courseID, MinProgress: 100, AutoIssueCertificate: true, EmailCertificate: true}, nil
+	}
+	return rule, err
+}
+
+// evaluateCompletion checks every rule for an enrollment without changing anything.
+// finalGrades (user → final %) saves rebuilding the gradebook per student; nil builds it here.
+func evaluateCompletion(rule models.CompletionRule, enrollment models.Enrollment, finalGrades map[uint]*float64) ([]completionCheck, bool, error) {
+	checks := []completionCheck{{
+		Rule:     "progress",
+		Met:      enrollment.Progress >= rule.MinProgress,
+		Required: fmt.Sprintf("%.2f%%", rule.MinProgress),
+		Actual:   fmt.Sprintf("%.2f%%", enrollment.Progress),
+	}}
+
+	if rule.MinFinalGrade != nil {
+		check := completionCheck{Rule: "final_grade", Required: fmt.Sprintf("%.2f%%", *rule.MinFinalGrade), Actual: "no grade yet"}
+		if finalGrades == nil {
+			var err error
+			if finalGrades, err = courseFinalGrades(enrollment.CourseID, &enrollment.UserID); err != nil {
+				return nil, false, err
+			}
+		}
+		if grade := finalGrades[enrollment.UserID]; grade != nil {
+			check.Actual = fmt.Sprintf("%.2f%%", *grade)
+			check.Met = *grade >= *rule.MinFinalGrade
+		}
+		checks = append(checks, check)
+	}
+
+	var required []models.Assignment
+	if err := database.DB.Where("course_id = ? AND required = ?", enrollment.CourseID, true).
+		Order("id").Find(&required).Error; err != nil {
+		return nil, false, err
+	}
+	if len(required) > 0 {
+		var submitted []uint
+		if err := database.DB.Model(&models.Submission{}).
+			Where("user_id = ? AND assignment_id IN ?", enrollment.UserID, assignmentIDs(required)).
+			Distinct().Pluck("assignment_id", &submitted).Error; err != nil {
+			return nil, false, err
+		}
+		done := map[uint]bool{}
+		for _, id := range submitted {
+			done[id] = true
+		}
+		var missing []string
+		for _, a := range required {
+			if !done[a.ID] {
+				missing = append(missing, a.Title)
+			}
+		}
+		check := completionCheck{
+			Rule:     "required_assignments",
+			Met:      len(missing) == 0,
+			Required: fmt.Sprintf("%d required assignments submitted", len(required)),
+			Actual:   fmt.Sprintf("%d submitted", len(required)-len(missing)),
+		}
+		if len(missing) > 0 {
+			check.Actual += "; missing: " + strings.Join(missing, ", ")
+		}
+		checks = append(checks, check)
+	}
+
+	met := true
+	for _, c := range checks {
+		met = met && c.Met
+	}
+	return checks, met, nil
+}
+
+// courseFinalGrades → each student's final gradebook percentage (nil until they have grades)
+func courseFinalGrades(courseID uint, onlyUser *uint) (map[uint]*float64, error) {
+	book, err := buildGradebook(courseID, onlyUser)
+	if err != nil {
+		return nil, err
+	}
+	grades := make(map[uint]*float64, len(book.Rows))
+	for _, row := range book.Rows {
+		grades[row.UserID] = row.Percent
+	}
+	return grades, nil
+}
+
+func assignmentIDs(assignments []models.Assignment) []uint {
+	ids := make([]uint, len(assignments))
+	for i, a := range assignments {
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+// runCompletion evaluates an enrollment and, once every rule holds, marks it completed and issues
+// its certificate. Completion is sticky, and a certificate is issued only if the student has never
+// had one for the course (a revoked certificate is not silently replaced). The email goes out after commit.
+func runCompletion(enrollmentID uint) (completionOutcome, error) {
+	outcome := completionOutcome{EnrollmentID: enrollmentID}
+
+	var enrollment models.Enrollment
+	if err := database.DB.First(&enrollment, enrollmentID).Error; err != nil {
+		return outcome, err
+	}
+	rule, err := courseCompletionRule(enrollment.CourseID)
+	if err != nil {
+		return outcome, err
+	}
+	outcome.Checks, outcome.RulesMet, err = evaluateCompletion(rule, enrollment, nil)
+	if err != nil {
+		return outcome, err
+	}
+	outcome.Status = enrollment.Status
+	// A refunded (suspended) enrollment keeps its progress but does not complete
+	if !outcome.RulesMet || enrollment.Status == models.EnrollmentSuspended {
+		return outcome, nil
+	}
+
+	outcome.JustCompleted, outcome.Certificate, err = completeEnrollment(enrollmentID, rule.AutoIssueCertificate, nil, "completion rules met")
+	if err != nil {
+		return outcome, err
+	}
+	outcome.Status = models.EnrollmentCompleted
+
+	if outcome.Certificate != nil && rule.EmailCertificate {
+		go emailCertificate(outcome.Certificate.ID)
+	}
+	return outcome, nil
+}
+
+// completeEnrollment marks an enrollment completed (if it is not already) and, when issue is set,
+// gives it a certificate unless the student has ever had one for the course. Callers check the rules.
+func completeEnrollment(enrollmentID uint, issue bool, actorID *uint, reason string) (bool, *models.Certificate, error) {
+	justCompleted := false
+	var issued *models.Certificate
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the enrollment so concurrent progress updates complete it only once
+		var enrollment models.Enrollment
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&enrollment, enrollmentID).Error; err != nil {
+			return err
+		}
+
+		if enrollment.Status != models.EnrollmentCompleted {
+			if err := tx.Model(&enrollment).Updates(map[string]interface{}{
+				"status":       models.EnrollmentCompleted,
+				"completed_at": time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+			justCompleted = true
+		}
+
+		if !issue {
+			return nil
+		}
+		var existing int64
+		if err := tx.Model(&models.Certificate{}).
+			Where("user_id = ? AND course_id = ?", enrollment.UserID, enrollment.CourseID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return nil
+		}
+		cert, err := issueCertificate(tx, enrollment, actorID, nil, reason)
+		if err != nil {
+			return err
+		}
+		issued = &cert
+		return nil
+	})
+	return justCompleted, issued, err
+}
+
+// emailCertificate sends the holder their certificate PDF; failures are logged, not retried
+func emailCertificate(certID uint) {
+	var cert models.Certificate
+	if err := database.DB.Preload("User").Preload("Course.Department").First(&cert, certID).Error; err != nil {
+		fmt.Println("ERROR: certificate email: certificate", certID, "not found:", err)
+		return
+	}
+	path, err := cachedCertificatePDF(cert)
+	if err != nil {
+		fmt.Println("ERROR: certificate email: failed to render certificate", certID, ":", err)
+		return
+	}
+	pdf, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Println("ERROR: certificate email: failed to read", path, ":", err)
+		return
+	}
+
+	subject := "Your certificate for " + cert.Course.Title
+	body := fmt.Sprintf("Hello %s,\n\nCongratulations on completing \"%s\"! Your certificate is attached.\n\n"+
+		"Certificate code: %s\nAnyone can verify it at %s\n",
+		cert.User.Name, cert.Course.Title, cert.CertCode, utils.CertificateVerifyURL(cert.CertCode))
+	filename := fmt.Sprintf("certificate_%s.pdf", cert.CertCode)
+	if err := utils.SendEmailWithAttachment(cert.User.Email, subject, body, filename, "application/pdf", pdf); err != nil {
+		fmt.Println("ERROR: failed to send certificate email to", cert.User.Email, ":", err)
+	}
+}
+
+// GetCompletionRule → GET /courses/:id/completion-rule
+func GetCompletionRule(ctx *gin.Context) {
+	courseID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid course id"})
+		return
+	}
+	rule, err := courseCompletionRule(uint(courseID))
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch completion rule", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"rule": rule, "default": rule.ID == 0})
+}
+
+// SetCompletionRule → PUT /courses/:id/completion-rule (course teacher/admin)
+// Omitting min_final_grade removes the grade requirement. Mark assignments as required on the
+// assignment itself (required: true).
+func SetCompletionRule(ctx *gin.Context) {
+	var course models.Course
+	if err := database.DB.First(&course, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "course not found"})
+		return
+	}
+	if !canManageCourse(ctx, course.ID) {
+		ctx.JSON(403, gin.H{"error": "only the course teacher or an admin can set completion rules"})
+		return
+	}
+
+	var input struct {
+		MinProgress          *float32 `json:"min_progress"`
+		MinFinalGrade        *float64 `json:"min_final_grade"`
+		AutoIssueCertificate *bool    `json:"auto_issue_certificate"`
+		EmailCertificate     *bool    `json:"email_certificate"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	rule, err := courseCompletionRule(course.ID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch completion rule", "details": err.Error()})
+		return
+	}
+	if input.MinProgress != nil {
+		rule.MinProgress = *input.MinProgress
+	}
+	rule.MinFinalGrade = input.MinFinalGrade
+	if input.AutoIssueCertificate != nil {
+		rule.AutoIssueCertificate = *input.AutoIssueCertificate
+	}
+	if input.EmailCertificate != nil {
+		rule.EmailCertificate = *input.EmailCertificate
+	}
+	if rule.MinProgress < 0 || rule.MinProgress > 100 {
+		ctx.JSON(400, gin.H{"error": "min_progress must be between 0 and 100"})
+		return
+	}
+	if rule.MinFinalGrade != nil && (*rule.MinFinalGrade < 0 || *rule.MinFinalGrade > 100) {
+		ctx.JSON(400, gin.H{"error": "min_final_grade must be between 0 and 100"})
+		return
+	}
+	rule.UpdatedBy, _ = getContextUserID(ctx)
+
+	if err := database.DB.Save(&rule).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to save completion rule", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "completion rule saved", "rule": rule})
+}
+
+// EvaluateCourseCompletion → POST /courses/:id/completion/evaluate (course teacher/admin)
+// Re-runs the engine for every enrollment, e.g. after final grades are in or auto-issue was switched on.
+// Safe to repeat: nothing is completed or issued twice. Poll GET /jobs/:id.
+func EvaluateCourseCompletion(ctx *gin.Context) {
+	var course models.Course
+	if err := database.DB.First(&course, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "course not found"})
+		return
+	}
+	if !canManageCourse(ctx, course.ID) {
+		ctx.JSON(403, gin.H{"error": "only the course teacher or an admin can evaluate completion"})
+		return
+	}
+
+	var enrollmentIDs []uint
+	if err := database.DB.Model(&models.Enrollment{}).
+		Where("course_id = ?", course.ID).
+		Order("id").Pluck("id", &enrollmentIDs).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch enrollments", "details": err.Error()})
+		return
+	}
+
+	userID, _ := getContextUserID(ctx)
+	job, err := startJob(jobTypeCompletion, userID, func(job *models.Job) (string, error) {
+		job.Total = len(enrollmentIDs)
+		completed, issued, failed := 0, 0, 0
+		for i, id := range enrollmentIDs {
+			outcome, err := runCompletion(id)
+			switch {
+			case err != nil:
+				failed++
+				fmt.Println("ERROR: completion for enrollment", id, ":", err)
+			case outcome.JustCompleted:
+				completed++
+			}
+			if outcome.Certificate != nil {
+				issued++
+			}
+			jobProgress(job, i+1)
+		}
+		return fmt.Sprintf("%d enrollments checked, %d completed, %d certificates issued, %d failed",
+			len(enrollmentIDs), completed, issued, failed), nil
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to start completion job", "details": err.Error()})
+		return
+	}
+	ctx.JSON(202, gin.H{"message": "completion evaluation started", "job": job})
+}
+
+// GetEnrollmentCompletion → GET /enrollments/:id/completion
+// Shows which completion rules the student meets; changes nothing.
+func GetEnrollmentCompletion(ctx *gin.Context) {
+	var enrollment models.Enrollment
+	if err := database.DB.First(&enrollment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "enrollment not found"})
+		return
+	}
+	userID, _ := getContextUserID(ctx)
+	if enrollment.UserID != userID && !canManageCourse(ctx, enrollment.CourseID) {
+		ctx.JSON(403, gin.H{"error": "you can only view your own enrollments"})
+		return
+	}
+
+	rule, err := courseCompletionRule(enrollment.CourseID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch completion rule", "details": err.Error()})
+		return
+	}
+	checks, met, err := evaluateCompletion(rule, enrollment, nil)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to evaluate completion", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{
+		"enrollment":     enrollment,
+		"rules_met":      met,
+		"checks":         checks,
+		"certificate_id": enrollment.CertificateID,
+	})
+}
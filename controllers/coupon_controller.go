@@ -0,0 +1,413 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// verifiedStudentDiscount is the share of the list price taken off for verified students
+const verifiedStudentDiscount = 0.20
+
+// Kinds of discount in a quote
+const (
+	discountVerified = "verified_student"
+	discountCoupon   = "coupon"
+)
+
+// quoteLine is one discount in a price breakdown
+type quoteLine struct {
+	Kind        string  `json:"kind"`
+	Code        string  `json:"code,omitempty"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+// paymentQuote is the server's price for a course: what POST /payments/quote shows and
+// CreatePayment charges
+type paymentQuote struct {
+	CourseID  uint        `json:"course_id"`
+	Currency  string      `json:"currency"`
+	ListPrice float64     `json:"list_price"`
+	Discounts []quoteLine `json:"discounts"`
+	Discount  float64     `json:"discount"`
+	Amount    float64     `json:"amount"`
+	Notes     []string    `json:"notes,omitempty"`
+
+	coupon *models.Coupon // applied coupon, redeemed when the payment is created
+}
+
+// couponError is a coupon the student cannot use; the message is shown to them
+type couponError string
+
+func (e couponError) Error() string { return string(e) }
+
+func roundMoney(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// couponDiscount → what the coupon takes off base, never more than base
+func couponDiscount(c models.Coupon, base float64) float64 {
+	d := c.Value
+	if c.Type == models.CouponPercent {
+		d = base * c.Value / 100
+		if c.MaxDiscount != nil && d > *c.MaxDiscount {
+			d = *c.MaxDiscount
+		}
+	}
+	return roundMoney(math.Min(d, base))
+}
+
+func couponLine(c models.Coupon, amount float64) quoteLine {
+	description := c.Description
+	if description == "" {
+		description = "Coupon " + c.Code
+	}
+	return quoteLine{Kind: discountCoupon, Code: c.Code, Description: description, Amount: amount}
+}
+
+// couponUses counts redemptions on payments that are pending or paid (failed and expired
+// attempts give the use back); userID narrows it to one student
+func couponUses(db *gorm.DB, couponID uint, userID *uint) (int64, error) {
+	query := db.Model(&models.CouponRedemption{}).
+		Joins("JOIN payments ON payments.id = coupon_redemptions.payment_id").
+		Where("coupon_redemptions.coupon_id = ? AND payments.status IN ?", couponID, []string{models.PaymentPending, models.PaymentSuccess})
+	if userID != nil {
+		query = query.Where("coupon_redemptions.user_id = ?", *userID)
+	}
+	var n int64
+	err := query.Count(&n).Error
+	return n, err
+}
+
+// checkCoupon → nil if the student may use the coupon on this course
+func checkCoupon(db *gorm.DB, c models.Coupon, userID uint, course models.Course) error {
+	now := time.Now()
+	switch {
+	case c.Disabled:
+		return couponError("coupon is no longer active")
+	case c.ValidFrom != nil && now.Before(*c.ValidFrom):
+		return couponError("coupon is not valid yet")
+	case c.ValidUntil != nil && now.After(*c.ValidUntil):
+		return couponError("coupon has expired")
+	case c.CourseID != nil && *c.CourseID != course.ID:
+		return couponError("coupon is not valid for this course")
+	case c.DepartmentID != nil && *c.DepartmentID != course.DepartmentID:
+		return couponError("coupon is not valid for this department")
+	case c.Type == models.CouponFixed && c.Currency != course.Currency:
+		return couponError(fmt.Sprintf("coupon is in %s but the course is priced in %s", c.Currency, course.Currency))
+	}
+
+	if c.MaxUses > 0 {
+		used, err := couponUses(db, c.ID, nil)
+		if err != nil {
+			return err
+		}
+		if used >= int64(c.MaxUses) {
+			return couponError("coupon usage limit reached")
+		}
+	}
+	if c.MaxUsesPerUser > 0 {
+		used, err := couponUses(db, c.ID, &userID)
+		if err != nil {
+			return err
+		}
+		if used >= int64(c.MaxUsesPerUser) {
+			return couponError("you have already used this coupon")
+		}
+	}
+	return nil
+}
+
+// quoteCourse prices a course for a student. The verified-student discount comes off the list
+// price; a stacking coupon then applies to what is left, otherwise the larger discount wins.
+// Pass a transaction holding the coupon row lock to re-check caps at checkout.
+func quoteCourse(db *gorm.DB, userID uint, course models.Course, couponCode string) (paymentQuote, error) {
+	quote := paymentQuote{CourseID: course.ID, Currency: course.Currency, ListPrice: course.Price, Discounts: []quoteLine{}}
+
+	var verified float64
+	var profile models.Profile
+	if err := db.Where("user_id = ?", userID).First(&profile).Error; err == nil && profile.Verified {
+		verified = roundMoney(course.Price * verifiedStudentDiscount)
+	}
+	verifiedLine := quoteLine{Kind: discountVerified, Description: "Verified student discount (20%)", Amount: verified}
+
+	var coupon *models.Coupon
+	if code := strings.ToUpper(strings.TrimSpace(couponCode)); code != "" {
+		var c models.Coupon
+		if err := db.Where("code = ?", code).First(&c).Error; err != nil {
+			return quote, couponError("coupon not found")
+		}
+		if err := checkCoupon(db, c, userID, course); err != nil {
+			return quote, err
+		}
+		coupon = &c
+	}
+
+	switch {
+	case coupon == nil:
+		if verified > 0 {
+			quote.Discounts = append(quote.Discounts, verifiedLine)
+		}
+	case verified > 0 && coupon.StacksWithVerified:
+		quote.Discounts = append(quote.Discounts, verifiedLine,
+			couponLine(*coupon, couponDiscount(*coupon, course.Price-verified)))
+		quote.coupon = coupon
+	case verified > 0:
+		if d := couponDiscount(*coupon, course.Price); d > verified {
+			quote.Discounts = append(quote.Discounts, couponLine(*coupon, d))
+			quote.coupon = coupon
+			quote.Notes = append(quote.Notes, "coupon does not combine with the verified student discount; the larger discount was applied")
+		} else {
+			quote.Discounts = append(quote.Discounts, verifiedLine)
+			quote.Notes = append(quote.Notes, "coupon does not combine with the verified student discount, which is larger; the coupon was not used")
+		}
+	default:
+		quote.Discounts = append(quote.Discounts, couponLine(*coupon, couponDiscount(*coupon, course.Price)))
+		quote.coupon = coupon
+	}
+
+	for _, line := range quote.Discounts {
+		quote.Discount += line.Amount
+	}
+	quote.Discount = roundMoney(math.Min(quote.Discount, quote.ListPrice))
+	quote.Amount = roundMoney(quote.ListPrice - quote.Discount)
+	return quote, nil
+}
+
+// QuotePayment → POST /payments/quote {course_id, coupon_code}
+// Shows the price breakdown CreatePayment would charge, without reserving the coupon.
+func QuotePayment(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+	var input struct {
+		CourseID   uint   `json:"course_id" binding:"required"`
+		CouponCode string `json:"coupon_code"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+
+	var course models.Course
+	if err := database.DB.First(&course, input.CourseID).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "course not found"})
+		return
+	}
+	if !course.RequiresPayment() {
+		ctx.JSON(400, gin.H{"error": "course is free", "details": "enroll directly with POST /enrollments"})
+		return
+	}
+
+	quote, err := quoteCourse(database.DB, userID, course, input.CouponCode)
+	if err != nil {
+		if reason, ok := err.(couponError); ok {
+			ctx.JSON(400, gin.H{"error": "coupon cannot be applied", "details": reason.Error()})
+			return
+		}
+		ctx.JSON(500, gin.H{"error": "failed to price course", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"quote": quote})
+}
+
+type couponInput struct {
+	Code               string     `json:"code" binding:"required"`
+	Description        string     `json:"description"`
+	Type               string     `json:"type" binding:"required,oneof=percent fixed"`
+	Value              float64    `json:"value" binding:"required,gt=0"`
+	MaxDiscount        *float64   `json:"max_discount"`
+	Currency           string     `json:"currency"`
+	ValidFrom          *time.Time `json:"valid_from"`
+	ValidUntil         *time.Time `json:"valid_until"`
+	MaxUses            int        `json:"max_uses" binding:"min=0"`
+	MaxUsesPerUser     int        `json:"max_uses_per_user" binding:"min=0"`
+	CourseID           *uint      `json:"course_id"`
+	DepartmentID       *uint      `json:"department_id"`
+	StacksWithVerified bool       `json:"stacks_with_verified"`
+	Disabled           bool       `json:"disabled"`
+}
+
+// apply copies the input onto a coupon, keeping its id
+func (in couponInput) apply(c *models.Coupon) {
+	c.Code = strings.ToUpper(strings.TrimSpace(in.Code))
+	c.Description = in.Description
+	c.Type, c.Value, c.MaxDiscount = in.Type, in.Value, in.MaxDiscount
+	c.Currency = strings.ToUpper(strings.TrimSpace(in.Currency))
+	if c.Type == models.CouponFixed && c.Currency == "" {
+		c.Currency = "INR"
+	}
+	c.ValidFrom, c.ValidUntil = in.ValidFrom, in.ValidUntil
+	c.MaxUses, c.MaxUsesPerUser = in.MaxUses, in.MaxUsesPerUser
+	c.CourseID, c.DepartmentID = in.CourseID, in.DepartmentID
+	c.StacksWithVerified, c.Disabled = in.StacksWithVerified, in.Disabled
+}
+
+// validateCoupon checks values, the validity window and that restrictions point somewhere
+func validateCoupon(c models.Coupon) error {
+	if len(c.Code) < 3 || strings.ContainsAny(c.Code, " \t") {
+		return fmt.Errorf("code must be at least 3 characters without spaces")
+	}
+	var taken int64
+	if err := database.DB.Model(&models.Coupon{}).Where("code = ? AND id <> ?", c.Code, c.ID).Count(&taken).Error; err != nil {
+		return err
+	}
+	if taken > 0 {
+		return fmt.Errorf("code %s is already in use", c.Code)
+	}
+
+	if c.Type == models.CouponPercent && c.Value > 100 {
+		return fmt.Errorf("percent value cannot exceed 100")
+	}
+	if c.MaxDiscount != nil && (c.Type != models.CouponPercent || *c.MaxDiscount <= 0) {
+		return fmt.Errorf("max_discount must be positive and only applies to percent coupons")
+	}
+	if c.Type == models.CouponFixed && len(c.Currency) != 3 {
+		return fmt.Errorf("currency must be a 3-letter ISO code")
+	}
+	if c.ValidFrom != nil && c.ValidUntil != nil && !c.ValidUntil.After(*c.ValidFrom) {
+		return fmt.Errorf("valid_until must be after valid_from")
+	}
+	if c.MaxUses > 0 && c.MaxUsesPerUser > c.MaxUses {
+		return fmt.Errorf("max_uses_per_user cannot exceed max_uses")
+	}
+
+	if c.CourseID != nil {
+		var course models.Course
+		if err := database.DB.First(&course, *c.CourseID).Error; err != nil {
+			return fmt.Errorf("course not found")
+		}
+		if c.DepartmentID != nil && *c.DepartmentID != course.DepartmentID {
+			return fmt.Errorf("course is not in the given department")
+		}
+	}
+	if c.DepartmentID != nil {
+		var dept models.Department
+		if err := database.DB.First(&dept, *c.DepartmentID).Error; err != nil {
+			return fmt.Errorf("department not found")
+		}
+	}
+	return nil
+}
+
+// CreateCoupon → POST /coupons (admin)
+func CreateCoupon(ctx *gin.Context) {
+	var input couponInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	var coupon models.Coupon
+	input.apply(&coupon)
+	if err := validateCoupon(coupon); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid coupon", "details": err.Error()})
+		return
+	}
+	coupon.CreatedBy, _ = getContextUserID(ctx)
+
+	if err := database.DB.Create(&coupon).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to create coupon", "details": err.Error()})
+		return
+	}
+	ctx.JSON(201, gin.H{"message": "coupon created successfully", "coupon": coupon})
+}
+
+// GetCoupons → GET /coupons?course_id=&department_id=&active=true (admin)
+func GetCoupons(ctx *gin.Context) {
+	query := database.DB.Order("id DESC")
+	if courseID := ctx.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if deptID := ctx.Query("department_id"); deptID != "" {
+		query = query.Where("department_id = ?", deptID)
+	}
+	if ctx.Query("active") == "true" {
+		now := time.Now()
+		query = query.Where("disabled = ? AND (valid_from IS NULL OR valid_from <= ?) AND (valid_until IS NULL OR valid_until >= ?)", false, now, now)
+	}
+
+	var coupons []models.Coupon
+	if err := query.Find(&coupons).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch coupons", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"coupons": coupons})
+}
+
+// GetCouponByID → GET /coupons/:id (admin), with usage so far
+func GetCouponByID(ctx *gin.Context) {
+	var coupon models.Coupon
+	if err := database.DB.First(&coupon, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "coupon not found"})
+		return
+	}
+	used, err := couponUses(database.DB, coupon.ID, nil)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to count redemptions", "details": err.Error()})
+		return
+	}
+	var redemptions []models.CouponRedemption
+	database.DB.Preload("Payment").Where("coupon_id = ?", coupon.ID).Order("id DESC").Limit(100).Find(&redemptions)
+
+	ctx.JSON(200, gin.H{"coupon": coupon, "uses": used, "redemptions": redemptions})
+}
+
+// UpdateCoupon → PUT /coupons/:id (admin)
+// Payments already made keep the discount they got.
+func UpdateCoupon(ctx *gin.Context) {
+	var coupon models.Coupon
+	if err := database.DB.First(&coupon, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "coupon not found"})
+		return
+	}
+
+	var input couponInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	input.apply(&coupon)
+	if err := validateCoupon(coupon); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid coupon", "details": err.Error()})
+		return
+	}
+
+	if err := database.DB.Save(&coupon).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to update coupon", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "coupon updated successfully", "coupon": coupon})
+}
+
+// DeleteCoupon → DELETE /coupons/:id (admin)
+// A coupon that has been redeemed is disabled instead, so payments keep their history.
+func DeleteCoupon(ctx *gin.Context) {
+	var coupon models.Coupon
+	if err := database.DB.First(&coupon, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "coupon not found"})
+		return
+	}
+
+	var redeemed int64
+	database.DB.Model(&models.CouponRedemption{}).Where("coupon_id = ?", coupon.ID).Count(&redeemed)
+	if redeemed > 0 {
+		if err := database.DB.Model(&coupon).Update("disabled", true).Error; err != nil {
+			ctx.JSON(500, gin.H{"error": "failed to disable coupon", "details": err.Error()})
+			return
+		}
+		ctx.JSON(200, gin.H{"message": "coupon has redemptions and was disabled instead of deleted"})
+		return
+	}
+
+	if err := database.DB.Delete(&coupon).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to delete coupon", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "coupon deleted successfully"})
+}
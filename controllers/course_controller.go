@@ -2,10 +2,13 @@ package controllers
 
 import (
 	"fmt"
+	"math"
+	"strings"
+
 	"github.com/ayushwar/major/database"
 	"github.com/ayushwar/major/models"
 	"github.com/gin-gonic/gin"
-	
+	"github.com/gin-gonic/gin/binding"
 )
 
 // helper: get user role from context
@@ -35,6 +38,25 @@ func getContextUserID(ctx *gin.Context) (uint, bool) {
 }
 
 
+// normalizeCoursePricing validates price and currency; a paid course with no price is made free
+func normalizeCoursePricing(course *models.Course) error {
+	course.Currency = strings.ToUpper(strings.TrimSpace(course.Currency))
+	if course.Currency == "" {
+		course.Currency = "INR"
+	}
+	if len(course.Currency) != 3 {
+		return fmt.Errorf("currency must be a 3-letter ISO code")
+	}
+	if course.Price < 0 {
+		return fmt.Errorf("price cannot be negative")
+	}
+	course.Price = math.Round(course.Price*100) / 100
+	if course.Price == 0 {
+		course.IsFree = true
+	}
+	return nil
+}
+
 // CreateCourse handles course creation with departmental authorization
 func CreateCourse(ctx *gin.Context) {
 	var course models.Course
@@ -48,6 +70,11 @@ func CreateCourse(ctx *gin.Context) {
 		return
 	}
 
+	if err := normalizeCoursePricing(&course); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid pricing", "details": err.Error()})
+		return
+	}
+
 	// 2. Assign TeacherID from JWT (this is the User ID)
 	teacherID, ok := getContextUserID(ctx)
 	if !ok {
@@ -195,7 +222,17 @@ func UpdateCourse(ctx *gin.Context) {
 
     // 4. Input Bind karna
     var input models.Course
-    if err := ctx.ShouldBindJSON(&input); err != nil {
+    if err := ctx.ShouldBindBodyWith(&input, binding.JSON); err != nil {
+        ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+        return
+    }
+    // Pricing fields are optional on update; pointers tell "not sent" from zero
+    var pricing struct {
+        Currency *string  `json:"currency"`
+        Price    *float64 `json:"price"`
+        IsFree   *bool    `json:"is_free"`
+    }
+    if err := ctx.ShouldBindBodyWith(&pricing, binding.JSON); err != nil {
         ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
         return
     }
@@ -250,7 +287,20 @@ func UpdateCourse(ctx *gin.Context) {
     if input.Credits > 0 {
         existingCourse.Credits = input.Credits
     }
-    // Update other fields as needed (e.g., Price, Duration, Level)
+    if pricing.Currency != nil {
+        existingCourse.Currency = *pricing.Currency
+    }
+    if pricing.Price != nil {
+        existingCourse.Price = *pricing.Price
+    }
+    if pricing.IsFree != nil {
+        existingCourse.IsFree = *pricing.IsFree
+    }
+    if err := normalizeCoursePricing(&existingCourse); err != nil {
+        ctx.JSON(400, gin.H{"error": "invalid pricing", "details": err.Error()})
+        return
+    }
+    // Update other fields as needed (e.g., Duration, Level)
     
     // 7. Database Save karna
     if err := database.DB.Save(&existingCourse).Error; err != nil {
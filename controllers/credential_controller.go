@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+)
+
+// Open Badges 3.0 documents. Only the fields we fill in are modelled.
+
+type obProfile struct {
+	Context            []string     `json:"@context,omitempty"`
+	ID                 string       `json:"id"`
+	Type               []string     `json:"type"`
+	Name               string       `json:"name"`
+	URL                string       `json:"url,omitempty"`
+	Description        string       `json:"description,omitempty"`
+	VerificationMethod []obMultikey `json:"verificationMethod,omitempty"`
+	AssertionMethod    []string     `json:"assertionMethod,omitempty"`
+}
+
+type obMultikey struct {
+	ID                 string `json:"id"`
+	Type               string `json:"type"`
+	Controller         string `json:"controller"`
+	PublicKeyMultibase string `json:"publicKeyMultibase"`
+}
+
+type obCriteria struct {
+	Narrative string `json:"narrative"`
+}
+
+type obAchievement struct {
+	ID               string     `json:"id"`
+	Type             []string   `json:"type"`
+	AchievementType  string     `json:"achievementType"`
+	Name             string     `json:"name"`
+	Description      string     `json:"description"`
+	Criteria         obCriteria `json:"criteria"`
+	HumanCode        string     `json:"humanCode,omitempty"`
+	FieldOfStudy     string     `json:"fieldOfStudy,omitempty"`
+	CreditsAvailable float64    `json:"creditsAvailable,omitempty"`
+	Creator          *obProfile `json:"creator,omitempty"`
+}
+
+type obIdentity struct {
+	Type         string `json:"type"`
+	IdentityHash string `json:"identityHash"`
+	IdentityType string `json:"identityType"`
+	Hashed       bool   `json:"hashed"`
+	Salt         string `json:"salt"`
+}
+
+type obSubject struct {
+	Type        []string      `json:"type"`
+	Identifier  []obIdentity  `json:"identifier"`
+	Achievement obAchievement `json:"achievement"`
+}
+
+type openBadgeCredential struct {
+	Context           []string                  `json:"@context"`
+	ID                string                    `json:"id"`
+	Type              []string                  `json:"type"`
+	Name              string                    `json:"name"`
+	Issuer            obProfile                 `json:"issuer"`
+	ValidFrom         string                    `json:"validFrom"`
+	CredentialSubject obSubject                 `json:"credentialSubject"`
+	Proof             *utils.DataIntegrityProof `json:"proof,omitempty"`
+}
+
+// issuerProfile → the platform as an Open Badges issuer; withKey adds the signing key for resolvers
+func issuerProfile(withKey bool) (obProfile, error) {
+	profile := obProfile{
+		ID:   utils.IssuerURL(),
+		Type: []string{"Profile"},
+		Name: utils.PlatformName(),
+		URL:  utils.PublicBaseURL(),
+	}
+	if !withKey {
+		return profile, nil
+	}
+
+	key, err := utils.SigningKey()
+	if err != nil {
+		return profile, err
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	method := utils.VerificationMethodURL(utils.SigningKeyID(pub))
+
+	profile.Context = utils.OpenBadgeContexts
+	profile.VerificationMethod = []obMultikey{{
+		ID:                 method,
+		Type:               "Multikey",
+		Controller:         profile.ID,
+		PublicKeyMultibase: utils.MultikeyPublic(pub),
+	}}
+	profile.AssertionMethod = []string{method}
+	return profile, nil
+}
+
+// courseAchievement → the achievement a course certificate stands for; the department is its creator
+func courseAchievement(course models.Course) obAchievement {
+	base := utils.PublicBaseURL()
+	description := course.Description
+	if description == "" {
+		description = fmt.Sprintf("Completion of the course %s (%s).", course.Title, course.Code)
+	}
+
+	achievement := obAchievement{
+		ID:               fmt.Sprintf("%s/courses/%d", base, course.ID),
+		Type:             []string{"Achievement"},
+		AchievementType:  "Course",
+		Name:             course.Title,
+		Description:      description,
+		Criteria:         obCriteria{Narrative: "Completed all required work for the course."},
+		HumanCode:        course.Code,
+		CreditsAvailable: float64(course.Credits),
+	}
+	if course.Department != nil {
+		achievement.FieldOfStudy = course.Department.Name
+		achievement.Creator = &obProfile{
+			ID:          fmt.Sprintf("%s/departments/%d", base, course.Department.ID),
+			Type:        []string{"Profile"},
+			Name:        course.Department.Name,
+			Description: course.Department.Description,
+		}
+	}
+	return achievement
+}
+
+// buildOpenBadgeCredential → signed OpenBadgeCredential for a certificate (User and Course.Department loaded)
+func buildOpenBadgeCredential(cert models.Certificate) (openBadgeCredential, error) {
+	issuer, err := issuerProfile(false)
+	if err != nil {
+		return openBadgeCredential{}, err
+	}
+
+	// The holder is identified by a salted hash of their email, not in the clear
+	email := strings.ToLower(strings.TrimSpace(cert.User.Email))
+	sum := sha256.Sum256([]byte(email + cert.CertCode))
+
+	credential := openBadgeCredential{
+		Context:   utils.OpenBadgeContexts,
+		ID:        utils.CertificateVerifyURL(cert.CertCode),
+		Type:      []string{"VerifiableCredential", "OpenBadgeCredential"},
+		Name:      cert.Course.Title,
+		Issuer:    issuer,
+		ValidFrom: cert.IssuedAt.UTC().Truncate(time.Second).Format(time.RFC3339),
+		CredentialSubject: obSubject{
+			Type: []string{"AchievementSubject"},
+			Identifier: []obIdentity{{
+				Type:         "IdentityObject",
+				IdentityHash: "sha256$" + hex.EncodeToString(sum[:]),
+				IdentityType: "emailAddress",
+				Hashed:       true,
+				Salt:         cert.CertCode,
+			}},
+			Achievement: courseAchievement(*cert.Course),
+		},
+	}
+
+	proof, err := utils.SignDataIntegrity(credential, credential.Context, cert.IssuedAt)
+	if err != nil {
+		return openBadgeCredential{}, err
+	}
+	credential.Proof = &proof
+	return credential, nil
+}
+
+// loadExportableCertificate fetches a certificate the caller may export; it writes the error response itself
+func loadExportableCertificate(ctx *gin.Context) (models.Certificate, bool) {
+	var cert models.Certificate
+	if err := database.DB.Preload("User").Preload("Course.Department").First(&cert, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "certificate not found"})
+		return cert, false
+	}
+
+	userID, _ := getContextUserID(ctx)
+	if cert.UserID != userID && !canManageCourse(ctx, cert.CourseID) {
+		ctx.JSON(403, gin.H{"error": "you can only export your own certificates"})
+		return cert, false
+	}
+	if cert.RevokedAt != nil {
+		ctx.JSON(410, gin.H{"error": "certificate has been revoked", "details": cert.RevokedReason})
+		return cert, false
+	}
+	if cert.SupersededAt != nil {
+		ctx.JSON(410, gin.H{"error": "certificate has been reissued, use its replacement", "superseded_by_id": cert.SupersededByID})
+		return cert, false
+	}
+	if cert.User == nil || cert.Course == nil {
+		ctx.JSON(500, gin.H{"error": "certificate is missing its holder or course"})
+		return cert, false
+	}
+	return cert, true
+}
+
+// GetCertificateCredential → GET /certificates/:id/credential
+// Open Badges 3.0 / W3C Verifiable Credential (JSON-LD) with an eddsa-jcs-2022 proof.
+func GetCertificateCredential(ctx *gin.Context) {
+	cert, ok := loadExportableCertificate(ctx)
+	if !ok {
+		return
+	}
+
+	credential, err := buildOpenBadgeCredential(cert)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to build credential", "details": err.Error()})
+		return
+	}
+	body, err := json.MarshalIndent(credential, "", "  ")
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to encode credential", "details": err.Error()})
+		return
+	}
+
+	if ctx.Query("download") == "true" {
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=credential-%s.json", cert.CertCode))
+	}
+	ctx.Data(200, "application/ld+json", body)
+}
+
+// GetCertificateBadge → GET /certificates/:id/badge.png
+// A badge image with the signed credential baked in, ready for e-portfolios and LinkedIn.
+func GetCertificateBadge(ctx *gin.Context) {
+	cert, ok := loadExportableCertificate(ctx)
+	if !ok {
+		return
+	}
+
+	credential, err := buildOpenBadgeCredential(cert)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to build credential", "details": err.Error()})
+		return
+	}
+	body, err := utils.CanonicalJSON(credential)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to encode credential", "details": err.Error()})
+		return
+	}
+
+	image, err := utils.RenderBadge(credential.ID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to render badge", "details": err.Error()})
+		return
+	}
+	baked, err := utils.BakePNG(image, body)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to bake badge", "details": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("inline; filename=badge-%s.png", cert.CertCode))
+	ctx.Data(200, "image/png", baked)
+}
+
+// GetCertificateIssuer → GET /certificates/issuer (public)
+// The issuer profile credentials point to, including the key their proofs verify against.
+func GetCertificateIssuer(ctx *gin.Context) {
+	profile, err := issuerProfile(true)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "signing key unavailable", "details": err.Error()})
+		return
+	}
+	body, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to encode issuer profile", "details": err.Error()})
+		return
+	}
+	ctx.Data(200, "application/ld+json", body)
+}
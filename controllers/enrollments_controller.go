@@ -7,8 +7,43 @@ import (
 	"github.com/ayushwar/major/database"
 	"github.com/ayushwar/major/models"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
+// enrollStudent creates an active enrollment unless the student already has one; created
+// reports whether this call made it
+func enrollStudent(tx *gorm.DB, userID, courseID uint) (models.Enrollment, bool, error) {
+	var enrollment models.Enrollment
+	if err := tx.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error; err == nil {
+		// Paying again after a refund restores access with progress intact
+		if enrollment.Status == models.EnrollmentSuspended {
+			status := models.EnrollmentActive
+			if enrollment.CompletedAt != nil {
+				status = models.EnrollmentCompleted
+			}
+			err := tx.Model(&enrollment).Update("status", status).Error
+			return enrollment, err == nil, err
+		}
+		return enrollment, false, nil
+	}
+
+	enrollment = models.Enrollment{
+		UserID:     userID,
+		CourseID:   courseID,
+		Progress:   0,
+		Status:     models.EnrollmentActive,
+		EnrolledAt: time.Now(),
+	}
+	if err := tx.Create(&enrollment).Error; err != nil {
+		// Lost a race with another request enrolling the same student
+		if tx.Where("user_id = ? AND course_id = ?", userID, courseID).First(&enrollment).Error == nil {
+			return enrollment, false, nil
+		}
+		return enrollment, false, err
+	}
+	return enrollment, true, nil
+}
+
 // -----------------------------
 // EnrollCourse → POST /enrollments
 // -----------------------------
@@ -41,22 +76,33 @@ func EnrollCourse(ctx *gin.Context) {
 
 	// Check if already enrolled
 	var existing models.Enrollment
-	if err := database.DB.Where("user_id = ? AND course_id = ?", userIDFromToken, input.CourseID).
+	if err := database.DB.Where("user_id = ? AND course_id = ? AND status <> ?", userIDFromToken, input.CourseID, models.EnrollmentSuspended).
 		First(&existing).Error; err == nil {
 		ctx.JSON(400, gin.H{"error": "already enrolled in this course"})
 		return
 	}
 
-	// Create enrollment
-	enrollment := models.Enrollment{
-		UserID:     userIDFromToken.(uint),
-		CourseID:   input.CourseID,
-		Progress:   0,
-		Status:     "active",
-		EnrolledAt: time.Now(),
+	// Paid courses need a settled payment first (the webhook then enrolls automatically)
+	if course.RequiresPayment() {
+		var paid int64
+		database.DB.Model(&models.Payment{}).
+			Where("user_id = ? AND course_id = ? AND status = ?", userIDFromToken, course.ID, models.PaymentSuccess).
+			Count(&paid)
+		if paid == 0 {
+			ctx.JSON(402, gin.H{
+				"error":     "payment required",
+				"details":   "create a payment with POST /payments and complete checkout to enroll",
+				"course_id": course.ID,
+				"price":     course.Price,
+				"currency":  course.Currency,
+			})
+			return
+		}
 	}
 
-	if err := database.DB.Create(&enrollment).Error; err != nil {
+	// Create enrollment
+	enrollment, _, err := enrollStudent(database.DB, userIDFromToken.(uint), course.ID)
+	if err != nil {
 		ctx.JSON(500, gin.H{"error": "failed to create enrollment", "details": err.Error()})
 		return
 	}
@@ -170,7 +216,16 @@ func UpdateEnrollment(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(200, gin.H{"message": "enrollment updated successfully", "enrollment": enrollment})
+	response := gin.H{"message": "enrollment updated successfully", "enrollment": enrollment}
+	if input.Progress != nil {
+		outcome, err := runCompletion(enrollment.ID)
+		if err != nil {
+			response["completion_error"] = err.Error()
+		} else {
+			response["completion"] = outcome
+		}
+	}
+	ctx.JSON(200, response)
 }
 
 // -----------------------------
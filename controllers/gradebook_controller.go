@@ -0,0 +1,697 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Gradebook cell statuses
+const (
+	gradeGraded     = "graded"
+	gradePending    = "pending_grading" // submitted, text answers still to be marked
+	gradeOverridden = "overridden"
+	gradeMissing    = "missing"     // not submitted and past the due date: counts as 0
+	gradeNotYetDue  = "not_yet_due" // not submitted, still open: not counted
+)
+
+// defaultGradeScale is used for courses that have not set their own
+var defaultGradeScale = []models.GradeScaleEntry{
+	{Letter: "A", MinPercent: 90},
+	{Letter: "B", MinPercent: 80},
+	{Letter: "C", MinPercent: 70},
+	{Letter: "D", MinPercent: 60},
+	{Letter: "F", MinPercent: 0},
+}
+
+type gradebookColumn struct {
+	AssignmentID uint       `json:"assignment_id"`
+	Title        string     `json:"title"`
+	CategoryID   *uint      `json:"category_id,omitempty"`
+	DueDate      *time.Time `json:"due_date,omitempty"`
+}
+
+type gradebookCell struct {
+	AssignmentID uint     `json:"assignment_id"`
+	Percent      *float64 `json:"percent"`
+	Status       string   `json:"status"`
+	Dropped      bool     `json:"dropped,omitempty"` // removed by the category's drop-lowest rule
+	SubmissionID *uint    `json:"submission_id,omitempty"`
+}
+
+type categoryGrade struct {
+	CategoryID uint     `json:"category_id"`
+	Name       string   `json:"name"`
+	Weight     float64  `json:"weight"`
+	Percent    *float64 `json:"percent"`
+}
+
+type gradebookRow struct {
+	UserID      uint            `json:"user_id"`
+	Name        string          `json:"name"`
+	Email       string          `json:"email"`
+	Assignments []gradebookCell `json:"assignments"`
+	Categories  []categoryGrade `json:"categories"`
+	Percent     *float64        `json:"percent"` // weighted final grade
+	Letter      string          `json:"letter,omitempty"`
+	Overridden  bool            `json:"overridden"`
+}
+
+type gradebook struct {
+	CourseID    uint                     `json:"course_id"`
+	Columns     []gradebookColumn        `json:"columns"`
+	Categories  []models.GradeCategory   `json:"categories"`
+	Scale       []models.GradeScaleEntry `json:"scale"`
+	Rows        []gradebookRow           `json:"rows"`
+	GeneratedAt time.Time                `json:"generated_at"`
+}
+
+// courseScale → the course's letter scale, highest first
+func courseScale(courseID uint) ([]models.GradeScaleEntry, error) {
+	var scale []models.GradeScaleEntry
+	if err := database.DB.Where("course_id = ?", courseID).Order("min_percent DESC").Find(&scale).Error; err != nil {
+		return nil, err
+	}
+	if len(scale) == 0 {
+		return defaultGradeScale, nil
+	}
+	return scale, nil
+}
+
+func letterFor(scale []models.GradeScaleEntry, percent float64) string {
+	for _, s := range scale {
+		if percent >= s.MinPercent {
+			return s.Letter
+		}
+	}
+	return ""
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+// buildGradebook aggregates every enrolled student's grades in a course.
+// onlyUser limits the rows to one student (the student view).
+//
+// Rules: each assignment uses the student's latest submission as a percentage (the blended
+// grade for peer-reviewed assignments); a missing submission counts 0 once the due date
+// has passed. A category averages its assignments after dropping the lowest N, and the
+// final grade is the weighted mean of the categories that have grades (weights are
+// rescaled). Assignments without a category only count when the course has no categories
+// at all. Overrides replace computed values.
+func buildGradebook(courseID uint, onlyUser *uint) (gradebook, error) {
+	book := gradebook{CourseID: courseID, Rows: []gradebookRow{}, GeneratedAt: time.Now()}
+
+	var assignments []models.Assignment
+	if err := database.DB.Where("course_id = ?", courseID).Order("id").Find(&assignments).Error; err != nil {
+		return book, err
+	}
+	for _, a := range assignments {
+		book.Columns = append(book.Columns, gradebookColumn{AssignmentID: a.ID, Title: a.Title, CategoryID: a.CategoryID, DueDate: a.DueDate})
+	}
+
+	if err := database.DB.Where("course_id = ?", courseID).Order("id").Find(&book.Categories).Error; err != nil {
+		return book, err
+	}
+	categories := book.Categories
+	if len(categories) == 0 {
+		categories = []models.GradeCategory{{Name: "All assignments", Weight: 100}}
+	}
+
+	scale, err := courseScale(courseID)
+	if err != nil {
+		return book, err
+	}
+	book.Scale = scale
+
+	enrollments := database.DB.Preload("User").Where("course_id = ?", courseID)
+	if onlyUser != nil {
+		enrollments = enrollments.Where("user_id = ?", *onlyUser)
+	} else {
+		// Students whose fee was refunded drop out of the class gradebook and its exports
+		enrollments = enrollments.Where("status IN ?", []string{models.EnrollmentActive, models.EnrollmentCompleted})
+	}
+	var enrolled []models.Enrollment
+	if err := enrollments.Order("user_id").Find(&enrolled).Error; err != nil {
+		return book, err
+	}
+	if len(enrolled) == 0 {
+		return book, nil
+	}
+	userIDs := make([]uint, 0, len(enrolled))
+	for _, e := range enrolled {
+		userIDs = append(userIDs, e.UserID)
+	}
+
+	// Latest submission per (student, assignment)
+	type key struct{ user, assignment uint }
+	latest := map[key]models.Submission{}
+	if len(assignments) > 0 {
+		assignmentIDs := make([]uint, 0, len(assignments))
+		for _, a := range assignments {
+			assignmentIDs = append(assignmentIDs, a.ID)
+		}
+		var submissions []models.Submission
+		if err := database.DB.Where("assignment_id IN ? AND user_id IN ?", assignmentIDs, userIDs).
+			Order("submitted_at DESC, id DESC").Find(&submissions).Error; err != nil {
+			return book, err
+		}
+		for _, s := range submissions {
+			k := key{s.UserID, s.AssignmentID}
+			if _, ok := latest[k]; !ok {
+				latest[k] = s
+			}
+		}
+	}
+
+	var overrides []models.GradeOverride
+	if err := database.DB.Where("course_id = ? AND user_id IN ?", courseID, userIDs).Find(&overrides).Error; err != nil {
+		return book, err
+	}
+	assignmentOverride := map[key]models.GradeOverride{}
+	finalOverride := map[uint]models.GradeOverride{}
+	for _, o := range overrides {
+		if o.AssignmentID == nil {
+			finalOverride[o.UserID] = o
+		} else {
+			assignmentOverride[key{o.UserID, *o.AssignmentID}] = o
+		}
+	}
+
+	now := time.Now()
+	for _, e := range enrolled {
+		row := gradebookRow{UserID: e.UserID}
+		if e.User != nil {
+			row.Name, row.Email = e.User.Name, e.User.Email
+		}
+
+		for _, a := range assignments {
+			cell := gradebookCell{AssignmentID: a.ID}
+			k := key{e.UserID, a.ID}
+			if o, ok := assignmentOverride[k]; ok {
+				p := o.Percent
+				cell.Percent, cell.Status = &p, gradeOverridden
+			} else if s, ok := latest[k]; ok {
+				p := 0.0
+				if s.FinalPercent != nil {
+					p = round2(*s.FinalPercent) // peer-reviewed: teacher and peer grades blended
+				} else if s.MaxScore > 0 {
+					p = round2(float64(s.Score) / float64(s.MaxScore) * 100)
+				}
+				id := s.ID
+				cell.Percent, cell.SubmissionID, cell.Status = &p, &id, gradeGraded
+				if s.NeedsGrading {
+					cell.Status = gradePending
+				}
+			} else if a.DueDate != nil && now.After(*a.DueDate) {
+				zero := 0.0
+				cell.Percent, cell.Status = &zero, gradeMissing
+			} else {
+				cell.Status = gradeNotYetDue
+			}
+			row.Assignments = append(row.Assignments, cell)
+		}
+
+		weighted, weights := 0.0, 0.0
+		for _, c := range categories {
+			grade := categoryGrade{CategoryID: c.ID, Name: c.Name, Weight: c.Weight}
+
+			var counted []int // indexes into row.Assignments
+			for i, a := range assignments {
+				inCategory := len(book.Categories) == 0 || (a.CategoryID != nil && *a.CategoryID == c.ID)
+				if inCategory && row.Assignments[i].Percent != nil {
+					counted = append(counted, i)
+				}
+			}
+			sort.SliceStable(counted, func(x, y int) bool {
+				return *row.Assignments[counted[x]].Percent < *row.Assignments[counted[y]].Percent
+			})
+			// Always keep at least one grade
+			drop := c.DropLowest
+			if drop > len(counted)-1 {
+				drop = len(counted) - 1
+			}
+			for n := 0; n < drop; n++ {
+				row.Assignments[counted[n]].Dropped = true
+			}
+
+			if len(counted) > 0 {
+				sum := 0.0
+				for _, i := range counted[drop:] {
+					sum += *row.Assignments[i].Percent
+				}
+				p := round2(sum / float64(len(counted)-drop))
+				grade.Percent = &p
+				weighted += p * c.Weight
+				weights += c.Weight
+			}
+			row.Categories = append(row.Categories, grade)
+		}
+
+		if weights > 0 {
+			p := round2(weighted / weights)
+			row.Percent = &p
+		}
+		if o, ok := finalOverride[e.UserID]; ok {
+			p := o.Percent
+			row.Percent, row.Overridden = &p, true
+		}
+		if row.Percent != nil {
+			row.Letter = letterFor(scale, *row.Percent)
+		}
+		book.Rows = append(book.Rows, row)
+	}
+
+	return book, nil
+}
+
+// courseForGradebook loads the course in :id and checks the caller may manage it
+func courseForGradebook(ctx *gin.Context) (models.Course, bool) {
+	var course models.Course
+	if err := database.DB.First(&course, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "course not found"})
+		return course, false
+	}
+	if !canManageCourse(ctx, course.ID) {
+		ctx.JSON(403, gin.H{"error": "you can only manage the gradebook of your own courses"})
+		return course, false
+	}
+	return course, true
+}
+
+// validCategory → categoryID is nil or a grade category of the course
+func validCategory(courseID uint, categoryID *uint) bool {
+	if categoryID == nil {
+		return true
+	}
+	var category models.GradeCategory
+	return database.DB.First(&category, *categoryID).Error == nil && category.CourseID == courseID
+}
+
+// GetGradebook → GET /courses/:id/gradebook
+func GetGradebook(ctx *gin.Context) {
+	course, ok := courseForGradebook(ctx)
+	if !ok {
+		return
+	}
+
+	book, err := buildGradebook(course.ID, nil)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to build gradebook", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"gradebook": book})
+}
+
+// GetMyGrades → GET /courses/:id/gradebook/me
+// A student's own row with the category breakdown; only for enrolled students.
+func GetMyGrades(ctx *gin.Context) {
+	userID, ok := getContextUserID(ctx)
+	if !ok {
+		ctx.JSON(401, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var enrollment models.Enrollment
+	if err := database.DB.Where("course_id = ? AND user_id = ?", ctx.Param("id"), userID).First(&enrollment).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "you are not enrolled in this course"})
+		return
+	}
+
+	book, err := buildGradebook(enrollment.CourseID, &userID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to build gradebook", "details": err.Error()})
+		return
+	}
+
+	response := gin.H{"columns": book.Columns, "categories": book.Categories, "scale": book.Scale}
+	if len(book.Rows) > 0 {
+		response["grades"] = book.Rows[0]
+	}
+	ctx.JSON(200, response)
+}
+
+// ExportGradebook → GET /courses/:id/gradebook/export?format=csv|xlsx
+// Lists active and completed students only.
+func ExportGradebook(ctx *gin.Context) {
+	course, ok := courseForGradebook(ctx)
+	if !ok {
+		return
+	}
+
+	book, err := buildGradebook(course.ID, nil)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to build gradebook", "details": err.Error()})
+		return
+	}
+
+	// Names and titles are typed by users, so they are escaped before a spreadsheet sees them
+	header := []interface{}{"Student ID", "Name", "Email"}
+	for _, c := range book.Columns {
+		header = append(header, utils.SpreadsheetText(c.Title))
+	}
+	for _, c := range book.Categories {
+		header = append(header, utils.SpreadsheetText(fmt.Sprintf("%s (%g%%)", c.Name, c.Weight)))
+	}
+	header = append(header, "Final %", "Letter")
+
+	rows := [][]interface{}{header}
+	for _, r := range book.Rows {
+		row := []interface{}{r.UserID, utils.SpreadsheetText(r.Name), utils.SpreadsheetText(r.Email)}
+		for _, a := range r.Assignments {
+			row = append(row, percentCell(a.Percent))
+		}
+		if len(book.Categories) > 0 {
+			for _, c := range r.Categories {
+				row = append(row, percentCell(c.Percent))
+			}
+		}
+		row = append(row, percentCell(r.Percent), utils.SpreadsheetText(r.Letter))
+		rows = append(rows, row)
+	}
+
+	baseName := fmt.Sprintf("gradebook_%s", strings.ReplaceAll(course.Code, " ", "_"))
+	switch strings.ToLower(ctx.DefaultQuery("format", "csv")) {
+	case "csv":
+		var buf bytes.Buffer
+		w := csv.NewWriter(&buf)
+		for _, row := range rows {
+			record := make([]string, len(row))
+			for i, cell := range row {
+				if cell != nil {
+					record[i] = fmt.Sprint(cell)
+				}
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			ctx.JSON(500, gin.H{"error": "failed to export gradebook", "details": err.Error()})
+			return
+		}
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", baseName))
+		ctx.Data(200, "text/csv", buf.Bytes())
+	case "xlsx":
+		body, err := utils.WriteXLSX(course.Title, rows)
+		if err != nil {
+			ctx.JSON(500, gin.H{"error": "failed to export gradebook", "details": err.Error()})
+			return
+		}
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.xlsx", baseName))
+		ctx.Data(200, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", body)
+	default:
+		ctx.JSON(400, gin.H{"error": "format must be csv or xlsx"})
+	}
+}
+
+func percentCell(p *float64) interface{} {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// ------------------------------------------------------------------
+//						categories, overrides, scale
+// ------------------------------------------------------------------
+
+type gradeCategoryInput struct {
+	Name       string  `json:"name" binding:"required"`
+	Weight     float64 `json:"weight" binding:"min=0,max=100"`
+	DropLowest int     `json:"drop_lowest" binding:"min=0"`
+}
+
+// checkCategoryWeights → the course's weights may not add up to more than 100%
+func checkCategoryWeights(courseID, excludeID uint, weight float64) error {
+	var total float64
+	if err := database.DB.Model(&models.GradeCategory{}).Select("COALESCE(SUM(weight), 0)").
+		Where("course_id = ? AND id <> ?", courseID, excludeID).Scan(&total).Error; err != nil {
+		return err
+	}
+	if total+weight > 100 {
+		return fmt.Errorf("category weights would total %g%%, the maximum is 100%%", total+weight)
+	}
+	return nil
+}
+
+// GetGradeCategories → GET /courses/:id/grade-categories
+func GetGradeCategories(ctx *gin.Context) {
+	course, ok := courseForGradebook(ctx)
+	if !ok {
+		return
+	}
+
+	var categories []models.GradeCategory
+	if err := database.DB.Where("course_id = ?", course.ID).Order("id").Find(&categories).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch categories", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"categories": categories})
+}
+
+// CreateGradeCategory → POST /courses/:id/grade-categories
+func CreateGradeCategory(ctx *gin.Context) {
+	course, ok := courseForGradebook(ctx)
+	if !ok {
+		return
+	}
+
+	var input gradeCategoryInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	if err := checkCategoryWeights(course.ID, 0, input.Weight); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	category := models.GradeCategory{CourseID: course.ID, Name: input.Name, Weight: input.Weight, DropLowest: input.DropLowest}
+	if err := database.DB.Create(&category).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to create category", "details": err.Error()})
+		return
+	}
+	ctx.JSON(201, gin.H{"message": "category created successfully", "category": category})
+}
+
+// UpdateGradeCategory → PUT /courses/:id/grade-categories/:category_id
+func UpdateGradeCategory(ctx *gin.Context) {
+	course, ok := courseForGradebook(ctx)
+	if !ok {
+		return
+	}
+
+	var category models.GradeCategory
+	if err := database.DB.Where("course_id = ?", course.ID).First(&category, ctx.Param("category_id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "category not found"})
+		return
+	}
+
+	var input gradeCategoryInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	if err := checkCategoryWeights(course.ID, category.ID, input.Weight); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	category.Name, category.Weight, category.DropLowest = input.Name, input.Weight, input.DropLowest
+	if err := database.DB.Save(&category).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to update category", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "category updated successfully", "category": category})
+}
+
+// DeleteGradeCategory → DELETE /courses/:id/grade-categories/:category_id
+// Its assignments become uncategorised.
+func DeleteGradeCategory(ctx *gin.Context) {
+	course, ok := courseForGradebook(ctx)
+	if !ok {
+		return
+	}
+
+	var category models.GradeCategory
+	if err := database.DB.Where("course_id = ?", course.ID).First(&category, ctx.Param("category_id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "category not found"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Assignment{}).Where("category_id = ?", category.ID).Update("category_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to delete category", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "category deleted successfully"})
+}
+
+// SetGradeOverride → POST /courses/:id/gradebook/overrides
+// Without assignment_id the student's final course grade is overridden.
+func SetGradeOverride(ctx *gin.Context) {
+	course, ok := courseForGradebook(ctx)
+	if !ok {
+		return
+	}
+	teacherID, _ := getContextUserID(ctx)
+
+	var input struct {
+		UserID       uint    `json:"user_id" binding:"required"`
+		AssignmentID *uint   `json:"assignment_id"`
+		Percent      float64 `json:"percent" binding:"min=0,max=100"`
+		Reason       string  `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	var enrollment models.Enrollment
+	if err := database.DB.Where("course_id = ? AND user_id = ?", course.ID, input.UserID).First(&enrollment).Error; err != nil {
+		ctx.JSON(400, gin.H{"error": "student is not enrolled in this course"})
+		return
+	}
+	if input.AssignmentID != nil {
+		var assignment models.Assignment
+		if err := database.DB.First(&assignment, *input.AssignmentID).Error; err != nil || assignment.CourseID != course.ID {
+			ctx.JSON(400, gin.H{"error": "assignment_id must be an assignment of this course"})
+			return
+		}
+	}
+
+	var override models.GradeOverride
+	query := database.DB.Where("course_id = ? AND user_id = ?", course.ID, input.UserID)
+	if input.AssignmentID == nil {
+		query = query.Where("assignment_id IS NULL")
+	} else {
+		query = query.Where("assignment_id = ?", *input.AssignmentID)
+	}
+	if err := query.First(&override).Error; err != nil && err != gorm.ErrRecordNotFound {
+		ctx.JSON(500, gin.H{"error": "failed to save override", "details": err.Error()})
+		return
+	}
+
+	override.CourseID, override.UserID, override.AssignmentID = course.ID, input.UserID, input.AssignmentID
+	override.Percent, override.Reason, override.OverriddenBy = input.Percent, input.Reason, teacherID
+	if err := database.DB.Save(&override).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to save override", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "grade overridden", "override": override})
+}
+
+// GetGradeOverrides → GET /courses/:id/gradebook/overrides
+func GetGradeOverrides(ctx *gin.Context) {
+	course, ok := courseForGradebook(ctx)
+	if !ok {
+		return
+	}
+
+	var overrides []models.GradeOverride
+	if err := database.DB.Where("course_id = ?", course.ID).Order("user_id, id").Find(&overrides).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch overrides", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"overrides": overrides})
+}
+
+// DeleteGradeOverride → DELETE /courses/:id/gradebook/overrides/:override_id
+func DeleteGradeOverride(ctx *gin.Context) {
+	course, ok := courseForGradebook(ctx)
+	if !ok {
+		return
+	}
+
+	res := database.DB.Where("course_id = ?", course.ID).Delete(&models.GradeOverride{}, ctx.Param("override_id"))
+	if res.Error != nil {
+		ctx.JSON(500, gin.H{"error": "failed to delete override", "details": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		ctx.JSON(404, gin.H{"error": "override not found"})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "override removed"})
+}
+
+// GetGradeScale → GET /courses/:id/grade-scale
+func GetGradeScale(ctx *gin.Context) {
+	var course models.Course
+	if err := database.DB.First(&course, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "course not found"})
+		return
+	}
+
+	scale, err := courseScale(course.ID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch grade scale", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"scale": scale})
+}
+
+// SetGradeScale → PUT /courses/:id/grade-scale
+// Replaces the whole scale; it must contain a letter for 0%.
+func SetGradeScale(ctx *gin.Context) {
+	course, ok := courseForGradebook(ctx)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Scale []struct {
+			Letter     string  `json:"letter" binding:"required"`
+			MinPercent float64 `json:"min_percent" binding:"min=0,max=100"`
+		} `json:"scale" binding:"required,min=1,dive"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	seen := map[float64]bool{}
+	entries := make([]models.GradeScaleEntry, 0, len(input.Scale))
+	for _, s := range input.Scale {
+		if seen[s.MinPercent] {
+			ctx.JSON(400, gin.H{"error": fmt.Sprintf("min_percent %g is used twice", s.MinPercent)})
+			return
+		}
+		seen[s.MinPercent] = true
+		entries = append(entries, models.GradeScaleEntry{CourseID: course.ID, Letter: s.Letter, MinPercent: s.MinPercent})
+	}
+	if !seen[0] {
+		ctx.JSON(400, gin.H{"error": "the scale needs a letter with min_percent 0"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("course_id = ?", course.ID).Delete(&models.GradeScaleEntry{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&entries).Error
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to save grade scale", "details": err.Error()})
+		return
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].MinPercent > entries[j].MinPercent })
+	ctx.JSON(200, gin.H{"message": "grade scale saved", "scale": entries})
+}
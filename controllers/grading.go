@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// questionPoints → points a question is worth (older rows default to 1)
+func questionPoints(q models.Question) int {
+	if q.Points <= 0 {
+		return 1
+	}
+	return q.Points
+}
+
+// gradeAnswer auto-grades one answer against the current answer key.
+// MCQ answers are right only when exactly the correct options were picked;
+// text answers are left at 0 points and flagged for manual grading.
+func gradeAnswer(q models.Question, selected []uint, text string) models.SubmissionAnswer {
+	answer := models.SubmissionAnswer{
+		QuestionID:        q.ID,
+		SelectedOptionIDs: selected,
+		TextAnswer:        strings.TrimSpace(text),
+		MaxPoints:         questionPoints(q),
+		GradingMode:       models.GradingAuto,
+	}
+
+	if q.Type == models.QuestionTypeText {
+		answer.NeedsGrading = answer.TextAnswer != ""
+		return answer
+	}
+
+	correct := map[uint]bool{}
+	for _, opt := range q.Options {
+		if opt.IsCorrect {
+			correct[opt.ID] = true
+		}
+	}
+	picked := map[uint]bool{}
+	for _, id := range selected {
+		picked[id] = true
+	}
+
+	answer.IsCorrect = len(correct) > 0 && len(picked) == len(correct)
+	for id := range picked {
+		if !correct[id] {
+			answer.IsCorrect = false
+		}
+	}
+	if answer.IsCorrect {
+		answer.AutoPoints = answer.MaxPoints
+		answer.PointsAwarded = answer.MaxPoints
+	}
+	return answer
+}
+
+// recalculateSubmission re-sums a submission's score from its stored answers
+func recalculateSubmission(tx *gorm.DB, submissionID uint) (models.Submission, error) {
+	var submission models.Submission
+	if err := tx.Preload("Answers").First(&submission, submissionID).Error; err != nil {
+		return submission, err
+	}
+
+	score, maxScore, needsGrading := 0, 0, false
+	for _, a := range submission.Answers {
+		score += a.PointsAwarded
+		maxScore += a.MaxPoints
+		needsGrading = needsGrading || a.NeedsGrading
+	}
+
+	// An assignment-rubric assessment counts as one more graded item; until the teacher
+	// has filled it in, the score is provisional
+	var assessment models.RubricAssessment
+	if err := tx.Where("submission_id = ? AND answer_id IS NULL", submissionID).First(&assessment).Error; err == nil {
+		score += assessment.Score
+		maxScore += assessment.MaxScore
+	} else if err != gorm.ErrRecordNotFound {
+		return submission, err
+	} else {
+		var assignment models.Assignment
+		if err := tx.Select("id", "rubric_id").First(&assignment, submission.AssignmentID).Error; err != nil {
+			return submission, err
+		}
+		needsGrading = needsGrading || assignment.RubricID != nil
+	}
+
+	submission.Score, submission.MaxScore, submission.NeedsGrading = score, maxScore, needsGrading
+	err := tx.Model(&submission).Updates(map[string]interface{}{
+		"score":         score,
+		"max_score":     maxScore,
+		"needs_grading": needsGrading,
+	}).Error
+	if err == nil {
+		// Scores changed, so cached analytics for the assignment are stale
+		err = tx.Where("assignment_id = ?", submission.AssignmentID).Delete(&models.AssignmentStats{}).Error
+	}
+	if err == nil {
+		err = blendPeerGrade(tx, &submission)
+	}
+	return submission, err
+}
+
+// latestSubmissions → each student's most recent submission for an assignment, plus the total
+// number of submissions. withAnswers preloads the per-answer rows.
+func latestSubmissions(assignmentID uint, withAnswers bool) ([]models.Submission, int, error) {
+	query := database.DB.Where("assignment_id = ?", assignmentID)
+	if withAnswers {
+		query = query.Preload("Answers")
+	}
+	var submissions []models.Submission
+	if err := query.Order("submitted_at DESC, id DESC").Find(&submissions).Error; err != nil {
+		return nil, 0, err
+	}
+
+	seen := map[uint]bool{}
+	latest := make([]models.Submission, 0, len(submissions))
+	for _, s := range submissions {
+		if !seen[s.UserID] {
+			seen[s.UserID] = true
+			latest = append(latest, s)
+		}
+	}
+	return latest, len(submissions), nil
+}
+
+// canGradeAssignment → admin, or the teacher who owns the assignment's course
+func canGradeAssignment(ctx *gin.Context, assignment models.Assignment) bool {
+	return canManageCourse(ctx, assignment.CourseID)
+}
+
+// canManageCourse → admin, or the teacher who owns the course
+func canManageCourse(ctx *gin.Context, courseID uint) bool {
+	role := getUserRole(ctx)
+	if role == "admin" {
+		return true
+	}
+	if role != "teacher" {
+		return false
+	}
+
+	userID, _ := getContextUserID(ctx)
+	var course models.Course
+	if err := database.DB.First(&course, courseID).Error; err != nil {
+		return false
+	}
+	return course.TeacherID == userID
+}
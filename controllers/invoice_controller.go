@@ -0,0 +1,384 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var stateCodePattern = regexp.MustCompile(`^[0-9]{2}$`)
+
+// nextInvoiceNumber bumps the financial year's counter under a row lock; the caller's
+// transaction makes the number and the invoice commit (or roll back) together
+func nextInvoiceNumber(tx *gorm.DB, financialYear string) (int, error) {
+	// Make sure the year's row exists first. A concurrent first invoice inserts the same row;
+	// the loser waits on the unique index and then does nothing, instead of failing the payment.
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.InvoiceSequence{FinancialYear: financialYear}).Error; err != nil {
+		return 0, err
+	}
+
+	var seq models.InvoiceSequence
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("financial_year = ?", financialYear).First(&seq).Error; err != nil {
+		return 0, err
+	}
+	seq.LastNumber++
+	return seq.LastNumber, tx.Model(&seq).Update("last_number", seq.LastNumber).Error
+}
+
+// createInvoice issues the invoice for a settled payment inside the settling transaction.
+// Fully discounted checkouts get none.
+func createInvoice(tx *gorm.DB, payment models.Payment) (*models.Invoice, error) {
+	if payment.Amount <= 0 {
+		return nil, nil
+	}
+	var existing int64
+	if err := tx.Model(&models.Invoice{}).Where("payment_id = ?", payment.ID).Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := tx.First(&user, payment.UserID).Error; err != nil {
+		return nil, err
+	}
+	var course models.Course
+	if err := tx.First(&course, payment.CourseID).Error; err != nil {
+		return nil, err
+	}
+	var billing models.BillingDetails
+	tx.Where("user_id = ?", user.ID).First(&billing)
+
+	seller := utils.InvoiceSeller()
+	rate := utils.GSTRate(seller)
+	place := billing.StateCode
+	if place == "" && len(billing.GSTIN) >= 2 {
+		place = billing.GSTIN[:2]
+	}
+	if place == "" {
+		place = seller.StateCode
+	}
+	split := utils.SplitGST(payment.Amount, rate, place == seller.StateCode)
+
+	issued := time.Now()
+	if payment.PaidAt != nil {
+		issued = *payment.PaidAt
+	}
+	fy := utils.FinancialYear(issued)
+	seq, err := nextInvoiceNumber(tx, fy)
+	if err != nil {
+		return nil, err
+	}
+
+	invoice := models.Invoice{
+		Number:          fmt.Sprintf("%s/%s/%05d", seller.Prefix, fy, seq),
+		FinancialYear:   fy,
+		Sequence:        seq,
+		PaymentID:       payment.ID,
+		UserID:          user.ID,
+		CourseID:        course.ID,
+		SellerName:      seller.LegalName,
+		SellerAddress:   seller.Address,
+		SellerGSTIN:     seller.GSTIN,
+		SellerStateCode: seller.StateCode,
+		BuyerName:       user.Name,
+		BuyerEmail:      user.Email,
+		BuyerAddress:    billing.Address,
+		BuyerGSTIN:      billing.GSTIN,
+		PlaceOfSupply:   place,
+		TaxInvoice:      seller.GSTIN != "",
+		Currency:        payment.Currency,
+		Taxable:         split.Taxable,
+		CGST:            split.CGST,
+		SGST:            split.SGST,
+		IGST:            split.IGST,
+		Total:           payment.Amount,
+		IssuedAt:        issued,
+		Lines: []models.InvoiceLine{{
+			Description: fmt.Sprintf("%s (%s)", course.Title, course.Code),
+			SAC:         utils.SACEducation,
+			Quantity:    1,
+			ListPrice:   roundMoney(payment.Amount + payment.DiscountApplied),
+			Discount:    payment.DiscountApplied,
+			Taxable:     split.Taxable,
+			TaxRate:     rate,
+			CGST:        split.CGST,
+			SGST:        split.SGST,
+			IGST:        split.IGST,
+			Total:       payment.Amount,
+		}},
+	}
+	if billing.Name != "" {
+		invoice.BuyerName = billing.Name
+	}
+	if err := tx.Create(&invoice).Error; err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// renderInvoicePDF draws an A4 invoice (or receipt when the seller is not GST registered)
+func renderInvoicePDF(inv models.Invoice, w io.Writer) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+	money := func(v float64) string { return fmt.Sprintf("%.2f", v) }
+
+	title := "RECEIPT"
+	if inv.TaxInvoice {
+		title = "TAX INVOICE"
+	}
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.CellFormat(0, 10, title, "", 1, "R", false, 0, "")
+
+	// Seller on the left, invoice details on the right
+	top := pdf.GetY()
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.MultiCell(100, 6, tr(inv.SellerName), "", "L", false)
+	pdf.SetFont("Helvetica", "", 9)
+	if inv.SellerAddress != "" {
+		pdf.MultiCell(100, 4.5, tr(inv.SellerAddress), "", "L", false)
+	}
+	if inv.SellerGSTIN != "" {
+		pdf.CellFormat(100, 4.5, "GSTIN: "+inv.SellerGSTIN, "", 1, "L", false, 0, "")
+	}
+	left := pdf.GetY()
+
+	pdf.SetXY(120, top)
+	for _, kv := range [][2]string{
+		{"Invoice no.", inv.Number},
+		{"Date", inv.IssuedAt.Format("02 Jan 2006")},
+		{"Financial year", inv.FinancialYear},
+		{"Place of supply", inv.PlaceOfSupply},
+	} {
+		if kv[1] == "" {
+			continue
+		}
+		pdf.SetX(120)
+		pdf.SetFont("Helvetica", "B", 9)
+		pdf.CellFormat(30, 5, kv[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 9)
+		pdf.CellFormat(45, 5, kv[1], "", 1, "L", false, 0, "")
+	}
+	if pdf.GetY() < left {
+		pdf.SetY(left)
+	}
+
+	// Buyer
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(0, 6, "Bill to", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 9)
+	pdf.CellFormat(0, 4.5, tr(inv.BuyerName), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 4.5, inv.BuyerEmail, "", 1, "L", false, 0, "")
+	if inv.BuyerAddress != "" {
+		pdf.MultiCell(100, 4.5, tr(inv.BuyerAddress), "", "L", false)
+	}
+	if inv.BuyerGSTIN != "" {
+		pdf.CellFormat(0, 4.5, "GSTIN: "+inv.BuyerGSTIN, "", 1, "L", false, 0, "")
+	}
+
+	// Lines
+	pdf.Ln(6)
+	widths := []float64{8, 62, 18, 10, 22, 20, 20, 20}
+	headers := []string{"#", "Description", "SAC", "Qty", "List price", "Discount", "Taxable", "Total"}
+	pdf.SetFont("Helvetica", "B", 8.5)
+	pdf.SetFillColor(235, 238, 242)
+	for i, h := range headers {
+		align := "R"
+		if i == 1 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 7, h, "1", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 8.5)
+	for n, line := range inv.Lines {
+		cells := []string{fmt.Sprint(n + 1), tr(line.Description), line.SAC, fmt.Sprint(line.Quantity),
+			money(line.ListPrice), money(line.Discount), money(line.Taxable), money(line.Total)}
+		for i, c := range cells {
+			align := "R"
+			if i == 1 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 7, c, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	// Totals
+	pdf.Ln(3)
+	rate := 0.0
+	if len(inv.Lines) > 0 {
+		rate = inv.Lines[0].TaxRate
+	}
+	totals := [][2]string{{"Taxable value", money(inv.Taxable)}}
+	switch {
+	case inv.IGST > 0:
+		totals = append(totals, [2]string{fmt.Sprintf("IGST @ %g%%", rate), money(inv.IGST)})
+	case inv.CGST > 0 || inv.SGST > 0:
+		totals = append(totals,
+			[2]string{fmt.Sprintf("CGST @ %g%%", rate/2), money(inv.CGST)},
+			[2]string{fmt.Sprintf("SGST @ %g%%", rate/2), money(inv.SGST)})
+	}
+	totals = append(totals, [2]string{"Total (" + inv.Currency + ")", money(inv.Total)})
+	for i, t := range totals {
+		style := ""
+		if i == len(totals)-1 {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 9)
+		pdf.SetX(115)
+		pdf.CellFormat(45, 6, t[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(20, 6, t[1], "", 1, "R", false, 0, "")
+	}
+
+	pdf.Ln(8)
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.SetTextColor(90, 90, 90)
+	if inv.Payment != nil {
+		ref := "Payment reference: " + inv.Payment.TransactionID
+		if inv.Payment.GatewayPaymentID != "" {
+			ref += " / " + inv.Payment.GatewayPaymentID
+		}
+		pdf.CellFormat(0, 4.5, ref, "", 1, "L", false, 0, "")
+	}
+	if inv.TaxInvoice {
+		pdf.CellFormat(0, 4.5, "Prices are inclusive of GST. This is a computer-generated invoice and needs no signature.", "", 1, "L", false, 0, "")
+	} else {
+		pdf.CellFormat(0, 4.5, "This is a computer-generated receipt and needs no signature.", "", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// invoiceFilename → INV/2026-27/00001 as INV-2026-27-00001.pdf
+func invoiceFilename(inv models.Invoice) string {
+	return strings.ReplaceAll(inv.Number, "/", "-") + ".pdf"
+}
+
+// emailInvoice sends the buyer the invoice for a payment, once; failures are logged, not retried
+func emailInvoice(paymentID uint) {
+	var inv models.Invoice
+	if err := database.DB.Preload("Lines").Preload("Payment").Where("payment_id = ?", paymentID).First(&inv).Error; err != nil {
+		return // free checkout, nothing to send
+	}
+	if inv.EmailedAt != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := renderInvoicePDF(inv, &buf); err != nil {
+		fmt.Println("ERROR: invoice email: failed to render", inv.Number, ":", err)
+		return
+	}
+	subject := "Your invoice " + inv.Number
+	body := fmt.Sprintf("Hello %s,\n\nThank you for your payment of %s %.2f. Your invoice %s is attached.\n"+
+		"You can download your invoices any time from your account.\n", inv.BuyerName, inv.Currency, inv.Total, inv.Number)
+	if err := utils.SendEmailWithAttachment(inv.BuyerEmail, subject, body, invoiceFilename(inv), "application/pdf", buf.Bytes()); err != nil {
+		fmt.Println("ERROR: failed to send invoice", inv.Number, "to", inv.BuyerEmail, ":", err)
+		return
+	}
+	database.DB.Model(&inv).Update("emailed_at", time.Now())
+}
+
+// GetMyInvoices → GET /users/me/invoices
+func GetMyInvoices(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+	var invoices []models.Invoice
+	if err := database.DB.Preload("Lines").Where("user_id = ?", userID).Order("issued_at DESC, id DESC").Find(&invoices).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch invoices", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"invoices": invoices})
+}
+
+// DownloadMyInvoice → GET /users/me/invoices/:id/pdf (owner; admins may fetch any)
+func DownloadMyInvoice(ctx *gin.Context) {
+	var inv models.Invoice
+	if err := database.DB.Preload("Lines").Preload("Payment").First(&inv, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "invoice not found"})
+		return
+	}
+	userID, _ := getContextUserID(ctx)
+	if inv.UserID != userID && getUserRole(ctx) != "admin" {
+		ctx.JSON(404, gin.H{"error": "invoice not found"})
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := renderInvoicePDF(inv, &buf); err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to render invoice", "details": err.Error()})
+		return
+	}
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, invoiceFilename(inv)))
+	ctx.Data(200, "application/pdf", buf.Bytes())
+}
+
+// GetBillingDetails → GET /users/me/billing
+func GetBillingDetails(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+	var billing models.BillingDetails
+	if err := database.DB.Where("user_id = ?", userID).First(&billing).Error; err != nil {
+		billing = models.BillingDetails{UserID: userID}
+	}
+	ctx.JSON(200, gin.H{"billing": billing})
+}
+
+// UpdateBillingDetails → PUT /users/me/billing {name, address, gstin, state_code}
+// Applies to invoices issued from now on.
+func UpdateBillingDetails(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+	var input struct {
+		Name      string `json:"name"`
+		Address   string `json:"address"`
+		GSTIN     string `json:"gstin"`
+		StateCode string `json:"state_code"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	input.GSTIN = strings.ToUpper(strings.TrimSpace(input.GSTIN))
+	input.StateCode = strings.TrimSpace(input.StateCode)
+	if input.GSTIN != "" && !utils.ValidGSTIN(input.GSTIN) {
+		ctx.JSON(400, gin.H{"error": "invalid GSTIN"})
+		return
+	}
+	if input.StateCode == "" && input.GSTIN != "" {
+		input.StateCode = input.GSTIN[:2]
+	}
+	if input.StateCode != "" && !stateCodePattern.MatchString(input.StateCode) {
+		ctx.JSON(400, gin.H{"error": "state_code must be the two-digit GST state code"})
+		return
+	}
+	if input.GSTIN != "" && input.GSTIN[:2] != input.StateCode {
+		ctx.JSON(400, gin.H{"error": "state_code does not match the GSTIN"})
+		return
+	}
+
+	var billing models.BillingDetails
+	database.DB.Where("user_id = ?", userID).First(&billing)
+	billing.UserID = userID
+	billing.Name, billing.Address, billing.GSTIN, billing.StateCode = input.Name, input.Address, input.GSTIN, input.StateCode
+	if err := database.DB.Save(&billing).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to save billing details", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "billing details saved", "billing": billing})
+}
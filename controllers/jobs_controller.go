@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/gin-gonic/gin"
+)
+
+// jobFunc does the work of a background job. It may update job.Total / call jobProgress
+// as it goes and returns a short summary that is stored in Job.Result.
+type jobFunc func(job *models.Job) (string, error)
+
+// startJob records a queued Job and runs fn in a goroutine
+func startJob(jobType string, createdBy uint, fn jobFunc) (models.Job, error) {
+	job := models.Job{Type: jobType, Status: models.JobQueued, CreatedBy: createdBy}
+	if err := database.DB.Create(&job).Error; err != nil {
+		return job, err
+	}
+
+	go runJob(job, fn)
+	return job, nil
+}
+
+func runJob(job models.Job, fn jobFunc) {
+	started := time.Now()
+	job.Status, job.StartedAt = models.JobRunning, &started
+	database.DB.Model(&job).Updates(map[string]interface{}{"status": job.Status, "started_at": started})
+
+	var result string
+	var err error
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("job panicked: %v", r)
+			}
+		}()
+		result, err = fn(&job)
+	}()
+
+	updates := map[string]interface{}{
+		"status":      models.JobCompleted,
+		"result":      result,
+		"total":       job.Total,
+		"processed":   job.Processed,
+		"finished_at": time.Now(),
+	}
+	if err != nil {
+		updates["status"] = models.JobFailed
+		updates["error"] = err.Error()
+		fmt.Println("ERROR: job", job.ID, job.Type, "failed:", err)
+	}
+	database.DB.Model(&job).Updates(updates)
+}
+
+// jobProgress persists how many items a running job has handled
+func jobProgress(job *models.Job, processed int) {
+	job.Processed = processed
+	database.DB.Model(job).Update("processed", processed)
+}
+
+// jobArtifact records the file a job produced so its creator can download it
+func jobArtifact(job *models.Job, path, name string) error {
+	job.ArtifactPath, job.ArtifactName = path, name
+	return database.DB.Model(job).Updates(map[string]interface{}{"artifact_path": path, "artifact_name": name}).Error
+}
+
+// GetJob → GET /jobs/:id
+// The user who started the job, or an admin, can poll its status.
+func GetJob(ctx *gin.Context) {
+	userID, ok := getContextUserID(ctx)
+	if !ok {
+		ctx.JSON(401, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var job models.Job
+	if err := database.DB.First(&job, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "job not found"})
+		return
+	}
+	if job.CreatedBy != userID && getUserRole(ctx) != "admin" {
+		ctx.JSON(403, gin.H{"error": "you can only view your own jobs"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"job": job})
+}
+
+// GetJobArtifact → GET /jobs/:id/artifact
+// Downloads the file a finished job produced; same access as GetJob.
+func GetJobArtifact(ctx *gin.Context) {
+	userID, ok := getContextUserID(ctx)
+	if !ok {
+		ctx.JSON(401, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var job models.Job
+	if err := database.DB.First(&job, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "job not found"})
+		return
+	}
+	if job.CreatedBy != userID && getUserRole(ctx) != "admin" {
+		ctx.JSON(403, gin.H{"error": "you can only download your own jobs' files"})
+		return
+	}
+	if job.Status != models.JobCompleted || job.ArtifactPath == "" {
+		ctx.JSON(404, gin.H{"error": "job has no file to download", "status": job.Status})
+		return
+	}
+
+	ctx.FileAttachment(job.ArtifactPath, job.ArtifactName)
+}
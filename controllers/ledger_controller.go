@@ -0,0 +1,583 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const jobTypeLedgerBackfill = "ledger_backfill"
+
+// postLedgerEntry writes a balanced entry once per Reference; zero lines are dropped and each
+// posting gets the entry's date. posted is false when the reference was already in the ledger.
+func postLedgerEntry(tx *gorm.DB, entry models.LedgerEntry) (bool, error) {
+	var exists int64
+	if err := tx.Model(&models.LedgerEntry{}).Where("reference = ?", entry.Reference).Count(&exists).Error; err != nil {
+		return false, err
+	}
+	if exists > 0 {
+		return false, nil
+	}
+
+	var postings []models.LedgerPosting
+	var debits, credits float64
+	for _, p := range entry.Postings {
+		p.Debit, p.Credit = roundMoney(p.Debit), roundMoney(p.Credit)
+		if p.Debit < 0 || p.Credit < 0 {
+			return false, fmt.Errorf("ledger %s: negative amount on %s", entry.Reference, p.Account)
+		}
+		if p.Debit == 0 && p.Credit == 0 {
+			continue
+		}
+		p.OccurredAt = entry.OccurredAt
+		debits += p.Debit
+		credits += p.Credit
+		postings = append(postings, p)
+	}
+	if len(postings) == 0 {
+		return false, nil
+	}
+	if moneyDiffers(debits, credits) {
+		return false, fmt.Errorf("ledger %s is unbalanced: debits %.2f, credits %.2f", entry.Reference, debits, credits)
+	}
+	entry.Postings = postings
+	return true, tx.Create(&entry).Error
+}
+
+// paymentDimensions → a posting template tagged with the payment's student, course, teacher and
+// department as they are now
+func paymentDimensions(tx *gorm.DB, payment models.Payment) (models.LedgerPosting, error) {
+	var course models.Course
+	if err := tx.First(&course, payment.CourseID).Error; err != nil {
+		return models.LedgerPosting{}, err
+	}
+	return models.LedgerPosting{
+		PaymentID:    &payment.ID,
+		UserID:       payment.UserID,
+		CourseID:     course.ID,
+		TeacherID:    course.TeacherID,
+		DepartmentID: course.DepartmentID,
+	}, nil
+}
+
+// paymentTax → GST inside a payment, from its invoice (none for receipts and free checkouts)
+func paymentTax(tx *gorm.DB, paymentID uint) float64 {
+	var invoice models.Invoice
+	if err := tx.Where("payment_id = ?", paymentID).First(&invoice).Error; err != nil {
+		return 0
+	}
+	return roundMoney(invoice.CGST + invoice.SGST + invoice.IGST)
+}
+
+// posting → a copy of the dimensions template on account
+func posting(dims models.LedgerPosting, account string, debit, credit float64) models.LedgerPosting {
+	dims.Account, dims.Debit, dims.Credit = account, debit, credit
+	return dims
+}
+
+// postPaymentLedger records a settled payment: the sale at list price (discounts and GST split
+// out) and, for paid checkouts, its collection by the gateway. Runs in the settling transaction,
+// after the invoice is issued.
+func postPaymentLedger(tx *gorm.DB, payment models.Payment) error {
+	dims, err := paymentDimensions(tx, payment)
+	if err != nil {
+		return err
+	}
+	occurred := time.Now()
+	if payment.PaidAt != nil {
+		occurred = *payment.PaidAt
+	}
+	tax := paymentTax(tx, payment.ID)
+	list := roundMoney(payment.Amount + payment.DiscountApplied)
+
+	_, err = postLedgerEntry(tx, models.LedgerEntry{
+		Kind:       models.LedgerSale,
+		Reference:  fmt.Sprintf("payment:%d:sale", payment.ID),
+		Memo:       "course sale, order " + payment.TransactionID,
+		Currency:   payment.Currency,
+		PaymentID:  &payment.ID,
+		OccurredAt: occurred,
+		Postings: []models.LedgerPosting{
+			posting(dims, models.AccountStudentReceivable, payment.Amount, 0),
+			posting(dims, models.AccountDiscounts, payment.DiscountApplied, 0),
+			posting(dims, models.AccountCourseRevenue, 0, list-tax),
+			posting(dims, models.AccountGSTPayable, 0, tax),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = postLedgerEntry(tx, models.LedgerEntry{
+		Kind:       models.LedgerCollection,
+		Reference:  fmt.Sprintf("payment:%d:collection", payment.ID),
+		Memo:       "collected by " + payment.Gateway,
+		Currency:   payment.Currency,
+		PaymentID:  &payment.ID,
+		OccurredAt: occurred,
+		Postings: []models.LedgerPosting{
+			posting(dims, models.AccountGatewayClearing, payment.Amount, 0),
+			posting(dims, models.AccountStudentReceivable, 0, payment.Amount),
+		},
+	})
+	return err
+}
+
+// postGatewayFee records what the gateway kept from a capture. The fee only arrives with the
+// webhook, which may come after the checkout callback has already settled the payment.
+func postGatewayFee(tx *gorm.DB, payment models.Payment, fee float64) error {
+	if fee <= 0 {
+		return nil
+	}
+	dims, err := paymentDimensions(tx, payment)
+	if err != nil {
+		return err
+	}
+	_, err = postLedgerEntry(tx, models.LedgerEntry{
+		Kind:       models.LedgerGatewayFee,
+		Reference:  fmt.Sprintf("payment:%d:fee", payment.ID),
+		Memo:       "gateway fee on " + payment.GatewayPaymentID,
+		Currency:   payment.Currency,
+		PaymentID:  &payment.ID,
+		OccurredAt: time.Now(),
+		Postings: []models.LedgerPosting{
+			posting(dims, models.AccountGatewayFees, fee, 0),
+			posting(dims, models.AccountGatewayClearing, 0, fee),
+		},
+	})
+	return err
+}
+
+// postRefundLedger records a processed refund, reversing the GST in proportion to the amount
+func postRefundLedger(tx *gorm.DB, payment models.Payment, request models.RefundRequest) error {
+	dims, err := paymentDimensions(tx, payment)
+	if err != nil {
+		return err
+	}
+	tax := 0.0
+	if payment.Amount > 0 {
+		tax = roundMoney(request.Amount * paymentTax(tx, payment.ID) / payment.Amount)
+	}
+	occurred := time.Now()
+	if request.ProcessedAt != nil {
+		occurred = *request.ProcessedAt
+	}
+	_, err = postLedgerEntry(tx, models.LedgerEntry{
+		Kind:       models.LedgerRefund,
+		Reference:  fmt.Sprintf("refund:%d", request.ID),
+		Memo:       "refund " + request.GatewayRefundID,
+		Currency:   payment.Currency,
+		PaymentID:  &payment.ID,
+		RefundID:   &request.ID,
+		OccurredAt: occurred,
+		Postings: []models.LedgerPosting{
+			posting(dims, models.AccountRefunds, request.Amount-tax, 0),
+			posting(dims, models.AccountGSTPayable, tax, 0),
+			posting(dims, models.AccountGatewayClearing, 0, request.Amount),
+		},
+	})
+	return err
+}
+
+// backfillLedger posts entries for payments and refunds settled before the ledger existed.
+// Posting is idempotent, so it is safe to run again.
+func backfillLedger(job *models.Job) (string, error) {
+	var payments []models.Payment
+	if err := database.DB.Where("status IN ?", settledStatuses).Order("id").Find(&payments).Error; err != nil {
+		return "", err
+	}
+	var refunds []models.RefundRequest
+	if err := database.DB.Where("status = ?", models.RefundProcessed).Order("id").Find(&refunds).Error; err != nil {
+		return "", err
+	}
+	job.Total = len(payments) + len(refunds)
+
+	before := ledgerEntryCount()
+	for i, payment := range payments {
+		if err := database.DB.Transaction(func(tx *gorm.DB) error { return postPaymentLedger(tx, payment) }); err != nil {
+			return "", fmt.Errorf("payment %d: %v", payment.ID, err)
+		}
+		jobProgress(job, i+1)
+	}
+	for i, request := range refunds {
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var payment models.Payment
+			if err := tx.First(&payment, request.PaymentID).Error; err != nil {
+				return err
+			}
+			return postRefundLedger(tx, payment, request)
+		})
+		if err != nil {
+			return "", fmt.Errorf("refund %d: %v", request.ID, err)
+		}
+		jobProgress(job, len(payments)+i+1)
+	}
+	return fmt.Sprintf("%d payments and %d refunds checked, %d entries posted", len(payments), len(refunds), ledgerEntryCount()-before), nil
+}
+
+func ledgerEntryCount() int64 {
+	var n int64
+	database.DB.Model(&models.LedgerEntry{}).Count(&n)
+	return n
+}
+
+// reportRange reads ?from=&to= (YYYY-MM-DD, to inclusive); nil bounds are open
+func reportRange(ctx *gin.Context) (from, to *time.Time, ok bool) {
+	if v := ctx.Query("from"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "from must be YYYY-MM-DD"})
+			return nil, nil, false
+		}
+		from = &t
+	}
+	if v := ctx.Query("to"); v != "" {
+		t, err := time.ParseInLocation("2006-01-02", v, time.Local)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "to must be YYYY-MM-DD"})
+			return nil, nil, false
+		}
+		end := t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		to = &end
+	}
+	return from, to, true
+}
+
+// GetLedgerEntries → GET /ledger/entries?kind=&payment_id=&account=&from=&to= (admin)
+func GetLedgerEntries(ctx *gin.Context) {
+	from, to, ok := reportRange(ctx)
+	if !ok {
+		return
+	}
+	query := database.DB.Preload("Postings").Order("occurred_at DESC, id DESC")
+	if kind := ctx.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+	if paymentID := ctx.Query("payment_id"); paymentID != "" {
+		query = query.Where("payment_id = ?", paymentID)
+	}
+	if account := ctx.Query("account"); account != "" {
+		query = query.Where("id IN (?)", database.DB.Model(&models.LedgerPosting{}).Select("entry_id").Where("account = ?", account))
+	}
+	if from != nil {
+		query = query.Where("occurred_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("occurred_at <= ?", *to)
+	}
+
+	var entries []models.LedgerEntry
+	if err := query.Find(&entries).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch ledger entries", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"entries": entries})
+}
+
+// accountBalance is one line of the trial balance
+type accountBalance struct {
+	Account string  `json:"account"`
+	Debit   float64 `json:"debit"`
+	Credit  float64 `json:"credit"`
+	Balance float64 `json:"balance"` // debit minus credit
+}
+
+// GetLedgerBalances → GET /ledger/balances?from=&to= (admin)
+// Trial balance per account; debits and credits always agree.
+func GetLedgerBalances(ctx *gin.Context) {
+	from, to, ok := reportRange(ctx)
+	if !ok {
+		return
+	}
+	query := database.DB.Model(&models.LedgerPosting{}).
+		Select("account, SUM(debit) AS debit, SUM(credit) AS credit").Group("account").Order("account")
+	if from != nil {
+		query = query.Where("occurred_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("occurred_at <= ?", *to)
+	}
+
+	var balances []accountBalance
+	if err := query.Scan(&balances).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to compute balances", "details": err.Error()})
+		return
+	}
+	var debits, credits float64
+	for i := range balances {
+		b := &balances[i]
+		b.Debit, b.Credit, b.Balance = roundMoney(b.Debit), roundMoney(b.Credit), roundMoney(b.Debit-b.Credit)
+		debits += b.Debit
+		credits += b.Credit
+	}
+	ctx.JSON(200, gin.H{
+		"accounts":      balances,
+		"total_debits":  roundMoney(debits),
+		"total_credits": roundMoney(credits),
+		"balanced":      !moneyDiffers(debits, credits),
+	})
+}
+
+// revenueRow is one group of the revenue report. Net is what the platform earned: revenue less
+// discounts and refunds, all excluding GST; gateway fees are reported beside it.
+type revenueRow struct {
+	Key       string  `json:"key"`
+	ID        uint    `json:"id,omitempty"`
+	Name      string  `json:"name,omitempty"`
+	Sales     int     `json:"sales"`
+	Gross     float64 `json:"gross"`
+	Discounts float64 `json:"discounts"`
+	Refunds   float64 `json:"refunds"`
+	Net       float64 `json:"net"`
+	GST       float64 `json:"gst"`
+	Fees      float64 `json:"fees"`
+
+	payments map[uint]float64 // gross per payment, to count sales net of reversals
+}
+
+// periodKey → the report bucket a date falls in
+func periodKey(t time.Time, interval string) string {
+	switch interval {
+	case "day":
+		return t.Format("2006-01-02")
+	case "year":
+		return t.Format("2006")
+	}
+	return t.Format("2006-01")
+}
+
+// GetRevenueReport → GET /ledger/revenue?group_by=course|department|teacher|period&interval=day|month|year
+// &from=&to=&course_id=&department_id=&teacher_id=&currency= (admin, teacher)
+// Teachers only see their own courses. Amounts are never converted, so the report covers one
+// currency at a time (INR unless ?currency= is given).
+func GetRevenueReport(ctx *gin.Context) {
+	from, to, ok := reportRange(ctx)
+	if !ok {
+		return
+	}
+	groupBy := ctx.DefaultQuery("group_by", "course")
+	interval := ctx.DefaultQuery("interval", "month")
+	if groupBy != "course" && groupBy != "department" && groupBy != "teacher" && groupBy != "period" {
+		ctx.JSON(400, gin.H{"error": "group_by must be course, department, teacher or period"})
+		return
+	}
+	if interval != "day" && interval != "month" && interval != "year" {
+		ctx.JSON(400, gin.H{"error": "interval must be day, month or year"})
+		return
+	}
+	currency := strings.ToUpper(strings.TrimSpace(ctx.DefaultQuery("currency", "INR")))
+	if len(currency) != 3 {
+		ctx.JSON(400, gin.H{"error": "currency must be a 3-letter code"})
+		return
+	}
+
+	query := database.DB.Model(&models.LedgerPosting{}).Where("account IN ?", []string{
+		models.AccountCourseRevenue, models.AccountDiscounts, models.AccountRefunds,
+		models.AccountGSTPayable, models.AccountGatewayFees,
+	}).Where("entry_id IN (?)", database.DB.Model(&models.LedgerEntry{}).Select("id").Where("currency = ?", currency))
+	if getUserRole(ctx) != "admin" {
+		userID, _ := getContextUserID(ctx)
+		query = query.Where("teacher_id = ?", userID)
+	} else if teacherID := ctx.Query("teacher_id"); teacherID != "" {
+		query = query.Where("teacher_id = ?", teacherID)
+	}
+	if courseID := ctx.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if departmentID := ctx.Query("department_id"); departmentID != "" {
+		query = query.Where("department_id = ?", departmentID)
+	}
+	if from != nil {
+		query = query.Where("occurred_at >= ?", *from)
+	}
+	if to != nil {
+		query = query.Where("occurred_at <= ?", *to)
+	}
+
+	var postings []models.LedgerPosting
+	if err := query.Find(&postings).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch ledger", "details": err.Error()})
+		return
+	}
+
+	rows := map[string]*revenueRow{}
+	total := revenueRow{payments: map[uint]float64{}}
+	for _, p := range postings {
+		key, id := periodKey(p.OccurredAt, interval), uint(0)
+		switch groupBy {
+		case "course":
+			id = p.CourseID
+		case "department":
+			id = p.DepartmentID
+		case "teacher":
+			id = p.TeacherID
+		}
+		if groupBy != "period" {
+			key = fmt.Sprint(id)
+		}
+		row := rows[key]
+		if row == nil {
+			row = &revenueRow{Key: key, ID: id, payments: map[uint]float64{}}
+			rows[key] = row
+		}
+		for _, r := range []*revenueRow{row, &total} {
+			switch p.Account {
+			case models.AccountCourseRevenue:
+				r.Gross += p.Credit - p.Debit
+				if p.PaymentID != nil {
+					r.payments[*p.PaymentID] += p.Credit - p.Debit
+				}
+			case models.AccountDiscounts:
+				r.Discounts += p.Debit - p.Credit
+			case models.AccountRefunds:
+				r.Refunds += p.Debit - p.Credit
+			case models.AccountGSTPayable:
+				r.GST += p.Credit - p.Debit
+			case models.AccountGatewayFees:
+				r.Fees += p.Debit - p.Credit
+			}
+		}
+	}
+
+	report := make([]revenueRow, 0, len(rows))
+	for _, row := range rows {
+		report = append(report, *row)
+	}
+	report = append(report, total)
+	names := revenueGroupNames(groupBy, report)
+	for i := range report {
+		r := &report[i]
+		r.Name = names[r.ID]
+		r.Net = roundMoney(r.Gross - r.Discounts - r.Refunds)
+		r.Gross, r.Discounts, r.Refunds = roundMoney(r.Gross), roundMoney(r.Discounts), roundMoney(r.Refunds)
+		r.GST, r.Fees = roundMoney(r.GST), roundMoney(r.Fees)
+		for _, gross := range r.payments {
+			if gross >= 0.005 {
+				r.Sales++
+			}
+		}
+	}
+	total = report[len(report)-1]
+	total.Key = "total"
+	report = report[:len(report)-1]
+	sort.Slice(report, func(i, j int) bool {
+		if groupBy == "period" {
+			return report[i].Key < report[j].Key
+		}
+		if math.Abs(report[i].Net-report[j].Net) >= 0.005 {
+			return report[i].Net > report[j].Net
+		}
+		return report[i].ID < report[j].ID
+	})
+	ctx.JSON(200, gin.H{"group_by": groupBy, "interval": interval, "currency": currency, "rows": report, "total": total})
+}
+
+// revenueGroupNames → display names for the ids in a course, department or teacher report
+func revenueGroupNames(groupBy string, rows []revenueRow) map[uint]string {
+	names := map[uint]string{}
+	var ids []uint
+	for _, r := range rows {
+		if r.ID != 0 {
+			ids = append(ids, r.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return names
+	}
+	switch groupBy {
+	case "course":
+		var courses []models.Course
+		database.DB.Select("id, title").Where("id IN ?", ids).Find(&courses)
+		for _, c := range courses {
+			names[c.ID] = c.Title
+		}
+	case "department":
+		var departments []models.Department
+		database.DB.Select("id, name").Where("id IN ?", ids).Find(&departments)
+		for _, d := range departments {
+			names[d.ID] = d.Name
+		}
+	case "teacher":
+		var users []models.User
+		database.DB.Select("id, name").Where("id IN ?", ids).Find(&users)
+		for _, u := range users {
+			names[u.ID] = u.Name
+		}
+	}
+	return names
+}
+
+// ReverseLedgerEntry → POST /ledger/entries/:id/reverse {reason} (admin)
+// The ledger is append-only; a wrong entry is cancelled by posting its mirror image.
+func ReverseLedgerEntry(ctx *gin.Context) {
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	adminID, _ := getContextUserID(ctx)
+
+	var original models.LedgerEntry
+	if err := database.DB.Preload("Postings").First(&original, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "ledger entry not found"})
+		return
+	}
+	if original.Kind == models.LedgerReversal {
+		ctx.JSON(400, gin.H{"error": "a reversal cannot be reversed; post the correcting entry instead"})
+		return
+	}
+
+	reversal := models.LedgerEntry{
+		Kind:       models.LedgerReversal,
+		Reference:  fmt.Sprintf("reversal:%d", original.ID),
+		Memo:       input.Reason,
+		Currency:   original.Currency,
+		PaymentID:  original.PaymentID,
+		RefundID:   original.RefundID,
+		ReversesID: &original.ID,
+		CreatedBy:  &adminID,
+		OccurredAt: time.Now(),
+	}
+	for _, p := range original.Postings {
+		p.ID, p.EntryID = 0, 0
+		p.Debit, p.Credit = p.Credit, p.Debit
+		reversal.Postings = append(reversal.Postings, p)
+	}
+	posted := false
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		posted, err = postLedgerEntry(tx, reversal)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to post reversal", "details": err.Error()})
+		return
+	}
+	if !posted {
+		ctx.JSON(409, gin.H{"error": "entry has already been reversed"})
+		return
+	}
+	database.DB.Preload("Postings").Where("reference = ?", reversal.Reference).First(&reversal)
+	ctx.JSON(201, gin.H{"message": "entry reversed", "entry": reversal})
+}
+
+// BackfillLedger → POST /ledger/backfill (admin)
+// Posts payments and refunds recorded before the ledger existed, in a background job.
+func BackfillLedger(ctx *gin.Context) {
+	adminID, _ := getContextUserID(ctx)
+	job, err := startJob(jobTypeLedgerBackfill, adminID, backfillLedger)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to start backfill", "details": err.Error()})
+		return
+	}
+	ctx.JSON(202, gin.H{"message": "ledger backfill started", "job": job})
+}
@@ -0,0 +1,745 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const jobTypePayoutStatements = "payout_statements"
+
+// payoutAccounts are the ledger accounts a teacher's share is computed from
+var payoutAccounts = []string{models.AccountCourseRevenue, models.AccountDiscounts, models.AccountRefunds, models.AccountGatewayFees}
+
+// payoutCurrency is the currency teachers are paid in. Shares are never converted, so a month
+// with sales in another currency is refused instead of adding the amounts up.
+const payoutCurrency = "INR"
+
+// errPayoutCurrency is returned when a teacher's month has postings in another currency
+var errPayoutCurrency = errors.New("ledger activity in a currency other than " + payoutCurrency + "; settle it outside payouts")
+
+// ruleApplies → the rule covers this teacher and department at time at
+func ruleApplies(rule models.RevenueShareRule, teacherID, departmentID uint, at time.Time) bool {
+	if rule.TeacherID != nil && *rule.TeacherID != teacherID {
+		return false
+	}
+	if rule.DepartmentID != nil && *rule.DepartmentID != departmentID {
+		return false
+	}
+	return !at.Before(rule.EffectiveFrom) && (rule.EffectiveUntil == nil || at.Before(*rule.EffectiveUntil))
+}
+
+// ruleRank → teacher rules beat department rules beat the default
+func ruleRank(rule models.RevenueShareRule) int {
+	rank := 0
+	if rule.TeacherID != nil {
+		rank += 2
+	}
+	if rule.DepartmentID != nil {
+		rank++
+	}
+	return rank
+}
+
+// matchRule → the most specific rule in effect, the newest on a tie; nil when none applies
+func matchRule(rules []models.RevenueShareRule, teacherID, departmentID uint, at time.Time) *models.RevenueShareRule {
+	var best *models.RevenueShareRule
+	for i := range rules {
+		rule := &rules[i]
+		if !ruleApplies(*rule, teacherID, departmentID, at) {
+			continue
+		}
+		if best == nil || ruleRank(*rule) > ruleRank(*best) ||
+			(ruleRank(*rule) == ruleRank(*best) && rule.EffectiveFrom.After(best.EffectiveFrom)) {
+			best = rule
+		}
+	}
+	return best
+}
+
+// parsePayoutPeriod → the month "2006-01" as [start, end); only finished months can be paid out
+func parsePayoutPeriod(period string) (time.Time, time.Time, error) {
+	start, err := time.ParseInLocation("2006-01", period, time.Local)
+	if err != nil {
+		return start, start, fmt.Errorf("period must be YYYY-MM")
+	}
+	end := start.AddDate(0, 1, 0)
+	if end.After(time.Now()) {
+		return start, end, fmt.Errorf("period %s has not ended yet", period)
+	}
+	return start, end, nil
+}
+
+// buildPayoutStatement computes a teacher's statement for a month from the ledger. Each posting
+// is shared under the rule in effect when it happened, so a rule change mid-month splits a
+// course over two lines.
+func buildPayoutStatement(db *gorm.DB, teacherID uint, period string, start, end time.Time, rules []models.RevenueShareRule) (models.PayoutStatement, error) {
+	statement := models.PayoutStatement{TeacherID: teacherID, Period: period, PeriodStart: start, PeriodEnd: end, Currency: payoutCurrency}
+
+	var foreign int64
+	if err := db.Model(&models.LedgerPosting{}).
+		Where("teacher_id = ? AND account IN ? AND occurred_at >= ? AND occurred_at < ?", teacherID, payoutAccounts, start, end).
+		Where("entry_id IN (?)", db.Model(&models.LedgerEntry{}).Select("id").Where("currency <> ?", payoutCurrency)).
+		Count(&foreign).Error; err != nil {
+		return statement, err
+	}
+	if foreign > 0 {
+		return statement, errPayoutCurrency
+	}
+
+	var postings []models.LedgerPosting
+	if err := db.Where("teacher_id = ? AND account IN ? AND occurred_at >= ? AND occurred_at < ?", teacherID, payoutAccounts, start, end).
+		Order("occurred_at, id").Find(&postings).Error; err != nil {
+		return statement, err
+	}
+
+	type lineKey struct {
+		courseID uint
+		ruleID   uint
+	}
+	lines := map[lineKey]*models.PayoutLine{}
+	sales := map[lineKey]map[uint]float64{}
+	for _, p := range postings {
+		rule := matchRule(rules, teacherID, p.DepartmentID, p.OccurredAt)
+		key := lineKey{courseID: p.CourseID}
+		if rule != nil {
+			key.ruleID = rule.ID
+		}
+		line := lines[key]
+		if line == nil {
+			line = &models.PayoutLine{CourseID: p.CourseID}
+			if rule != nil {
+				line.RuleID, line.Percent = &rule.ID, rule.Percent
+			}
+			lines[key] = line
+			sales[key] = map[uint]float64{}
+		}
+		switch p.Account {
+		case models.AccountCourseRevenue:
+			line.Gross += p.Credit - p.Debit
+			if p.PaymentID != nil {
+				sales[key][*p.PaymentID] += p.Credit - p.Debit
+			}
+		case models.AccountDiscounts:
+			line.Discounts += p.Debit - p.Credit
+		case models.AccountRefunds:
+			line.Refunds += p.Debit - p.Credit
+		case models.AccountGatewayFees:
+			line.Fees += p.Debit - p.Credit
+		}
+	}
+
+	var courseIDs []uint
+	for key := range lines {
+		courseIDs = append(courseIDs, key.courseID)
+	}
+	titles := map[uint]string{}
+	if len(courseIDs) > 0 {
+		var courses []models.Course
+		db.Select("id, title").Where("id IN ?", courseIDs).Find(&courses)
+		for _, c := range courses {
+			titles[c.ID] = c.Title
+		}
+	}
+
+	for key, line := range lines {
+		for _, gross := range sales[key] {
+			if gross >= 0.005 {
+				line.Sales++
+			}
+		}
+		line.CourseTitle = titles[line.CourseID]
+		line.Gross, line.Discounts = roundMoney(line.Gross), roundMoney(line.Discounts)
+		line.Refunds, line.Fees = roundMoney(line.Refunds), roundMoney(line.Fees)
+		line.Net = roundMoney(line.Gross - line.Discounts - line.Refunds - line.Fees)
+		line.Share = roundMoney(line.Net * line.Percent / 100)
+
+		statement.Sales += line.Sales
+		statement.Gross += line.Gross
+		statement.Discounts += line.Discounts
+		statement.Refunds += line.Refunds
+		statement.Fees += line.Fees
+		statement.Net += line.Net
+		statement.Share += line.Share
+		statement.Lines = append(statement.Lines, *line)
+	}
+	sort.Slice(statement.Lines, func(i, j int) bool {
+		a, b := statement.Lines[i], statement.Lines[j]
+		if a.CourseID != b.CourseID {
+			return a.CourseID < b.CourseID
+		}
+		return a.Percent < b.Percent
+	})
+	statement.Gross, statement.Discounts = roundMoney(statement.Gross), roundMoney(statement.Discounts)
+	statement.Refunds, statement.Fees = roundMoney(statement.Refunds), roundMoney(statement.Fees)
+	statement.Net, statement.Share = roundMoney(statement.Net), roundMoney(statement.Share)
+
+	carried, err := payoutCarriedIn(db, teacherID, period)
+	if err != nil {
+		return statement, err
+	}
+	statement.CarriedIn = carried
+	statement.Payable = roundMoney(statement.Share + statement.CarriedIn)
+	return statement, nil
+}
+
+// payoutCarriedIn → the negative balance on the teacher's latest settled statement before period,
+// which is recovered from the next one (0 when it is positive or there is none)
+func payoutCarriedIn(db *gorm.DB, teacherID uint, period string) (float64, error) {
+	var previous models.PayoutStatement
+	err := db.Where("teacher_id = ? AND period < ? AND status IN ?", teacherID, period, []string{models.PayoutApproved, models.PayoutPaid}).
+		Order("period DESC").First(&previous).Error
+	if err == gorm.ErrRecordNotFound {
+		return 0, nil
+	}
+	if err != nil || previous.Payable >= 0 {
+		return 0, err
+	}
+	return previous.Payable, nil
+}
+
+// savePayoutStatement stores a freshly built statement, replacing a draft or rejected one for
+// the same month. Approved and paid statements are left alone; saved is false for those.
+func savePayoutStatement(built models.PayoutStatement) (saved bool, err error) {
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var existing models.PayoutStatement
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("teacher_id = ? AND period = ?", built.TeacherID, built.Period).First(&existing).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if err == nil {
+			if existing.Status == models.PayoutApproved || existing.Status == models.PayoutPaid {
+				return nil
+			}
+			if err := tx.Where("statement_id = ?", existing.ID).Delete(&models.PayoutLine{}).Error; err != nil {
+				return err
+			}
+			built.ID, built.CreatedAt = existing.ID, existing.CreatedAt
+		}
+
+		built.Status, built.GeneratedAt = models.PayoutDraft, time.Now()
+		if err := tx.Omit("Lines").Save(&built).Error; err != nil {
+			return err
+		}
+		for i := range built.Lines {
+			built.Lines[i].ID, built.Lines[i].StatementID = 0, built.ID
+		}
+		if len(built.Lines) > 0 {
+			if err := tx.Create(&built.Lines).Error; err != nil {
+				return err
+			}
+		}
+		saved = true
+		return nil
+	})
+	return saved, err
+}
+
+// generatePayoutStatements builds the month's statement for every teacher with ledger activity
+// in it (or just teacherID). Teachers with sales in another currency are skipped and listed.
+func generatePayoutStatements(job *models.Job, period string, teacherID uint) (string, error) {
+	start, end, err := parsePayoutPeriod(period)
+	if err != nil {
+		return "", err
+	}
+	var rules []models.RevenueShareRule
+	if err := database.DB.Find(&rules).Error; err != nil {
+		return "", err
+	}
+
+	teachers := []uint{teacherID}
+	if teacherID == 0 {
+		teachers = nil
+		if err := database.DB.Model(&models.LedgerPosting{}).
+			Where("teacher_id <> 0 AND account IN ? AND occurred_at >= ? AND occurred_at < ?", payoutAccounts, start, end).
+			Distinct().Order("teacher_id").Pluck("teacher_id", &teachers).Error; err != nil {
+			return "", err
+		}
+	}
+	job.Total = len(teachers)
+
+	generated, locked := 0, 0
+	var skipped []string
+	for i, id := range teachers {
+		statement, err := buildPayoutStatement(database.DB, id, period, start, end, rules)
+		if err == errPayoutCurrency && teacherID == 0 {
+			skipped = append(skipped, fmt.Sprint(id))
+			jobProgress(job, i+1)
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("teacher %d: %v", id, err)
+		}
+		saved, err := savePayoutStatement(statement)
+		if err != nil {
+			return "", fmt.Errorf("teacher %d: %v", id, err)
+		}
+		if saved {
+			generated++
+		} else {
+			locked++
+		}
+		jobProgress(job, i+1)
+	}
+	result := fmt.Sprintf("%s: %d statements generated, %d already approved or paid", period, generated, locked)
+	if len(skipped) > 0 {
+		result += fmt.Sprintf("; skipped teachers %s: %v", strings.Join(skipped, ", "), errPayoutCurrency)
+	}
+	return result, nil
+}
+
+// revenueShareInput is the body for creating or replacing a rule
+type revenueShareInput struct {
+	TeacherID      *uint      `json:"teacher_id"`
+	DepartmentID   *uint      `json:"department_id"`
+	Percent        float64    `json:"percent" binding:"gte=0,lte=100"`
+	EffectiveFrom  *time.Time `json:"effective_from"`
+	EffectiveUntil *time.Time `json:"effective_until"`
+	Note           string     `json:"note"`
+}
+
+// apply checks the rule's references and dates and fills in the rule
+func (in revenueShareInput) apply(rule *models.RevenueShareRule) error {
+	if in.TeacherID != nil {
+		var teacher models.User
+		if err := database.DB.First(&teacher, *in.TeacherID).Error; err != nil || teacher.Role != "teacher" {
+			return fmt.Errorf("teacher %d not found", *in.TeacherID)
+		}
+	}
+	if in.DepartmentID != nil {
+		var department models.Department
+		if err := database.DB.First(&department, *in.DepartmentID).Error; err != nil {
+			return fmt.Errorf("department %d not found", *in.DepartmentID)
+		}
+	}
+	from := time.Now()
+	if in.EffectiveFrom != nil {
+		from = *in.EffectiveFrom
+	}
+	if in.EffectiveUntil != nil && !in.EffectiveUntil.After(from) {
+		return fmt.Errorf("effective_until must be after effective_from")
+	}
+	rule.TeacherID, rule.DepartmentID, rule.Percent = in.TeacherID, in.DepartmentID, in.Percent
+	rule.EffectiveFrom, rule.EffectiveUntil, rule.Note = from, in.EffectiveUntil, in.Note
+	return nil
+}
+
+// ruleInSettledStatement → the rule has been paid out under, so changing it would rewrite history
+func ruleInSettledStatement(ruleID uint) bool {
+	var n int64
+	database.DB.Model(&models.PayoutLine{}).
+		Joins("JOIN payout_statements ON payout_statements.id = payout_lines.statement_id").
+		Where("payout_lines.rule_id = ? AND payout_statements.status IN ?", ruleID, []string{models.PayoutApproved, models.PayoutPaid}).
+		Count(&n)
+	return n > 0
+}
+
+// GetRevenueShareRules → GET /payouts/rules?teacher_id=&department_id= (admin)
+func GetRevenueShareRules(ctx *gin.Context) {
+	query := database.DB.Order("effective_from DESC, id DESC")
+	if teacherID := ctx.Query("teacher_id"); teacherID != "" {
+		query = query.Where("teacher_id = ?", teacherID)
+	}
+	if departmentID := ctx.Query("department_id"); departmentID != "" {
+		query = query.Where("department_id = ?", departmentID)
+	}
+	var rules []models.RevenueShareRule
+	if err := query.Find(&rules).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch rules", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"rules": rules})
+}
+
+// CreateRevenueShareRule → POST /payouts/rules {teacher_id, department_id, percent, effective_from, effective_until, note} (admin)
+// Leave both teacher_id and department_id out for the platform default.
+func CreateRevenueShareRule(ctx *gin.Context) {
+	var input revenueShareInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	adminID, _ := getContextUserID(ctx)
+	rule := models.RevenueShareRule{CreatedBy: adminID}
+	if err := input.apply(&rule); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid rule", "details": err.Error()})
+		return
+	}
+	if err := database.DB.Create(&rule).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to create rule", "details": err.Error()})
+		return
+	}
+	ctx.JSON(201, gin.H{"message": "rule created", "rule": rule})
+}
+
+// UpdateRevenueShareRule → PUT /payouts/rules/:id (admin)
+// Rules already paid out under are fixed; end them with effective_until and add a new one.
+func UpdateRevenueShareRule(ctx *gin.Context) {
+	var rule models.RevenueShareRule
+	if err := database.DB.First(&rule, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "rule not found"})
+		return
+	}
+	var input revenueShareInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	if ruleInSettledStatement(rule.ID) {
+		// Only closing the rule is allowed
+		sameRule := input.Percent == rule.Percent && equalUintPtr(input.TeacherID, rule.TeacherID) &&
+			equalUintPtr(input.DepartmentID, rule.DepartmentID) && input.EffectiveFrom != nil && input.EffectiveFrom.Equal(rule.EffectiveFrom)
+		if !sameRule {
+			ctx.JSON(409, gin.H{"error": "rule is used by approved statements; set effective_until and create a new rule instead"})
+			return
+		}
+	}
+	if err := input.apply(&rule); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid rule", "details": err.Error()})
+		return
+	}
+	if err := database.DB.Save(&rule).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to update rule", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "rule updated", "rule": rule})
+}
+
+func equalUintPtr(a, b *uint) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// DeleteRevenueShareRule → DELETE /payouts/rules/:id (admin)
+func DeleteRevenueShareRule(ctx *gin.Context) {
+	var rule models.RevenueShareRule
+	if err := database.DB.First(&rule, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "rule not found"})
+		return
+	}
+	if ruleInSettledStatement(rule.ID) {
+		ctx.JSON(409, gin.H{"error": "rule is used by approved statements; set effective_until instead"})
+		return
+	}
+	if err := database.DB.Delete(&rule).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to delete rule", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "rule deleted"})
+}
+
+// GeneratePayoutStatements → POST /payouts/statements/generate {period: "2026-09", teacher_id} (admin)
+// Builds draft statements in a background job; regenerating replaces drafts and rejected ones.
+// Statements are in INR only; a month with sales in another currency is not paid out.
+func GeneratePayoutStatements(ctx *gin.Context) {
+	var input struct {
+		Period    string `json:"period" binding:"required"`
+		TeacherID uint   `json:"teacher_id"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	if _, _, err := parsePayoutPeriod(input.Period); err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	adminID, _ := getContextUserID(ctx)
+	job, err := startJob(jobTypePayoutStatements, adminID, func(job *models.Job) (string, error) {
+		return generatePayoutStatements(job, input.Period, input.TeacherID)
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to start statement generation", "details": err.Error()})
+		return
+	}
+	ctx.JSON(202, gin.H{"message": "statement generation started", "job": job})
+}
+
+// GetPayoutStatements → GET /payouts/statements?period=&status=&teacher_id= (admin, teacher)
+// Teachers see their own statements.
+func GetPayoutStatements(ctx *gin.Context) {
+	query := database.DB.Preload("Teacher").Order("period DESC, teacher_id")
+	if getUserRole(ctx) != "admin" {
+		userID, _ := getContextUserID(ctx)
+		query = query.Where("teacher_id = ?", userID)
+	} else if teacherID := ctx.Query("teacher_id"); teacherID != "" {
+		query = query.Where("teacher_id = ?", teacherID)
+	}
+	if period := ctx.Query("period"); period != "" {
+		query = query.Where("period = ?", period)
+	}
+	if status := ctx.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var statements []models.PayoutStatement
+	if err := query.Find(&statements).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch statements", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"statements": statements})
+}
+
+// loadPayoutStatement → the statement in :id with its lines, if the caller may see it
+func loadPayoutStatement(ctx *gin.Context) (models.PayoutStatement, bool) {
+	var statement models.PayoutStatement
+	if err := database.DB.Preload("Teacher").Preload("Lines").First(&statement, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "statement not found"})
+		return statement, false
+	}
+	userID, _ := getContextUserID(ctx)
+	if getUserRole(ctx) != "admin" && statement.TeacherID != userID {
+		ctx.JSON(404, gin.H{"error": "statement not found"})
+		return statement, false
+	}
+	return statement, true
+}
+
+// GetPayoutStatement → GET /payouts/statements/:id (admin, owning teacher)
+func GetPayoutStatement(ctx *gin.Context) {
+	statement, ok := loadPayoutStatement(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(200, gin.H{"statement": statement})
+}
+
+// reviewPayoutStatement applies updates to a statement currently in one of the from statuses,
+// recording the reviewing admin
+func reviewPayoutStatement(ctx *gin.Context, from []string, updates map[string]interface{}) {
+	adminID, _ := getContextUserID(ctx)
+	var statement models.PayoutStatement
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&statement, ctx.Param("id")).Error; err != nil {
+			return err
+		}
+		allowed := false
+		for _, s := range from {
+			allowed = allowed || statement.Status == s
+		}
+		if !allowed {
+			return fmt.Errorf("statement is %s", statement.Status)
+		}
+		if updates["status"] == models.PayoutPaid && statement.Payable <= 0 {
+			return fmt.Errorf("nothing is payable on this statement; its balance carries into the next one")
+		}
+		if updates["status"] == models.PayoutRejected && statement.Status == models.PayoutApproved {
+			// A later settled month may already have carried in this one's balance
+			var later []string
+			if err := tx.Model(&models.PayoutStatement{}).
+				Where("teacher_id = ? AND period > ? AND status IN ?", statement.TeacherID, statement.Period, []string{models.PayoutApproved, models.PayoutPaid}).
+				Order("period").Pluck("period", &later).Error; err != nil {
+				return err
+			}
+			if len(later) > 0 {
+				return fmt.Errorf("later statements are already settled: %s", strings.Join(later, ", "))
+			}
+		}
+		if updates["status"] == models.PayoutApproved {
+			// Months settle in order, or an earlier deficit would never be carried in
+			var unsettled []string
+			if err := tx.Model(&models.PayoutStatement{}).
+				Where("teacher_id = ? AND period < ? AND status IN ?", statement.TeacherID, statement.Period, []string{models.PayoutDraft, models.PayoutRejected}).
+				Order("period").Pluck("period", &unsettled).Error; err != nil {
+				return err
+			}
+			if len(unsettled) > 0 {
+				return fmt.Errorf("earlier statements are not settled yet: %s", strings.Join(unsettled, ", "))
+			}
+			carried, err := payoutCarriedIn(tx, statement.TeacherID, statement.Period)
+			if err != nil {
+				return err
+			}
+			if moneyDiffers(carried, statement.CarriedIn) {
+				return fmt.Errorf("the balance carried from earlier statements has changed since this one was generated; regenerate it")
+			}
+		}
+		if updates["status"] != models.PayoutPaid {
+			updates["reviewed_by"], updates["reviewed_at"] = adminID, time.Now()
+		}
+		return tx.Model(&statement).Updates(updates).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		ctx.JSON(404, gin.H{"error": "statement not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(409, gin.H{"error": "statement cannot be updated", "details": err.Error()})
+		return
+	}
+	database.DB.Preload("Lines").First(&statement, statement.ID)
+	ctx.JSON(200, gin.H{"statement": statement})
+}
+
+// ApprovePayoutStatement → POST /payouts/statements/:id/approve {note} (admin)
+// A teacher's statements are approved in period order, so each one carries in the last deficit.
+func ApprovePayoutStatement(ctx *gin.Context) {
+	var input struct {
+		Note string `json:"note"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	reviewPayoutStatement(ctx, []string{models.PayoutDraft}, map[string]interface{}{"status": models.PayoutApproved, "review_note": input.Note})
+}
+
+// RejectPayoutStatement → POST /payouts/statements/:id/reject {reason} (admin)
+// Fix the rules or ledger and regenerate; the statement returns to draft. An approved statement
+// can only be rejected while no later month for the teacher is approved or paid.
+func RejectPayoutStatement(ctx *gin.Context) {
+	var input struct {
+		Reason string `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	reviewPayoutStatement(ctx, []string{models.PayoutDraft, models.PayoutApproved}, map[string]interface{}{"status": models.PayoutRejected, "review_note": input.Reason})
+}
+
+// MarkPayoutStatementPaid → POST /payouts/statements/:id/paid {reference} (admin)
+// Records the bank transfer for an approved statement.
+func MarkPayoutStatementPaid(ctx *gin.Context) {
+	var input struct {
+		Reference string `json:"reference" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	reviewPayoutStatement(ctx, []string{models.PayoutApproved}, map[string]interface{}{
+		"status": models.PayoutPaid, "payment_reference": input.Reference, "paid_at": time.Now(),
+	})
+}
+
+// payoutStatementRows → the statement as a table: one row per line, then the totals
+func payoutStatementRows(s models.PayoutStatement) [][]string {
+	money := func(v float64) string { return fmt.Sprintf("%.2f", v) }
+	rows := [][]string{{"Course ID", "Course", "Sales", "Gross", "Discounts", "Refunds", "Fees", "Net", "Share %", "Share"}}
+	for _, l := range s.Lines {
+		rows = append(rows, []string{fmt.Sprint(l.CourseID), l.CourseTitle, fmt.Sprint(l.Sales), money(l.Gross), money(l.Discounts),
+			money(l.Refunds), money(l.Fees), money(l.Net), fmt.Sprintf("%g", l.Percent), money(l.Share)})
+	}
+	rows = append(rows, []string{"", "Total", fmt.Sprint(s.Sales), money(s.Gross), money(s.Discounts),
+		money(s.Refunds), money(s.Fees), money(s.Net), "", money(s.Share)})
+	return rows
+}
+
+// renderPayoutStatementPDF draws the statement on an A4 landscape page
+func renderPayoutStatementPDF(s models.PayoutStatement, w io.Writer) error {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.AddPage()
+	money := func(v float64) string { return fmt.Sprintf("%.2f", v) }
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 9, "Payout statement "+s.Period, "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	teacher := fmt.Sprint("Teacher #", s.TeacherID)
+	if s.Teacher != nil {
+		teacher = s.Teacher.Name + " <" + s.Teacher.Email + ">"
+	}
+	pdf.CellFormat(0, 5.5, tr(teacher), "", 1, "L", false, 0, "")
+	pdf.CellFormat(0, 5.5, tr(fmt.Sprintf("%s, %s to %s, status %s",
+		utils.PlatformName(), s.PeriodStart.Format("02 Jan 2006"), s.PeriodEnd.AddDate(0, 0, -1).Format("02 Jan 2006"), s.Status)), "", 1, "L", false, 0, "")
+	pdf.Ln(5)
+
+	widths := []float64{18, 77, 15, 22, 22, 22, 22, 22, 18, 29}
+	rows := payoutStatementRows(s)
+	for n, row := range rows {
+		style, fill := "", false
+		if n == 0 || n == len(rows)-1 {
+			style, fill = "B", n == 0
+		}
+		pdf.SetFont("Helvetica", style, 8.5)
+		pdf.SetFillColor(235, 238, 242)
+		for i, cell := range row {
+			align := "R"
+			if i == 1 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 7, tr(cell), "1", 0, align, fill, 0, "")
+		}
+		pdf.Ln(-1)
+	}
+
+	pdf.Ln(4)
+	totals := [][2]string{{"Share for the month", money(s.Share)}}
+	if s.CarriedIn != 0 {
+		totals = append(totals, [2]string{"Carried from earlier statements", money(s.CarriedIn)})
+	}
+	totals = append(totals, [2]string{"Payable (" + s.Currency + ")", money(s.Payable)})
+	for i, t := range totals {
+		style := ""
+		if i == len(totals)-1 {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.SetX(182)
+		pdf.CellFormat(70, 6, t[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(30, 6, t[1], "", 1, "R", false, 0, "")
+	}
+
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "", 8)
+	pdf.SetTextColor(90, 90, 90)
+	pdf.MultiCell(0, 4.5, "Net is revenue excluding GST, after discounts, refunds and payment gateway fees. "+
+		"Refunds are counted in the month they are processed.", "", "L", false)
+	if s.PaidAt != nil {
+		pdf.CellFormat(0, 4.5, tr("Paid "+s.PaidAt.Format("02 Jan 2006")+", reference "+s.PaymentReference), "", 1, "L", false, 0, "")
+	}
+	return pdf.Output(w)
+}
+
+// ExportPayoutStatement → GET /payouts/statements/:id/export?format=csv|pdf (admin, owning teacher)
+func ExportPayoutStatement(ctx *gin.Context) {
+	statement, ok := loadPayoutStatement(ctx)
+	if !ok {
+		return
+	}
+	baseName := fmt.Sprintf("payout_%s_teacher_%d", statement.Period, statement.TeacherID)
+
+	var buf bytes.Buffer
+	switch ctx.DefaultQuery("format", "csv") {
+	case "csv":
+		w := csv.NewWriter(&buf)
+		w.WriteAll(payoutStatementRows(statement))
+		w.WriteAll([][]string{
+			{},
+			{"", "Carried in", "", "", "", "", "", "", "", fmt.Sprintf("%.2f", statement.CarriedIn)},
+			{"", "Payable", "", "", "", "", "", "", "", fmt.Sprintf("%.2f", statement.Payable)},
+		})
+		if err := w.Error(); err != nil {
+			ctx.JSON(500, gin.H{"error": "failed to export statement", "details": err.Error()})
+			return
+		}
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.csv", baseName))
+		ctx.Data(200, "text/csv", buf.Bytes())
+	case "pdf":
+		if err := renderPayoutStatementPDF(statement, &buf); err != nil {
+			ctx.JSON(500, gin.H{"error": "failed to export statement", "details": err.Error()})
+			return
+		}
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", baseName))
+		ctx.Data(200, "application/pdf", buf.Bytes())
+	default:
+		ctx.JSON(400, gin.H{"error": "format must be csv or pdf"})
+	}
+}
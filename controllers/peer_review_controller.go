@@ -0,0 +1,574 @@
+package controllers
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Outliers are only judged against the median once a submission has this many reviews
+const minReviewsForOutliers = 3
+
+// allocatePeerReviews pairs every author with n reviewers so that each submission gets n
+// reviews and each student writes n reviews, never of their own work and never twice.
+// Authors are shuffled, then reviewer k of author i is author (i+k) mod len, k = 1..n.
+// Returns author index → reviewer indexes. n must be less than len(authors).
+func allocatePeerReviews(authors int, n int, rng *rand.Rand) [][]int {
+	order := rng.Perm(authors)
+	allocation := make([][]int, authors)
+	for pos, author := range order {
+		for k := 1; k <= n; k++ {
+			allocation[author] = append(allocation[author], order[(pos+k)%authors])
+		}
+	}
+	return allocation
+}
+
+// reviewPercent → a completed review's score as a percentage
+func reviewPercent(r models.PeerReview) float64 {
+	if r.MaxScore <= 0 {
+		return 0
+	}
+	return float64(r.Score) / float64(r.MaxScore) * 100
+}
+
+// blendPeerGrade re-flags outlier reviews of a submission and stores its peer average and
+// blended final grade. It does nothing for assignments without peer review. The final grade
+// stays empty while the teacher still has answers to grade (ungraded answers would count as 0);
+// grading them recalculates the submission, which blends again.
+func blendPeerGrade(tx *gorm.DB, submission *models.Submission) error {
+	var settings models.PeerReviewSettings
+	if err := tx.Where("assignment_id = ?", submission.AssignmentID).First(&settings).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil
+		}
+		return err
+	}
+
+	var reviews []models.PeerReview
+	if err := tx.Where("submission_id = ? AND status = ?", submission.ID, models.PeerReviewCompleted).Find(&reviews).Error; err != nil {
+		return err
+	}
+
+	percents := make([]float64, len(reviews))
+	for i, r := range reviews {
+		percents[i] = reviewPercent(r)
+	}
+	median := utils.Median(percents)
+
+	var included []float64
+	for i, r := range reviews {
+		outlier := len(reviews) >= minReviewsForOutliers && math.Abs(percents[i]-median) > settings.OutlierThreshold
+		if outlier != r.IsOutlier {
+			if err := tx.Model(&reviews[i]).Update("is_outlier", outlier).Error; err != nil {
+				return err
+			}
+		}
+		if !outlier || r.OutlierDismissed {
+			included = append(included, percents[i])
+		}
+	}
+
+	var peer, final *float64
+	if len(included) > 0 {
+		p := utils.Mean(included)
+		peer = &p
+	}
+	if peer != nil && !submission.NeedsGrading {
+		f := *peer
+		if submission.MaxScore > 0 {
+			teacher := float64(submission.Score) / float64(submission.MaxScore) * 100
+			f = teacher*(1-settings.PeerWeight/100) + *peer*settings.PeerWeight/100
+		}
+		final = &f
+	}
+
+	submission.PeerPercent, submission.FinalPercent = peer, final
+	return tx.Model(&models.Submission{}).Where("id = ?", submission.ID).
+		Updates(map[string]interface{}{"peer_percent": peer, "final_percent": final}).Error
+}
+
+// anonymousAnswer is what a reviewer sees of the submission under review
+type anonymousAnswer struct {
+	QuestionID      uint     `json:"question_id"`
+	QuestionText    string   `json:"question_text"`
+	Type            string   `json:"type"`
+	TextAnswer      string   `json:"text_answer,omitempty"`
+	SelectedOptions []string `json:"selected_options,omitempty"`
+}
+
+// reviewerView is a review as its reviewer sees it: no submission id, which would lead back to
+// the author through the submission listings
+type reviewerView struct {
+	ID           uint                 `json:"id"`
+	AssignmentID uint                 `json:"assignment_id"`
+	Status       string               `json:"status"`
+	Score        int                  `json:"score"`
+	MaxScore     int                  `json:"max_score"`
+	Comment      string               `json:"comment,omitempty"`
+	Scores       []models.RubricScore `json:"scores,omitempty"`
+	SubmittedAt  *time.Time           `json:"submitted_at,omitempty"`
+	CreatedAt    time.Time            `json:"created_at"`
+}
+
+func newReviewerView(r models.PeerReview) reviewerView {
+	return reviewerView{
+		ID: r.ID, AssignmentID: r.AssignmentID, Status: r.Status, Score: r.Score, MaxScore: r.MaxScore,
+		Comment: r.Comment, Scores: r.Scores, SubmittedAt: r.SubmittedAt, CreatedAt: r.CreatedAt,
+	}
+}
+
+// anonymousSubmission strips everything that identifies the author
+func anonymousSubmission(submissionID uint) ([]anonymousAnswer, error) {
+	var submission models.Submission
+	if err := database.DB.Preload("Answers", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Preload("Answers.Question.Options").First(&submission, submissionID).Error; err != nil {
+		return nil, err
+	}
+
+	answers := make([]anonymousAnswer, 0, len(submission.Answers))
+	for _, a := range submission.Answers {
+		view := anonymousAnswer{QuestionID: a.QuestionID, TextAnswer: a.TextAnswer}
+		if a.Question != nil {
+			view.QuestionText, view.Type = a.Question.Text, a.Question.Type
+			for _, id := range a.SelectedOptionIDs {
+				for _, o := range a.Question.Options {
+					if o.ID == id {
+						view.SelectedOptions = append(view.SelectedOptions, o.Text)
+					}
+				}
+			}
+		}
+		answers = append(answers, view)
+	}
+	return answers, nil
+}
+
+// GetPeerReviewSettings → GET /assignments/:id/peer-review
+func GetPeerReviewSettings(ctx *gin.Context) {
+	var settings models.PeerReviewSettings
+	if err := database.DB.Where("assignment_id = ?", ctx.Param("id")).First(&settings).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "peer review is not enabled for this assignment"})
+		return
+	}
+	ctx.JSON(200, gin.H{"settings": settings})
+}
+
+// SetPeerReviewSettings → PUT /assignments/:id/peer-review
+// Enables peer review or changes its settings. The rubric is fixed once reviews are allocated.
+func SetPeerReviewSettings(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only set up peer review for your own assignments"})
+		return
+	}
+
+	var input struct {
+		RubricID             uint       `json:"rubric_id" binding:"required"`
+		ReviewsPerSubmission int        `json:"reviews_per_submission" binding:"required,min=1,max=10"`
+		PeerWeight           *float64   `json:"peer_weight" binding:"omitempty,min=0,max=100"`
+		OutlierThreshold     *float64   `json:"outlier_threshold" binding:"omitempty,min=0,max=100"`
+		ReviewDueDate        *time.Time `json:"review_due_date"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	var rubric models.Rubric
+	if err := database.DB.First(&rubric, input.RubricID).Error; err != nil {
+		ctx.JSON(400, gin.H{"error": "rubric not found"})
+		return
+	}
+	if !canManageRubric(ctx, rubric) {
+		ctx.JSON(403, gin.H{"error": "you can only use your own rubrics"})
+		return
+	}
+
+	var settings models.PeerReviewSettings
+	if err := database.DB.Where("assignment_id = ?", assignment.ID).First(&settings).Error; err != nil && err != gorm.ErrRecordNotFound {
+		ctx.JSON(500, gin.H{"error": "failed to save peer review settings", "details": err.Error()})
+		return
+	}
+	if settings.AllocatedAt != nil && settings.RubricID != input.RubricID {
+		ctx.JSON(409, gin.H{"error": "reviews are already allocated, the rubric can no longer change"})
+		return
+	}
+	// Omitted fields keep the current value, or the default on a new row. peer_weight 0 (teacher
+	// grades only) is kept; outlier_threshold 0 would flag every review, so it means the default.
+	if input.PeerWeight != nil {
+		settings.PeerWeight = *input.PeerWeight
+	} else if settings.ID == 0 {
+		settings.PeerWeight = 50
+	}
+	if input.OutlierThreshold != nil && *input.OutlierThreshold > 0 {
+		settings.OutlierThreshold = *input.OutlierThreshold
+	} else if settings.OutlierThreshold == 0 {
+		settings.OutlierThreshold = 25
+	}
+
+	settings.AssignmentID = assignment.ID
+	settings.RubricID = input.RubricID
+	settings.ReviewsPerSubmission = input.ReviewsPerSubmission
+	settings.ReviewDueDate = input.ReviewDueDate
+	if err := database.DB.Save(&settings).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to save peer review settings", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "peer review settings saved", "settings": settings})
+}
+
+// AllocatePeerReviews → POST /assignments/:id/peer-review/allocate
+// After the deadline, hands each student's latest submission to N classmates.
+func AllocatePeerReviews(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only allocate reviews for your own assignments"})
+		return
+	}
+
+	var settings models.PeerReviewSettings
+	if err := database.DB.Where("assignment_id = ?", assignment.ID).First(&settings).Error; err != nil {
+		ctx.JSON(400, gin.H{"error": "peer review is not enabled for this assignment"})
+		return
+	}
+	if settings.AllocatedAt != nil {
+		ctx.JSON(409, gin.H{"error": "reviews are already allocated", "allocated_at": settings.AllocatedAt})
+		return
+	}
+	if assignment.DueDate == nil || time.Now().Before(*assignment.DueDate) {
+		ctx.JSON(400, gin.H{"error": "reviews can only be allocated after the assignment's due date"})
+		return
+	}
+
+	authors, _, err := latestSubmissions(assignment.ID, false)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch submissions", "details": err.Error()})
+		return
+	}
+	if len(authors) < 2 {
+		ctx.JSON(400, gin.H{"error": "at least 2 students must have submitted"})
+		return
+	}
+
+	// Small classes cannot give everyone N reviewers
+	n := settings.ReviewsPerSubmission
+	if n > len(authors)-1 {
+		n = len(authors) - 1
+	}
+
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	var reviews []models.PeerReview
+	for author, reviewers := range allocatePeerReviews(len(authors), n, rng) {
+		for _, reviewer := range reviewers {
+			reviews = append(reviews, models.PeerReview{
+				AssignmentID: assignment.ID,
+				SubmissionID: authors[author].ID,
+				ReviewerID:   authors[reviewer].UserID,
+				Status:       models.PeerReviewAssigned,
+			})
+		}
+	}
+
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&reviews).Error; err != nil {
+			return err
+		}
+		return tx.Model(&settings).Update("allocated_at", now).Error
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to allocate reviews", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(201, gin.H{
+		"message":                "reviews allocated",
+		"submissions":            len(authors),
+		"reviews_per_submission": n,
+		"reviews":                len(reviews),
+	})
+}
+
+// GetAssignmentPeerReviews → GET /assignments/:id/peer-reviews?flagged=true
+// Teacher view with reviewer identities and outlier flags.
+func GetAssignmentPeerReviews(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only view reviews of your own assignments"})
+		return
+	}
+
+	query := database.DB.Preload("Scores").Where("assignment_id = ?", assignment.ID)
+	if ctx.Query("flagged") == "true" {
+		query = query.Where("is_outlier = ? AND outlier_dismissed = ?", true, false)
+	}
+	var reviews []models.PeerReview
+	if err := query.Order("submission_id, id").Find(&reviews).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch reviews", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"reviews": reviews})
+}
+
+// GetMyPeerReviews → GET /peer-reviews/assigned
+// The reviews the current student has to write (authors are not shown).
+func GetMyPeerReviews(ctx *gin.Context) {
+	userID, ok := getContextUserID(ctx)
+	if !ok {
+		ctx.JSON(401, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var reviews []models.PeerReview
+	if err := database.DB.Where("reviewer_id = ?", userID).Order("assignment_id, id").Find(&reviews).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch reviews", "details": err.Error()})
+		return
+	}
+	views := make([]reviewerView, 0, len(reviews))
+	for _, r := range reviews {
+		views = append(views, newReviewerView(r))
+	}
+	ctx.JSON(200, gin.H{"reviews": views})
+}
+
+// GetPeerReview → GET /peer-reviews/:id
+// The reviewer (or the course teacher) gets the anonymised submission and the rubric.
+func GetPeerReview(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+
+	var review models.PeerReview
+	if err := database.DB.Preload("Scores").First(&review, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "review not found"})
+		return
+	}
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, review.AssignmentID).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	teacher := canGradeAssignment(ctx, assignment)
+	if review.ReviewerID != userID && !teacher {
+		ctx.JSON(403, gin.H{"error": "this review is not assigned to you"})
+		return
+	}
+
+	var settings models.PeerReviewSettings
+	if err := database.DB.Where("assignment_id = ?", assignment.ID).First(&settings).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "peer review is not enabled for this assignment"})
+		return
+	}
+	rubric, err := loadRubric(settings.RubricID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to load rubric", "details": err.Error()})
+		return
+	}
+	answers, err := anonymousSubmission(review.SubmissionID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to load submission", "details": err.Error()})
+		return
+	}
+
+	var view interface{} = newReviewerView(review)
+	if teacher {
+		view = review
+	}
+	ctx.JSON(200, gin.H{
+		"review":          view,
+		"answers":         answers,
+		"rubric":          rubric,
+		"review_due_date": settings.ReviewDueDate,
+	})
+}
+
+// SubmitPeerReview → POST /peer-reviews/:id/submit
+// Can be resubmitted until the review due date; the peer grade is updated each time.
+func SubmitPeerReview(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+
+	var review models.PeerReview
+	if err := database.DB.First(&review, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "review not found"})
+		return
+	}
+	if review.ReviewerID != userID {
+		ctx.JSON(403, gin.H{"error": "this review is not assigned to you"})
+		return
+	}
+
+	var settings models.PeerReviewSettings
+	if err := database.DB.Where("assignment_id = ?", review.AssignmentID).First(&settings).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "peer review is not enabled for this assignment"})
+		return
+	}
+	if settings.ReviewDueDate != nil && time.Now().After(*settings.ReviewDueDate) {
+		ctx.JSON(400, gin.H{"error": "the review due date has passed"})
+		return
+	}
+
+	var input struct {
+		Scores  []rubricPick `json:"scores" binding:"required,min=1,dive"`
+		Comment string       `json:"comment"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	rubric, err := loadRubric(settings.RubricID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to load rubric", "details": err.Error()})
+		return
+	}
+	scores, total, err := scoreRubric(rubric, input.Scores)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("peer_review_id = ?", review.ID).Delete(&models.RubricScore{}).Error; err != nil {
+			return err
+		}
+		for i := range scores {
+			scores[i].PeerReviewID = &review.ID
+		}
+		if err := tx.Create(&scores).Error; err != nil {
+			return err
+		}
+
+		review.Status, review.Score, review.MaxScore = models.PeerReviewCompleted, total, rubricMaxPoints(rubric)
+		review.Comment, review.SubmittedAt = input.Comment, &now
+		if err := tx.Model(&review).Updates(map[string]interface{}{
+			"status":       review.Status,
+			"score":        review.Score,
+			"max_score":    review.MaxScore,
+			"comment":      review.Comment,
+			"submitted_at": now,
+		}).Error; err != nil {
+			return err
+		}
+
+		var submission models.Submission
+		if err := tx.First(&submission, review.SubmissionID).Error; err != nil {
+			return err
+		}
+		return blendPeerGrade(tx, &submission)
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to save review", "details": err.Error()})
+		return
+	}
+
+	review.Scores = scores
+	ctx.JSON(200, gin.H{"message": "review submitted", "review": newReviewerView(review)})
+}
+
+// DismissPeerReviewOutlier → PUT /peer-reviews/:id/outlier
+// The teacher decides whether a flagged review still counts towards the peer grade.
+func DismissPeerReviewOutlier(ctx *gin.Context) {
+	var review models.PeerReview
+	if err := database.DB.First(&review, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "review not found"})
+		return
+	}
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, review.AssignmentID).Error; err != nil || !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only moderate reviews of your own assignments"})
+		return
+	}
+
+	var input struct {
+		Dismissed bool `json:"dismissed"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	var submission models.Submission
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&review).Update("outlier_dismissed", input.Dismissed).Error; err != nil {
+			return err
+		}
+		if err := tx.First(&submission, review.SubmissionID).Error; err != nil {
+			return err
+		}
+		return blendPeerGrade(tx, &submission)
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to update review", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{
+		"message":       "review updated",
+		"review":        review,
+		"peer_percent":  submission.PeerPercent,
+		"final_percent": submission.FinalPercent,
+	})
+}
+
+// GetSubmissionPeerReviews → GET /submissions/:id/peer-reviews
+// The author sees completed reviews without reviewer identities; the teacher sees everything.
+func GetSubmissionPeerReviews(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+
+	var submission models.Submission
+	if err := database.DB.First(&submission, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "submission not found"})
+		return
+	}
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, submission.AssignmentID).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+
+	isTeacher := canGradeAssignment(ctx, assignment)
+	if !isTeacher && submission.UserID != userID {
+		ctx.JSON(403, gin.H{"error": "you can only view reviews of your own submissions"})
+		return
+	}
+
+	query := database.DB.Preload("Scores").Where("submission_id = ?", submission.ID)
+	if !isTeacher {
+		query = query.Where("status = ?", models.PeerReviewCompleted)
+	}
+	var reviews []models.PeerReview
+	if err := query.Order("id").Find(&reviews).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch reviews", "details": err.Error()})
+		return
+	}
+	if !isTeacher {
+		for i := range reviews {
+			reviews[i].ReviewerID = 0
+		}
+	}
+
+	ctx.JSON(200, gin.H{
+		"reviews":       reviews,
+		"peer_percent":  submission.PeerPercent,
+		"final_percent": submission.FinalPercent,
+	})
+}
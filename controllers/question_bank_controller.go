@@ -0,0 +1,328 @@
+package controllers
+
+import (
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var validQuestionTypes = map[string]bool{
+	models.QuestionTypeMCQ:  true,
+	models.QuestionTypeText: true,
+}
+
+var validDifficulties = map[string]bool{
+	models.DifficultyEasy:   true,
+	models.DifficultyMedium: true,
+	models.DifficultyHard:   true,
+}
+
+// canManageBank → admin, the bank owner, the course teacher or a teacher of the bank's department
+func canManageBank(ctx *gin.Context, bank models.QuestionBank) bool {
+	userID, ok := getContextUserID(ctx)
+	if !ok {
+		return false
+	}
+	if getUserRole(ctx) == "admin" || bank.OwnerID == userID {
+		return true
+	}
+
+	if bank.CourseID != nil {
+		var course models.Course
+		if err := database.DB.First(&course, *bank.CourseID).Error; err == nil && course.TeacherID == userID {
+			return true
+		}
+	}
+	if bank.DepartmentID != nil {
+		var teacherProfile models.TeacherProfile
+		if err := database.DB.Where("user_id = ?", userID).First(&teacherProfile).Error; err == nil &&
+			teacherProfile.DepartmentID != nil && *teacherProfile.DepartmentID == *bank.DepartmentID {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateQuestionBank → POST /question-banks
+func CreateQuestionBank(ctx *gin.Context) {
+	var input struct {
+		Title        string `json:"title" binding:"required"`
+		Description  string `json:"description"`
+		CourseID     *uint  `json:"course_id"`
+		DepartmentID *uint  `json:"department_id"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	// A bank belongs either to a course or to a department, never both
+	if (input.CourseID == nil) == (input.DepartmentID == nil) {
+		ctx.JSON(400, gin.H{"error": "exactly one of course_id or department_id is required"})
+		return
+	}
+	if input.CourseID != nil {
+		var course models.Course
+		if err := database.DB.First(&course, *input.CourseID).Error; err != nil {
+			ctx.JSON(404, gin.H{"error": "course not found"})
+			return
+		}
+	} else {
+		var dept models.Department
+		if err := database.DB.First(&dept, *input.DepartmentID).Error; err != nil {
+			ctx.JSON(404, gin.H{"error": "department not found"})
+			return
+		}
+	}
+
+	userID, _ := getContextUserID(ctx)
+	bank := models.QuestionBank{
+		Title:        input.Title,
+		Description:  input.Description,
+		CourseID:     input.CourseID,
+		DepartmentID: input.DepartmentID,
+	}
+
+	// Ownership check runs before OwnerID is set so the creator must actually teach the course/department
+	if !canManageBank(ctx, bank) {
+		ctx.JSON(403, gin.H{"error": "you can only create banks for your own courses or department"})
+		return
+	}
+	bank.OwnerID = userID
+
+	if err := database.DB.Create(&bank).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to create question bank", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(201, gin.H{"message": "question bank created successfully", "bank": bank})
+}
+
+// GetQuestionBanks → GET /question-banks?course_id=&department_id=
+func GetQuestionBanks(ctx *gin.Context) {
+	query := database.DB.Model(&models.QuestionBank{})
+	if courseID := ctx.Query("course_id"); courseID != "" {
+		query = query.Where("course_id = ?", courseID)
+	}
+	if deptID := ctx.Query("department_id"); deptID != "" {
+		query = query.Where("department_id = ?", deptID)
+	}
+
+	var banks []models.QuestionBank
+	if err := query.Find(&banks).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch question banks", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"banks": banks})
+}
+
+// GetQuestionBankByID → GET /question-banks/:id
+// Includes the answer key, so only the bank's managers can read it.
+func GetQuestionBankByID(ctx *gin.Context) {
+	var bank models.QuestionBank
+	if err := database.DB.Preload("Questions.Options").First(&bank, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "question bank not found"})
+		return
+	}
+	if !canManageBank(ctx, bank) {
+		ctx.JSON(403, gin.H{"error": "you can only view your own question banks"})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"bank": bank})
+}
+
+// UpdateQuestionBank → PUT /question-banks/:id
+func UpdateQuestionBank(ctx *gin.Context) {
+	var bank models.QuestionBank
+	if err := database.DB.First(&bank, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "question bank not found"})
+		return
+	}
+	if !canManageBank(ctx, bank) {
+		ctx.JSON(403, gin.H{"error": "you can only update your own question banks"})
+		return
+	}
+
+	var input struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	if input.Title != "" {
+		bank.Title = input.Title
+	}
+	if input.Description != "" {
+		bank.Description = input.Description
+	}
+
+	if err := database.DB.Save(&bank).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to update question bank", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"message": "question bank updated successfully", "bank": bank})
+}
+
+// DeleteQuestionBank → DELETE /question-banks/:id
+func DeleteQuestionBank(ctx *gin.Context) {
+	var bank models.QuestionBank
+	if err := database.DB.First(&bank, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "question bank not found"})
+		return
+	}
+	if !canManageBank(ctx, bank) {
+		ctx.JSON(403, gin.H{"error": "you can only delete your own question banks"})
+		return
+	}
+
+	if err := database.DB.Delete(&bank).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to delete question bank", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(200, gin.H{"message": "question bank deleted successfully"})
+}
+
+// AddBankQuestion → POST /question-banks/:id/questions
+// Creates the question and all of its options in one go.
+func AddBankQuestion(ctx *gin.Context) {
+	var bank models.QuestionBank
+	if err := database.DB.First(&bank, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "question bank not found"})
+		return
+	}
+	if !canManageBank(ctx, bank) {
+		ctx.JSON(403, gin.H{"error": "you can only add questions to your own question banks"})
+		return
+	}
+
+	var input struct {
+		Text       string   `json:"text" binding:"required"`
+		Type       string   `json:"type"`
+		Points     int      `json:"points"`
+		Difficulty string   `json:"difficulty"`
+		Tags       []string `json:"tags"`
+		Feedback   string   `json:"feedback"`
+		Options    []struct {
+			Text      string `json:"text" binding:"required"`
+			IsCorrect bool   `json:"is_correct"`
+		} `json:"options" binding:"dive"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	if input.Difficulty == "" {
+		input.Difficulty = models.DifficultyMedium
+	}
+	if !validDifficulties[input.Difficulty] {
+		ctx.JSON(400, gin.H{"error": "difficulty must be one of easy, medium, hard"})
+		return
+	}
+
+	if input.Type == "" {
+		input.Type = models.QuestionTypeMCQ
+	}
+	if !validQuestionTypes[input.Type] {
+		ctx.JSON(400, gin.H{"error": "type must be one of mcq, text"})
+		return
+	}
+	if input.Type == models.QuestionTypeMCQ && len(input.Options) < 2 {
+		ctx.JSON(400, gin.H{"error": "mcq questions need at least 2 options"})
+		return
+	}
+	if input.Points <= 0 {
+		input.Points = 1
+	}
+
+	question := models.Question{
+		BankID:     &bank.ID,
+		Type:       input.Type,
+		Points:     input.Points,
+		Text:       input.Text,
+		Difficulty: input.Difficulty,
+		Tags:       utils.JoinTags(input.Tags),
+		Feedback:   input.Feedback,
+	}
+
+	for _, o := range input.Options {
+		question.Options = append(question.Options, models.Option{Text: o.Text, IsCorrect: o.IsCorrect})
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		return createQuestionWithOptions(tx, &question)
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to create question", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(201, gin.H{"message": "question added to bank", "question": question})
+}
+
+// createQuestionWithOptions inserts a question and its options and points CorrectOption
+// at the first correct one. Call it inside a transaction.
+func createQuestionWithOptions(tx *gorm.DB, question *models.Question) error {
+	options := question.Options
+	question.Options = nil
+	if err := tx.Create(question).Error; err != nil {
+		return err
+	}
+	for _, option := range options {
+		option.ID = 0
+		option.QuestionID = question.ID
+		if err := tx.Create(&option).Error; err != nil {
+			return err
+		}
+		if option.IsCorrect && question.CorrectOption == 0 {
+			question.CorrectOption = option.ID
+		}
+		question.Options = append(question.Options, option)
+	}
+	return tx.Model(question).Update("correct_option", question.CorrectOption).Error
+}
+
+// GetBankQuestions → GET /question-banks/:id/questions?tag=&difficulty=
+func GetBankQuestions(ctx *gin.Context) {
+	var bank models.QuestionBank
+	if err := database.DB.First(&bank, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "question bank not found"})
+		return
+	}
+	if !canManageBank(ctx, bank) {
+		ctx.JSON(403, gin.H{"error": "you can only view your own question banks"})
+		return
+	}
+
+	query := database.DB.Preload("Options").Where("bank_id = ?", bank.ID)
+	if difficulty := ctx.Query("difficulty"); difficulty != "" {
+		query = query.Where("difficulty = ?", difficulty)
+	}
+
+	var questions []models.Question
+	if err := query.Find(&questions).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch questions", "details": err.Error()})
+		return
+	}
+
+	if tag := ctx.Query("tag"); tag != "" {
+		filtered := questions[:0]
+		for _, q := range questions {
+			if utils.HasTag(q.Tags, tag) {
+				filtered = append(filtered, q)
+			}
+		}
+		questions = filtered
+	}
+
+	ctx.JSON(200, gin.H{"questions": questions})
+}
@@ -0,0 +1,203 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxImportSize caps uploaded question files (QTI packages can carry images)
+const maxImportSize = 10 << 20
+
+// ImportAssignmentQuestions → POST /assignments/:id/questions/import?format=csv|gift|qti
+func ImportAssignmentQuestions(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only import into your own assignments"})
+		return
+	}
+
+	importQuestions(ctx, func(q *models.Question) { q.AssignmentID = &assignment.ID })
+}
+
+// ExportAssignmentQuestions → GET /assignments/:id/questions/export?format=csv|gift|qti
+func ExportAssignmentQuestions(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only export your own assignments"})
+		return
+	}
+
+	var questions []models.Question
+	if err := database.DB.Preload("Options").Where("assignment_id = ?", assignment.ID).
+		Order("id").Find(&questions).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch questions", "details": err.Error()})
+		return
+	}
+
+	exportQuestions(ctx, assignment.Title, fmt.Sprintf("assignment_%d", assignment.ID), questions)
+}
+
+// ImportBankQuestions → POST /question-banks/:id/import?format=csv|gift|qti
+func ImportBankQuestions(ctx *gin.Context) {
+	var bank models.QuestionBank
+	if err := database.DB.First(&bank, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "question bank not found"})
+		return
+	}
+	if !canManageBank(ctx, bank) {
+		ctx.JSON(403, gin.H{"error": "you can only import into your own question banks"})
+		return
+	}
+
+	importQuestions(ctx, func(q *models.Question) { q.BankID = &bank.ID })
+}
+
+// ExportBankQuestions → GET /question-banks/:id/export?format=csv|gift|qti
+func ExportBankQuestions(ctx *gin.Context) {
+	var bank models.QuestionBank
+	if err := database.DB.Preload("Questions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Preload("Questions.Options").First(&bank, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "question bank not found"})
+		return
+	}
+	if !canManageBank(ctx, bank) {
+		ctx.JSON(403, gin.H{"error": "you can only export your own question banks"})
+		return
+	}
+
+	exportQuestions(ctx, bank.Title, fmt.Sprintf("bank_%d", bank.ID), bank.Questions)
+}
+
+// importQuestions reads the uploaded "file", validates every question and only then
+// writes them all in a single transaction. attach sets the owning assignment/bank.
+func importQuestions(ctx *gin.Context, attach func(q *models.Question)) {
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "file is required", "details": err.Error()})
+		return
+	}
+	if fileHeader.Size > maxImportSize {
+		ctx.JSON(400, gin.H{"error": "file too large", "details": fmt.Sprintf("max %d MB", maxImportSize>>20)})
+		return
+	}
+
+	format := strings.ToLower(ctx.Query("format"))
+	if format == "" {
+		format = quizFormatFromFilename(fileHeader.Filename)
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "failed to read file", "details": err.Error()})
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, maxImportSize))
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "failed to read file", "details": err.Error()})
+		return
+	}
+
+	parsed, errs := utils.ParseQuiz(format, data)
+
+	// Difficulty is validated here because the allowed values live with the models
+	questions := make([]models.Question, 0, len(parsed))
+	for _, p := range parsed {
+		if p.Difficulty == "" {
+			p.Difficulty = models.DifficultyMedium
+		}
+		if !validDifficulties[p.Difficulty] {
+			errs = append(errs, utils.QuizImportError{File: p.File, Line: p.Line, Message: "difficulty must be one of easy, medium, hard"})
+			continue
+		}
+
+		q := models.Question{
+			Type:       models.QuestionTypeMCQ,
+			Points:     1,
+			Text:       p.Text,
+			Difficulty: p.Difficulty,
+			Tags:       utils.JoinTags(p.Tags),
+		}
+		attach(&q)
+		for _, o := range p.Options {
+			q.Options = append(q.Options, models.Option{Text: o.Text, IsCorrect: o.IsCorrect})
+		}
+		questions = append(questions, q)
+	}
+
+	if len(errs) > 0 {
+		ctx.JSON(422, gin.H{"error": "import file has errors, nothing was imported", "errors": errs})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		for i := range questions {
+			if err := createQuestionWithOptions(tx, &questions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to import questions", "details": err.Error()})
+		return
+	}
+
+	ctx.JSON(201, gin.H{"message": "questions imported successfully", "imported": len(questions), "questions": questions})
+}
+
+// exportQuestions renders questions in the requested format as a download
+func exportQuestions(ctx *gin.Context, title, baseName string, questions []models.Question) {
+	format := strings.ToLower(ctx.DefaultQuery("format", utils.QuizFormatCSV))
+
+	quiz := make([]utils.QuizQuestion, 0, len(questions))
+	for _, q := range questions {
+		if q.Type == models.QuestionTypeText {
+			continue // the interchange formats here only carry choice questions
+		}
+		item := utils.QuizQuestion{Text: q.Text, Difficulty: q.Difficulty, Tags: utils.SplitTags(q.Tags)}
+		for _, o := range q.Options {
+			item.Options = append(item.Options, utils.QuizOption{Text: o.Text, IsCorrect: o.IsCorrect})
+		}
+		quiz = append(quiz, item)
+	}
+
+	body, contentType, ext, err := utils.ExportQuiz(format, title, quiz)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "failed to export questions", "details": err.Error()})
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.%s", baseName, ext))
+	ctx.Data(200, contentType, body)
+}
+
+// quizFormatFromFilename guesses the import format from the upload's extension
+func quizFormatFromFilename(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return utils.QuizFormatCSV
+	case ".gift", ".txt":
+		return utils.QuizFormatGIFT
+	case ".zip", ".xml":
+		return utils.QuizFormatQTI
+	}
+	return ""
+}
@@ -0,0 +1,349 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	jobTypeReconciliation = "payment_reconciliation"
+
+	maxSettlementReportSize = 10 << 20
+)
+
+// settledStatuses are the local statuses of a payment the gateway has collected
+var settledStatuses = []string{models.PaymentSuccess, models.PaymentPartiallyRefunded, models.PaymentRefunded}
+
+// settlementRow is one payment or refund line of a gateway settlement report (amounts in rupees)
+type settlementRow struct {
+	Type      string
+	EntityID  string
+	OrderID   string
+	PaymentID string
+	Amount    float64
+	CreatedAt *time.Time
+}
+
+// settlementTimeFormats are the timestamp layouts seen in settlement exports
+var settlementTimeFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02", "02/01/2006 15:04:05", "02/01/2006"}
+
+func parseSettlementTime(v string) *time.Time {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return nil
+	}
+	if unix, err := strconv.ParseInt(v, 10, 64); err == nil {
+		t := time.Unix(unix, 0)
+		return &t
+	}
+	for _, layout := range settlementTimeFormats {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+// parseSettlementReport reads the CSV by header name (entity_id, type, amount required; order_id,
+// payment_id, created_at optional). Lines other than payments and refunds are skipped.
+func parseSettlementReport(data []byte) ([]settlementRow, int, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read header: %v", err)
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, required := range []string{"entity_id", "type", "amount"} {
+		if _, ok := col[required]; !ok {
+			return nil, 0, fmt.Errorf("missing column %q", required)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := col[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rows []settlementRow
+	skipped := 0
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %d: %v", line, err)
+		}
+		row := settlementRow{
+			Type:      strings.ToLower(field(record, "type")),
+			EntityID:  field(record, "entity_id"),
+			OrderID:   field(record, "order_id"),
+			PaymentID: field(record, "payment_id"),
+			CreatedAt: parseSettlementTime(field(record, "created_at")),
+		}
+		if row.Type != "payment" && row.Type != "refund" {
+			skipped++
+			continue
+		}
+		if row.EntityID == "" {
+			return nil, 0, fmt.Errorf("line %d: entity_id is empty", line)
+		}
+		if row.Amount, err = strconv.ParseFloat(strings.ReplaceAll(field(record, "amount"), ",", ""), 64); err != nil {
+			return nil, 0, fmt.Errorf("line %d: invalid amount %q", line, field(record, "amount"))
+		}
+		rows = append(rows, row)
+	}
+	return rows, skipped, nil
+}
+
+func moneyDiffers(a, b float64) bool {
+	return math.Abs(a-b) >= 0.005
+}
+
+// reconcileRow compares a report line with the local records; nil means it matches
+func reconcileRow(row settlementRow, seen map[uint]bool) *models.ReconciliationItem {
+	item := &models.ReconciliationItem{Type: row.Type, EntityID: row.EntityID, OrderID: row.OrderID, ReportAmount: &row.Amount}
+
+	if row.Type == "refund" {
+		var request models.RefundRequest
+		if err := database.DB.Where("gateway_refund_id = ?", row.EntityID).First(&request).Error; err != nil {
+			item.Issue, item.Detail = models.ReconMissingLocally, "refund not recorded here"
+			var payment models.Payment
+			if row.PaymentID != "" && database.DB.Where("gateway_payment_id = ?", row.PaymentID).First(&payment).Error == nil {
+				item.PaymentID = &payment.ID
+			}
+			return item
+		}
+		item.PaymentID, item.LocalAmount, item.LocalStatus = &request.PaymentID, &request.Amount, request.Status
+		switch {
+		case request.Status != models.RefundProcessed:
+			item.Issue, item.Detail = models.ReconStatusMismatch, "gateway refunded, but the request here is "+request.Status
+		case moneyDiffers(request.Amount, row.Amount):
+			item.Issue, item.Detail = models.ReconAmountMismatch, fmt.Sprintf("refunded %.2f here, %.2f at the gateway", request.Amount, row.Amount)
+		default:
+			return nil
+		}
+		return item
+	}
+
+	var payment models.Payment
+	query := database.DB.Where("gateway_payment_id = ?", row.EntityID)
+	if row.OrderID != "" {
+		query = database.DB.Where("gateway_payment_id = ? OR transaction_id = ?", row.EntityID, row.OrderID)
+	}
+	if err := query.First(&payment).Error; err != nil {
+		item.Issue, item.Detail = models.ReconMissingLocally, "payment not recorded here"
+		return item
+	}
+	seen[payment.ID] = true
+	item.PaymentID, item.LocalAmount, item.LocalStatus = &payment.ID, &payment.Amount, payment.Status
+
+	settled := false
+	for _, s := range settledStatuses {
+		settled = settled || payment.Status == s
+	}
+	switch {
+	case !settled:
+		item.Issue, item.Detail = models.ReconStatusMismatch, "gateway settled a payment that is "+payment.Status+" here"
+	case moneyDiffers(payment.Amount, row.Amount):
+		item.Issue, item.Detail = models.ReconAmountMismatch, fmt.Sprintf("charged %.2f here, %.2f at the gateway", payment.Amount, row.Amount)
+	default:
+		return nil
+	}
+	return item
+}
+
+// runReconciliation checks every report line, then flags local payments from the report's
+// period that the report does not contain
+func runReconciliation(job *models.Job, run models.ReconciliationRun, rows []settlementRow) (string, error) {
+	job.Total = len(rows)
+	run.JobID = job.ID
+	if err := database.DB.Create(&run).Error; err != nil {
+		return "", err
+	}
+
+	seen := map[uint]bool{}
+	var items []models.ReconciliationItem
+	for i, row := range rows {
+		if item := reconcileRow(row, seen); item != nil {
+			item.RunID = run.ID
+			items = append(items, *item)
+		} else {
+			run.Matched++
+		}
+		jobProgress(job, i+1)
+	}
+
+	if run.PeriodFrom != nil && run.PeriodTo != nil {
+		var paid []models.Payment
+		if err := database.DB.Where("status IN ? AND gateway <> ? AND paid_at BETWEEN ? AND ?", settledStatuses, "none", run.PeriodFrom, run.PeriodTo).
+			Find(&paid).Error; err != nil {
+			return "", err
+		}
+		for _, p := range paid {
+			if seen[p.ID] {
+				continue
+			}
+			amount := p.Amount
+			items = append(items, models.ReconciliationItem{
+				RunID: run.ID, Issue: models.ReconMissingInReport, Type: "payment",
+				EntityID: p.GatewayPaymentID, OrderID: p.TransactionID, PaymentID: &p.ID,
+				LocalAmount: &amount, LocalStatus: p.Status, Detail: "paid here but not in the settlement report",
+			})
+		}
+	}
+
+	if len(items) > 0 {
+		if err := database.DB.CreateInBatches(&items, 200).Error; err != nil {
+			return "", err
+		}
+	}
+	run.Rows, run.Mismatched = len(rows), len(items)
+	if err := database.DB.Model(&run).Updates(map[string]interface{}{"rows": run.Rows, "matched": run.Matched, "mismatched": run.Mismatched}).Error; err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("run %d: %d lines, %d matched, %d flagged", run.ID, run.Rows, run.Matched, run.Mismatched), nil
+}
+
+// ImportSettlementReport → POST /payments/reconciliation (admin, multipart: file, from, to)
+// Compares a gateway settlement CSV with local payments and refunds in a background job. from/to
+// (YYYY-MM-DD) bound the check for payments missing from the report; by default the report's
+// own created_at range is used.
+func ImportSettlementReport(ctx *gin.Context) {
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "file is required", "details": err.Error()})
+		return
+	}
+	if fileHeader.Size > maxSettlementReportSize {
+		ctx.JSON(400, gin.H{"error": "file is larger than 10 MB"})
+		return
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "failed to read file", "details": err.Error()})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(file, maxSettlementReportSize))
+	file.Close()
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "failed to read file", "details": err.Error()})
+		return
+	}
+
+	rows, skipped, err := parseSettlementReport(data)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid settlement report", "details": err.Error()})
+		return
+	}
+
+	adminID, _ := getContextUserID(ctx)
+	run := models.ReconciliationRun{FileName: fileHeader.Filename, CreatedBy: adminID}
+	for _, row := range rows {
+		if row.CreatedAt == nil {
+			continue
+		}
+		if run.PeriodFrom == nil || row.CreatedAt.Before(*run.PeriodFrom) {
+			run.PeriodFrom = row.CreatedAt
+		}
+		if run.PeriodTo == nil || row.CreatedAt.After(*run.PeriodTo) {
+			run.PeriodTo = row.CreatedAt
+		}
+	}
+	if from := ctx.PostForm("from"); from != "" {
+		t, err := time.ParseInLocation("2006-01-02", from, time.Local)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+		run.PeriodFrom = &t
+	}
+	if to := ctx.PostForm("to"); to != "" {
+		t, err := time.ParseInLocation("2006-01-02", to, time.Local)
+		if err != nil {
+			ctx.JSON(400, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+		end := t.AddDate(0, 0, 1).Add(-time.Second)
+		run.PeriodTo = &end
+	}
+
+	job, err := startJob(jobTypeReconciliation, adminID, func(job *models.Job) (string, error) {
+		return runReconciliation(job, run, rows)
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to start reconciliation", "details": err.Error()})
+		return
+	}
+	ctx.JSON(202, gin.H{"message": "reconciliation started", "job": job, "lines": len(rows), "skipped_lines": skipped})
+}
+
+// GetReconciliationRuns → GET /payments/reconciliation (admin)
+func GetReconciliationRuns(ctx *gin.Context) {
+	var runs []models.ReconciliationRun
+	if err := database.DB.Order("id DESC").Limit(100).Find(&runs).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch reconciliation runs", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"runs": runs})
+}
+
+// GetReconciliationRun → GET /payments/reconciliation/:run_id?unresolved=true (admin)
+func GetReconciliationRun(ctx *gin.Context) {
+	var run models.ReconciliationRun
+	if err := database.DB.First(&run, ctx.Param("run_id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "reconciliation run not found"})
+		return
+	}
+	query := database.DB.Where("run_id = ?", run.ID).Order("id")
+	if ctx.Query("unresolved") == "true" {
+		query = query.Where("resolved_at IS NULL")
+	}
+	if err := query.Find(&run.Items).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch reconciliation items", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"run": run})
+}
+
+// ResolveReconciliationItem → POST /payments/reconciliation/items/:item_id/resolve {resolution} (admin)
+func ResolveReconciliationItem(ctx *gin.Context) {
+	var input struct {
+		Resolution string `json:"resolution" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "resolution is required", "details": err.Error()})
+		return
+	}
+
+	var item models.ReconciliationItem
+	if err := database.DB.First(&item, ctx.Param("item_id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "reconciliation item not found"})
+		return
+	}
+	adminID, _ := getContextUserID(ctx)
+	now := time.Now()
+	item.ResolvedBy, item.ResolvedAt, item.Resolution = &adminID, &now, input.Resolution
+	if err := database.DB.Save(&item).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to resolve item", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "item resolved", "item": item})
+}
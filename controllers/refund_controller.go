@@ -0,0 +1,333 @@
+package controllers
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// refundable → what is left of a payment to refund
+func refundable(payment models.Payment) float64 {
+	if payment.Status != models.PaymentSuccess && payment.Status != models.PaymentPartiallyRefunded {
+		return 0
+	}
+	return roundMoney(payment.Amount - payment.RefundedAmount)
+}
+
+// applyRefund adds a processed refund to its payment and, unless keepEnrollment, suspends the
+// student's enrollment in the course
+func applyRefund(tx *gorm.DB, paymentID uint, amount float64, keepEnrollment bool) (models.Payment, error) {
+	var payment models.Payment
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&payment, paymentID).Error; err != nil {
+		return payment, err
+	}
+
+	now := time.Now()
+	payment.RefundedAmount = roundMoney(payment.RefundedAmount + amount)
+	payment.RefundedAt = &now
+	payment.Status = models.PaymentPartiallyRefunded
+	if payment.RefundedAmount >= payment.Amount {
+		payment.Status = models.PaymentRefunded
+	}
+	if err := tx.Save(&payment).Error; err != nil {
+		return payment, err
+	}
+
+	if !keepEnrollment {
+		if err := tx.Model(&models.Enrollment{}).
+			Where("user_id = ? AND course_id = ? AND status <> ?", payment.UserID, payment.CourseID, models.EnrollmentSuspended).
+			Update("status", models.EnrollmentSuspended).Error; err != nil {
+			return payment, err
+		}
+	}
+	return payment, nil
+}
+
+// completeRefund marks a request processed and applies it, once. Both the approval and the
+// gateway's refund.processed webhook call it, in whichever order they arrive.
+func completeRefund(tx *gorm.DB, requestID uint, gatewayRefundID string) (bool, error) {
+	var request models.RefundRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, requestID).Error; err != nil {
+		return false, err
+	}
+	if request.Status == models.RefundProcessed {
+		return false, nil
+	}
+
+	now := time.Now()
+	if err := tx.Model(&request).Updates(map[string]interface{}{
+		"status":            models.RefundProcessed,
+		"gateway_refund_id": gatewayRefundID,
+		"processed_at":      now,
+		"error":             "",
+	}).Error; err != nil {
+		return false, err
+	}
+	payment, err := applyRefund(tx, request.PaymentID, request.Amount, request.KeepEnrollment)
+	if err != nil {
+		return false, err
+	}
+	request.GatewayRefundID, request.ProcessedAt = gatewayRefundID, &now
+	return true, postRefundLedger(tx, payment, request)
+}
+
+// recordGatewayRefund handles refund.processed: it completes the matching in-flight request, or
+// records a refund made outside the platform (e.g. from the gateway dashboard)
+func recordGatewayRefund(tx *gorm.DB, payment models.Payment, event utils.WebhookEvent) (bool, error) {
+	var request models.RefundRequest
+	if event.RefundID != "" && tx.Where("gateway_refund_id = ?", event.RefundID).First(&request).Error == nil {
+		return completeRefund(tx, request.ID, event.RefundID)
+	}
+
+	amount := utils.FromMinorUnits(event.Amount)
+	err := tx.Where("payment_id = ? AND status = ? AND amount = ?", payment.ID, models.RefundProcessing, amount).
+		Order("id").First(&request).Error
+	if err == gorm.ErrRecordNotFound {
+		request = models.RefundRequest{
+			PaymentID: payment.ID,
+			UserID:    payment.UserID,
+			Amount:    amount,
+			Reason:    "refunded at the payment gateway",
+			Status:    models.RefundProcessing,
+		}
+		err = tx.Create(&request).Error
+	}
+	if err != nil {
+		return false, err
+	}
+	return completeRefund(tx, request.ID, event.RefundID)
+}
+
+// RequestRefund → POST /payments/:id/refunds {amount, reason} (owner/admin)
+// amount defaults to everything not yet refunded.
+func RequestRefund(ctx *gin.Context) {
+	payment, ok := loadOwnPayment(ctx)
+	if !ok {
+		return
+	}
+	var input struct {
+		Amount *float64 `json:"amount"`
+		Reason string   `json:"reason" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+
+	available := refundable(payment)
+	if available <= 0 {
+		ctx.JSON(400, gin.H{"error": "nothing to refund on this payment", "status": payment.Status})
+		return
+	}
+	amount := available
+	if input.Amount != nil {
+		amount = roundMoney(*input.Amount)
+	}
+	if amount <= 0 || amount > available {
+		ctx.JSON(400, gin.H{"error": fmt.Sprintf("amount must be between 0 and %.2f", available)})
+		return
+	}
+
+	var open int64
+	database.DB.Model(&models.RefundRequest{}).
+		Where("payment_id = ? AND status IN ?", payment.ID, []string{models.RefundRequested, models.RefundProcessing, models.RefundFailed}).
+		Count(&open)
+	if open > 0 {
+		ctx.JSON(409, gin.H{"error": "a refund request for this payment is already open"})
+		return
+	}
+
+	userID, _ := getContextUserID(ctx)
+	request := models.RefundRequest{PaymentID: payment.ID, UserID: userID, Amount: amount, Reason: input.Reason, Status: models.RefundRequested}
+	if err := database.DB.Create(&request).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to create refund request", "details": err.Error()})
+		return
+	}
+	ctx.JSON(201, gin.H{"message": "refund requested", "refund": request})
+}
+
+// GetRefundRequests → GET /refunds?status=&payment_id=
+// Admins see every request; others see their own.
+func GetRefundRequests(ctx *gin.Context) {
+	query := database.DB.Preload("Payment").Order("id DESC")
+	if getUserRole(ctx) != "admin" {
+		userID, _ := getContextUserID(ctx)
+		query = query.Where("user_id = ?", userID)
+	}
+	if status := ctx.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if paymentID := ctx.Query("payment_id"); paymentID != "" {
+		query = query.Where("payment_id = ?", paymentID)
+	}
+
+	var requests []models.RefundRequest
+	if err := query.Find(&requests).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch refund requests", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"refunds": requests})
+}
+
+// ApproveRefund → POST /refunds/:id/approve {amount, note, keep_enrollment} (admin)
+// amount lowers the refund (partial refund); the request moves to processing before the gateway
+// is called, so approving twice cannot refund twice. A failed request can be approved again.
+func ApproveRefund(ctx *gin.Context) {
+	var input struct {
+		Amount         *float64 `json:"amount"`
+		Note           string   `json:"note"`
+		KeepEnrollment bool     `json:"keep_enrollment"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid input", "details": err.Error()})
+		return
+	}
+	adminID, _ := getContextUserID(ctx)
+
+	var request models.RefundRequest
+	var payment models.Payment
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, ctx.Param("id")).Error; err != nil {
+			return err
+		}
+		if request.Status != models.RefundRequested && request.Status != models.RefundFailed {
+			return fmt.Errorf("refund request is %s", request.Status)
+		}
+		if err := tx.First(&payment, request.PaymentID).Error; err != nil {
+			return err
+		}
+		if input.Amount != nil {
+			request.Amount = roundMoney(*input.Amount)
+		}
+		if available := refundable(payment); request.Amount <= 0 || request.Amount > available {
+			return fmt.Errorf("amount must be between 0 and %.2f", available)
+		}
+
+		now := time.Now()
+		request.Status, request.ReviewedBy, request.ReviewedAt = models.RefundProcessing, &adminID, &now
+		// Duplicate-payment refunds are opened with the enrollment kept
+		request.ReviewNote, request.KeepEnrollment, request.Error = input.Note, request.KeepEnrollment || input.KeepEnrollment, ""
+		return tx.Save(&request).Error
+	})
+	if err == gorm.ErrRecordNotFound {
+		ctx.JSON(404, gin.H{"error": "refund request not found"})
+		return
+	}
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": "refund cannot be approved", "details": err.Error()})
+		return
+	}
+
+	refund, err := refundThroughGateway(payment, request)
+	if err != nil {
+		database.DB.Model(&request).Updates(map[string]interface{}{"status": models.RefundFailed, "error": err.Error()})
+		ctx.JSON(502, gin.H{"error": "gateway refund failed", "details": err.Error()})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		_, err := completeRefund(tx, request.ID, refund.RefundID)
+		return err
+	})
+	if err != nil {
+		// The gateway has the money back; the refund.processed webhook will record it
+		fmt.Println("ERROR: refund", request.ID, "processed at the gateway but not recorded:", err)
+		ctx.JSON(500, gin.H{"error": "refund processed but not recorded", "details": err.Error()})
+		return
+	}
+
+	database.DB.Preload("Payment").First(&request, request.ID)
+	ctx.JSON(200, gin.H{"message": "refund processed", "refund": request})
+}
+
+// refundThroughGateway asks the gateway that took the payment to return the amount
+func refundThroughGateway(payment models.Payment, request models.RefundRequest) (utils.Refund, error) {
+	gw, err := utils.Gateway()
+	if err != nil {
+		return utils.Refund{}, err
+	}
+	if payment.Gateway != gw.Name() {
+		return utils.Refund{}, fmt.Errorf("payment was taken by the %q gateway, but %q is configured", payment.Gateway, gw.Name())
+	}
+	if payment.GatewayPaymentID == "" {
+		return utils.Refund{}, fmt.Errorf("payment has no gateway payment id")
+	}
+	return gw.Refund(payment.GatewayPaymentID, utils.ToMinorUnits(request.Amount), map[string]string{"refund_request_id": fmt.Sprint(request.ID)})
+}
+
+// RejectRefund → POST /refunds/:id/reject {note} (admin)
+func RejectRefund(ctx *gin.Context) {
+	var input struct {
+		Note string `json:"note" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "a note explaining the rejection is required", "details": err.Error()})
+		return
+	}
+
+	var request models.RefundRequest
+	if err := database.DB.First(&request, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "refund request not found"})
+		return
+	}
+	if request.Status != models.RefundRequested && request.Status != models.RefundFailed {
+		ctx.JSON(400, gin.H{"error": fmt.Sprintf("refund request is %s", request.Status)})
+		return
+	}
+
+	adminID, _ := getContextUserID(ctx)
+	now := time.Now()
+	res := database.DB.Model(&models.RefundRequest{}).
+		Where("id = ? AND status IN ?", request.ID, []string{models.RefundRequested, models.RefundFailed}).
+		Updates(map[string]interface{}{"status": models.RefundRejected, "reviewed_by": adminID, "reviewed_at": now, "review_note": input.Note})
+	if res.Error != nil {
+		ctx.JSON(500, gin.H{"error": "failed to reject refund", "details": res.Error.Error()})
+		return
+	}
+	if res.RowsAffected == 0 {
+		ctx.JSON(409, gin.H{"error": "refund request changed meanwhile; reload it"})
+		return
+	}
+
+	database.DB.First(&request, request.ID)
+	ctx.JSON(200, gin.H{"message": "refund rejected", "refund": request})
+}
+
+// pendingPaymentTTL → how long checkout may stay open (PAYMENT_PENDING_TTL, default 24h)
+func pendingPaymentTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("PAYMENT_PENDING_TTL")); err == nil && ttl > 0 {
+		return ttl
+	}
+	return 24 * time.Hour
+}
+
+// expireStalePayments marks pending payments older than the TTL expired, returning their coupon
+// uses. A late capture webhook still settles an expired payment, and opens a refund request if
+// the coupon filled up in the meantime.
+func expireStalePayments() (int64, error) {
+	res := database.DB.Model(&models.Payment{}).
+		Where("status = ? AND created_at < ?", models.PaymentPending, time.Now().Add(-pendingPaymentTTL())).
+		Updates(map[string]interface{}{"status": models.PaymentExpired, "failure_reason": "checkout not completed in time"})
+	return res.RowsAffected, res.Error
+}
+
+// StartPaymentExpiry expires stale pending payments now and every 15 minutes
+func StartPaymentExpiry() {
+	go func() {
+		for {
+			if n, err := expireStalePayments(); err != nil {
+				fmt.Println("ERROR: expiring stale payments:", err)
+			} else if n > 0 {
+				fmt.Println("expired", n, "stale pending payments")
+			}
+			time.Sleep(15 * time.Minute)
+		}
+	}()
+}
@@ -0,0 +1,309 @@
+package controllers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const jobTypeRegrade = "regrade"
+
+// regradeMu serialises regrade jobs so a job always grades against the key
+// as it is after every edit that came before it
+var regradeMu sync.Mutex
+
+// regradeChange is one stored answer whose points would change under the new key
+type regradeChange struct {
+	AnswerID     uint `json:"answer_id"`
+	SubmissionID uint `json:"submission_id"`
+	AssignmentID uint `json:"assignment_id"`
+	UserID       uint `json:"user_id"`
+
+	WasCorrect   bool `json:"was_correct"`
+	IsCorrect    bool `json:"is_correct"`
+	PointsBefore int  `json:"points_before"`
+	PointsAfter  int  `json:"points_after"`
+	ScoreBefore  int  `json:"score_before"`
+	ScoreAfter   int  `json:"score_after"`
+
+	graded models.SubmissionAnswer
+}
+
+// regradeImpact summarises what regrading one question does to existing submissions
+type regradeImpact struct {
+	QuestionID      uint            `json:"question_id"`
+	AnswersChecked  int             `json:"answers_checked"`
+	SkippedOverride int             `json:"skipped_overrides"` // manually graded answers are left alone
+	Changes         []regradeChange `json:"changes"`
+}
+
+// computeRegrade grades every stored answer to q against q's key as given
+// (which may be an unsaved edit when previewing). Nothing is written.
+func computeRegrade(q models.Question) (regradeImpact, error) {
+	impact := regradeImpact{QuestionID: q.ID, Changes: []regradeChange{}}
+
+	var answers []models.SubmissionAnswer
+	if err := database.DB.Where("question_id = ?", q.ID).Order("id").Find(&answers).Error; err != nil {
+		return impact, err
+	}
+	if len(answers) == 0 {
+		return impact, nil
+	}
+
+	submissionIDs := make([]uint, 0, len(answers))
+	for _, a := range answers {
+		submissionIDs = append(submissionIDs, a.SubmissionID)
+	}
+	var submissions []models.Submission
+	if err := database.DB.Where("id IN ?", submissionIDs).Find(&submissions).Error; err != nil {
+		return impact, err
+	}
+	byID := make(map[uint]models.Submission, len(submissions))
+	for _, s := range submissions {
+		byID[s.ID] = s
+	}
+
+	for _, a := range answers {
+		impact.AnswersChecked++
+		if a.GradingMode == models.GradingManual {
+			impact.SkippedOverride++
+			continue
+		}
+
+		g := gradeAnswer(q, a.SelectedOptionIDs, a.TextAnswer)
+		if g.IsCorrect == a.IsCorrect && g.PointsAwarded == a.PointsAwarded &&
+			g.MaxPoints == a.MaxPoints && g.NeedsGrading == a.NeedsGrading {
+			continue
+		}
+
+		sub := byID[a.SubmissionID]
+		impact.Changes = append(impact.Changes, regradeChange{
+			AnswerID:     a.ID,
+			SubmissionID: a.SubmissionID,
+			AssignmentID: sub.AssignmentID,
+			UserID:       sub.UserID,
+			WasCorrect:   a.IsCorrect,
+			IsCorrect:    g.IsCorrect,
+			PointsBefore: a.PointsAwarded,
+			PointsAfter:  g.PointsAwarded,
+			ScoreBefore:  sub.Score,
+			ScoreAfter:   sub.Score - a.PointsAwarded + g.PointsAwarded,
+			graded:       g,
+		})
+	}
+	return impact, nil
+}
+
+// loadQuestionKey loads a question together with its options
+func loadQuestionKey(questionID interface{}) (models.Question, error) {
+	var q models.Question
+	err := database.DB.Preload("Options").First(&q, questionID).Error
+	return q, err
+}
+
+// canManageQuestion → whoever can grade the question's assignment, or manage its bank.
+// Key edits, previews and regrades go through it: they expose and change students' scores.
+func canManageQuestion(ctx *gin.Context, q models.Question) bool {
+	switch {
+	case q.AssignmentID != nil:
+		var assignment models.Assignment
+		if err := database.DB.First(&assignment, *q.AssignmentID).Error; err != nil {
+			return false
+		}
+		return canGradeAssignment(ctx, assignment)
+	case q.BankID != nil:
+		var bank models.QuestionBank
+		if err := database.DB.First(&bank, *q.BankID).Error; err != nil {
+			return false
+		}
+		return canManageBank(ctx, bank)
+	}
+	return getUserRole(ctx) == "admin"
+}
+
+// errQuestionForbidden is the 403 message for canManageQuestion
+const errQuestionForbidden = "you can only change questions of your own assignments or banks"
+
+// syncCorrectOption keeps Question.CorrectOption pointing at the first correct option
+func syncCorrectOption(tx *gorm.DB, questionID uint) error {
+	var first models.Option
+	correctID := uint(0)
+	if err := tx.Where("question_id = ? AND is_correct = ?", questionID, true).Order("id").First(&first).Error; err == nil {
+		correctID = first.ID
+	} else if err != gorm.ErrRecordNotFound {
+		return err
+	}
+	return tx.Model(&models.Question{}).Where("id = ?", questionID).Update("correct_option", correctID).Error
+}
+
+// queueRegrade starts a background regrade of every submission that answered the question
+func queueRegrade(ctx *gin.Context, questionID uint) (models.Job, error) {
+	userID, _ := getContextUserID(ctx)
+	return startJob(jobTypeRegrade, userID, func(job *models.Job) (string, error) {
+		return runRegrade(job, questionID)
+	})
+}
+
+// runRegrade applies the current key of a question to its stored answers,
+// re-sums the affected submissions, logs before/after scores and emails the students
+func runRegrade(job *models.Job, questionID uint) (string, error) {
+	regradeMu.Lock()
+	defer regradeMu.Unlock()
+
+	q, err := loadQuestionKey(questionID)
+	if err != nil {
+		return "", fmt.Errorf("load question %d: %w", questionID, err)
+	}
+	impact, err := computeRegrade(q)
+	if err != nil {
+		return "", err
+	}
+	job.Total = len(impact.Changes)
+
+	logs := make([]models.RegradeLog, 0, len(impact.Changes))
+	for i, c := range impact.Changes {
+		var entry models.RegradeLog
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			// Only touch the answer if nobody overrode it since the impact was computed
+			res := tx.Model(&models.SubmissionAnswer{}).
+				Where("id = ? AND grading_mode = ?", c.AnswerID, models.GradingAuto).
+				Updates(map[string]interface{}{
+					"is_correct":     c.graded.IsCorrect,
+					"auto_points":    c.graded.AutoPoints,
+					"points_awarded": c.graded.PointsAwarded,
+					"max_points":     c.graded.MaxPoints,
+					"needs_grading":  c.graded.NeedsGrading,
+				})
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+
+			submission, err := recalculateSubmission(tx, c.SubmissionID)
+			if err != nil {
+				return err
+			}
+			entry = models.RegradeLog{
+				JobID:        job.ID,
+				AssignmentID: c.AssignmentID,
+				QuestionID:   q.ID,
+				SubmissionID: c.SubmissionID,
+				UserID:       c.UserID,
+				PointsBefore: c.PointsBefore,
+				PointsAfter:  c.PointsAfter,
+				ScoreBefore:  c.ScoreBefore,
+				ScoreAfter:   submission.Score,
+			}
+			return tx.Create(&entry).Error
+		})
+		if err != nil {
+			return fmt.Sprintf("%d of %d submissions regraded", len(logs), len(impact.Changes)), err
+		}
+		if entry.ID != 0 {
+			logs = append(logs, entry)
+		}
+		jobProgress(job, i+1)
+	}
+
+	notifyRegraded(logs)
+	return fmt.Sprintf("%d submissions regraded, %d overridden answers kept", len(logs), impact.SkippedOverride), nil
+}
+
+// notifyRegraded emails each student whose score changed. Failures are logged, not fatal.
+func notifyRegraded(logs []models.RegradeLog) {
+	for _, entry := range logs {
+		if entry.ScoreBefore == entry.ScoreAfter {
+			continue
+		}
+
+		var user models.User
+		if err := database.DB.First(&user, entry.UserID).Error; err != nil {
+			continue
+		}
+		var submission models.Submission
+		if err := database.DB.Preload("Assignment").First(&submission, entry.SubmissionID).Error; err != nil {
+			continue
+		}
+
+		subject := "Your score for " + submission.Assignment.Title + " was updated"
+		body := fmt.Sprintf("Hello %s,\n\nA question in \"%s\" had its answer key corrected and your submission was regraded.\n"+
+			"Your score changed from %d to %d (out of %d).\n",
+			user.Name, submission.Assignment.Title, entry.ScoreBefore, entry.ScoreAfter, submission.MaxScore)
+		if err := utils.SendEmail(user.Email, subject, body); err != nil {
+			fmt.Println("ERROR: failed to send regrade email to", user.Email, ":", err)
+			continue
+		}
+		database.DB.Model(&entry).Update("notified", true)
+	}
+}
+
+// isPreview → ?preview=true asks a key-changing edit to report its impact without saving anything
+func isPreview(ctx *gin.Context) bool {
+	return ctx.Query("preview") == "true"
+}
+
+// PreviewQuestionRegrade → GET /questions/:question_id/regrade/preview
+// Shows which stored answers disagree with the question's current key.
+func PreviewQuestionRegrade(ctx *gin.Context) {
+	q, err := loadQuestionKey(ctx.Param("question_id"))
+	if err != nil {
+		ctx.JSON(404, gin.H{"error": "question not found"})
+		return
+	}
+	if !canManageQuestion(ctx, q) {
+		ctx.JSON(403, gin.H{"error": errQuestionForbidden})
+		return
+	}
+
+	impact, err := computeRegrade(q)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to compute regrade", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"preview": true, "impact": impact})
+}
+
+// RegradeQuestion → POST /questions/:question_id/regrade
+// Starts a regrade job against the current key; poll it via GET /jobs/:id.
+func RegradeQuestion(ctx *gin.Context) {
+	var q models.Question
+	if err := database.DB.First(&q, ctx.Param("question_id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "question not found"})
+		return
+	}
+	if !canManageQuestion(ctx, q) {
+		ctx.JSON(403, gin.H{"error": errQuestionForbidden})
+		return
+	}
+
+	job, err := queueRegrade(ctx, q.ID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to start regrade", "details": err.Error()})
+		return
+	}
+	ctx.JSON(202, gin.H{"message": "regrade started", "job": job})
+}
+
+// GetRegradeLog → GET /assignments/:id/regrade-log
+func GetRegradeLog(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only view regrades of your own assignments"})
+		return
+	}
+
+	var logs []models.RegradeLog
+	if err := database.DB.Where("assignment_id = ?", assignment.ID).Order("id DESC").Find(&logs).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch regrade log", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"regrades": logs})
+}
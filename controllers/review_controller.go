@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/gin-gonic/gin"
+)
+
+var validReviewPolicies = map[string]bool{
+	models.ReviewNever:           true,
+	models.ReviewAfterSubmission: true,
+	models.ReviewAfterDueDate:    true,
+}
+
+// canSeeAnswerKey → only the assignment's course teacher and admins get IsCorrect /
+// CorrectOption / feedback on the regular question routes; everyone else never does.
+func canSeeAnswerKey(ctx *gin.Context, assignment models.Assignment) bool {
+	return canGradeAssignment(ctx, assignment)
+}
+
+// stripAnswerKey removes everything that would give the answers away
+func stripAnswerKey(questions []models.Question) {
+	for i := range questions {
+		questions[i].CorrectOption = 0
+		questions[i].Feedback = ""
+		stripOptionKey(questions[i].Options)
+	}
+}
+
+func stripOptionKey(options []models.Option) {
+	for i := range options {
+		options[i].IsCorrect = false
+	}
+}
+
+// reviewOpen reports whether a student may review an assignment under its review policy.
+// hasSubmitted tells whether the student has at least one submission.
+func reviewOpen(assignment models.Assignment, hasSubmitted bool, now time.Time) bool {
+	switch assignment.ReviewPolicy {
+	case models.ReviewAfterSubmission:
+		return hasSubmitted
+	case models.ReviewAfterDueDate:
+		// Without a due date there is no moment the key can be released
+		return assignment.DueDate != nil && now.After(*assignment.DueDate)
+	default:
+		return false
+	}
+}
+
+// GetAssignmentReview → GET /assignments/:id/review
+// Students get their latest submission (with per-answer results and rubric grades) together with the answer key and feedback,
+// but only once the assignment's review policy allows it. The course teacher and admins always can,
+// and get every question the assignment can use (its own plus its rules' bank questions).
+func GetAssignmentReview(ctx *gin.Context) {
+	userID, ok := getContextUserID(ctx)
+	if !ok {
+		ctx.JSON(401, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+
+	var submission models.Submission
+	hasSubmitted := database.DB.Preload("Answers").Preload("RubricAssessments.Scores.Criterion").
+		Preload("RubricAssessments.Scores.Level").Where("assignment_id = ? AND user_id = ?", assignment.ID, userID).
+		Order("submitted_at DESC").First(&submission).Error == nil
+
+	grader := canSeeAnswerKey(ctx, assignment)
+	if !grader && !reviewOpen(assignment, hasSubmitted, time.Now()) {
+		ctx.JSON(403, gin.H{
+			"error":         "review is not available yet",
+			"review_policy": assignment.ReviewPolicy,
+			"due_date":      assignment.DueDate,
+		})
+		return
+	}
+
+	var questions []models.Question
+	var err error
+	if grader {
+		questions, err = assignmentQuestionPool(assignment.ID)
+	} else {
+		questions, _, err = submissionQuestions(assignment.ID, userID)
+	}
+	if err != nil && err != errAttemptNotStarted {
+		ctx.JSON(500, gin.H{"error": "failed to fetch questions", "details": err.Error()})
+		return
+	}
+
+	response := gin.H{"assignment": assignment, "questions": questions}
+	if hasSubmitted {
+		response["submission"] = submission
+	}
+	ctx.JSON(200, response)
+}
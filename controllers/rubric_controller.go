@@ -0,0 +1,486 @@
+package controllers
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type rubricInput struct {
+	Title       string `json:"title" binding:"required"`
+	Description string `json:"description"`
+	Criteria    []struct {
+		Title       string `json:"title" binding:"required"`
+		Description string `json:"description"`
+		Levels      []struct {
+			Title      string `json:"title" binding:"required"`
+			Descriptor string `json:"descriptor"`
+			Points     int    `json:"points" binding:"min=0"`
+		} `json:"levels" binding:"required,min=1,dive"`
+	} `json:"criteria" binding:"required,min=1,dive"`
+}
+
+func (input rubricInput) criteria() []models.RubricCriterion {
+	var criteria []models.RubricCriterion
+	for i, c := range input.Criteria {
+		criterion := models.RubricCriterion{Title: c.Title, Description: c.Description, OrderIndex: i}
+		for j, l := range c.Levels {
+			criterion.Levels = append(criterion.Levels, models.RubricLevel{Title: l.Title, Descriptor: l.Descriptor, Points: l.Points, OrderIndex: j})
+		}
+		criteria = append(criteria, criterion)
+	}
+	return criteria
+}
+
+// rubricPick is the level a grader chose for one criterion
+type rubricPick struct {
+	CriterionID uint   `json:"criterion_id" binding:"required"`
+	LevelID     uint   `json:"level_id" binding:"required"`
+	Comment     string `json:"comment"`
+}
+
+// loadRubric loads a rubric with its criteria and levels in display order
+func loadRubric(id interface{}) (models.Rubric, error) {
+	var rubric models.Rubric
+	err := database.DB.Preload("Criteria", func(db *gorm.DB) *gorm.DB {
+		return db.Order("order_index, id")
+	}).Preload("Criteria.Levels", func(db *gorm.DB) *gorm.DB {
+		return db.Order("order_index, id")
+	}).First(&rubric, id).Error
+	return rubric, err
+}
+
+// rubricMaxPoints → sum of the best level of every criterion
+func rubricMaxPoints(rubric models.Rubric) int {
+	total := 0
+	for _, c := range rubric.Criteria {
+		best := 0
+		for _, l := range c.Levels {
+			if l.Points > best {
+				best = l.Points
+			}
+		}
+		total += best
+	}
+	return total
+}
+
+// scoreRubric checks that every criterion got exactly one of its own levels
+// and returns the scores and their total
+func scoreRubric(rubric models.Rubric, picks []rubricPick) ([]models.RubricScore, int, error) {
+	byCriterion := map[uint]rubricPick{}
+	for _, p := range picks {
+		if _, dup := byCriterion[p.CriterionID]; dup {
+			return nil, 0, fmt.Errorf("criterion %d is scored twice", p.CriterionID)
+		}
+		byCriterion[p.CriterionID] = p
+	}
+
+	var scores []models.RubricScore
+	total := 0
+	for _, c := range rubric.Criteria {
+		p, ok := byCriterion[c.ID]
+		if !ok {
+			return nil, 0, fmt.Errorf("criterion %q has no level selected", c.Title)
+		}
+		delete(byCriterion, c.ID)
+
+		found := false
+		for _, l := range c.Levels {
+			if l.ID == p.LevelID {
+				scores = append(scores, models.RubricScore{CriterionID: c.ID, LevelID: l.ID, Points: l.Points, Comment: p.Comment})
+				total += l.Points
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, 0, fmt.Errorf("level %d does not belong to criterion %q", p.LevelID, c.Title)
+		}
+	}
+	for id := range byCriterion {
+		return nil, 0, fmt.Errorf("criterion %d is not part of this rubric", id)
+	}
+	return scores, total, nil
+}
+
+// rubricAttached → the rubric is used by an assignment, a question or a peer review
+func rubricAttached(rubricID uint) bool {
+	var n int64
+	database.DB.Model(&models.Assignment{}).Where("rubric_id = ?", rubricID).Count(&n)
+	if n > 0 {
+		return true
+	}
+	database.DB.Model(&models.Question{}).Where("rubric_id = ?", rubricID).Count(&n)
+	if n > 0 {
+		return true
+	}
+	database.DB.Model(&models.PeerReviewSettings{}).Where("rubric_id = ?", rubricID).Count(&n)
+	return n > 0
+}
+
+// rubricGraded → some assessment or peer review already picked levels of this rubric
+func rubricGraded(rubricID uint) bool {
+	var n int64
+	database.DB.Model(&models.RubricScore{}).
+		Joins("JOIN rubric_criteria ON rubric_criteria.id = rubric_scores.criterion_id").
+		Where("rubric_criteria.rubric_id = ?", rubricID).Count(&n)
+	return n > 0
+}
+
+// canManageRubric → admin, or the teacher who created the rubric
+func canManageRubric(ctx *gin.Context, rubric models.Rubric) bool {
+	if getUserRole(ctx) == "admin" {
+		return true
+	}
+	userID, _ := getContextUserID(ctx)
+	return rubric.OwnerID == userID
+}
+
+// CreateRubric → POST /rubrics
+func CreateRubric(ctx *gin.Context) {
+	userID, ok := getContextUserID(ctx)
+	if !ok {
+		ctx.JSON(401, gin.H{"error": "user not authenticated"})
+		return
+	}
+
+	var input rubricInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	rubric := models.Rubric{Title: input.Title, Description: input.Description, OwnerID: userID, Criteria: input.criteria()}
+
+	if err := database.DB.Create(&rubric).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to create rubric", "details": err.Error()})
+		return
+	}
+	ctx.JSON(201, gin.H{"message": "rubric created successfully", "rubric": rubric, "max_points": rubricMaxPoints(rubric)})
+}
+
+// GetRubrics → GET /rubrics
+// Teachers see their own rubrics, admins see all.
+func GetRubrics(ctx *gin.Context) {
+	userID, _ := getContextUserID(ctx)
+
+	query := database.DB.Preload("Criteria.Levels")
+	if getUserRole(ctx) != "admin" {
+		query = query.Where("owner_id = ?", userID)
+	}
+
+	var rubrics []models.Rubric
+	if err := query.Order("id DESC").Find(&rubrics).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch rubrics", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"rubrics": rubrics})
+}
+
+// GetRubricByID → GET /rubrics/:id
+func GetRubricByID(ctx *gin.Context) {
+	rubric, err := loadRubric(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(404, gin.H{"error": "rubric not found"})
+		return
+	}
+	ctx.JSON(200, gin.H{"rubric": rubric, "max_points": rubricMaxPoints(rubric)})
+}
+
+// UpdateRubric → PUT /rubrics/:id
+// Replaces title, description and the whole criteria grid. Rubrics that were already used
+// for grading are frozen so existing scores keep pointing at the levels they picked.
+func UpdateRubric(ctx *gin.Context) {
+	var rubric models.Rubric
+	if err := database.DB.First(&rubric, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "rubric not found"})
+		return
+	}
+	if !canManageRubric(ctx, rubric) {
+		ctx.JSON(403, gin.H{"error": "you can only edit your own rubrics"})
+		return
+	}
+	if rubricGraded(rubric.ID) {
+		ctx.JSON(409, gin.H{"error": "rubric has been used for grading, create a new one instead"})
+		return
+	}
+
+	var input rubricInput
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	rubric.Title, rubric.Description = input.Title, input.Description
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("rubric_id = ?", rubric.ID).Delete(&models.RubricCriterion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Save(&rubric).Error; err != nil {
+			return err
+		}
+		rubric.Criteria = input.criteria()
+		for i := range rubric.Criteria {
+			rubric.Criteria[i].RubricID = rubric.ID
+		}
+		return tx.Create(&rubric.Criteria).Error
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to update rubric", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "rubric updated successfully", "rubric": rubric, "max_points": rubricMaxPoints(rubric)})
+}
+
+// DeleteRubric → DELETE /rubrics/:id
+func DeleteRubric(ctx *gin.Context) {
+	var rubric models.Rubric
+	if err := database.DB.First(&rubric, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "rubric not found"})
+		return
+	}
+	if !canManageRubric(ctx, rubric) {
+		ctx.JSON(403, gin.H{"error": "you can only delete your own rubrics"})
+		return
+	}
+
+	if rubricAttached(rubric.ID) || rubricGraded(rubric.ID) {
+		ctx.JSON(409, gin.H{"error": "rubric is attached to an assignment or question, or has been used for grading"})
+		return
+	}
+
+	// Criteria and levels go with it through the cascading foreign keys
+	if err := database.DB.Delete(&rubric).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to delete rubric", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "rubric deleted successfully"})
+}
+
+// ------------------------------------------------------------------
+//						attaching and grading
+// ------------------------------------------------------------------
+
+// rubricForAttach loads the rubric in the body (null detaches) and checks the caller may use it
+func rubricForAttach(ctx *gin.Context) (*uint, bool) {
+	var input struct {
+		RubricID *uint `json:"rubric_id"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return nil, false
+	}
+	if input.RubricID == nil {
+		return nil, true
+	}
+
+	var rubric models.Rubric
+	if err := database.DB.First(&rubric, *input.RubricID).Error; err != nil {
+		ctx.JSON(400, gin.H{"error": "rubric not found"})
+		return nil, false
+	}
+	if !canManageRubric(ctx, rubric) {
+		ctx.JSON(403, gin.H{"error": "you can only use your own rubrics"})
+		return nil, false
+	}
+	return input.RubricID, true
+}
+
+// SetAssignmentRubric → PUT /assignments/:id/rubric  {"rubric_id": 3 | null}
+func SetAssignmentRubric(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only change your own assignments"})
+		return
+	}
+
+	rubricID, ok := rubricForAttach(ctx)
+	if !ok {
+		return
+	}
+	// Existing submissions now wait for (or no longer wait for) a rubric grade
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&assignment).Update("rubric_id", rubricID).Error; err != nil {
+			return err
+		}
+		var submissionIDs []uint
+		if err := tx.Model(&models.Submission{}).Where("assignment_id = ?", assignment.ID).Pluck("id", &submissionIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range submissionIDs {
+			if _, err := recalculateSubmission(tx, id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to attach rubric", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "assignment rubric updated", "assignment_id": assignment.ID, "rubric_id": rubricID})
+}
+
+// SetQuestionRubric → PUT /questions/:question_id/rubric  {"rubric_id": 3 | null}
+func SetQuestionRubric(ctx *gin.Context) {
+	var question models.Question
+	if err := database.DB.First(&question, ctx.Param("question_id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "question not found"})
+		return
+	}
+	if !canManageQuestion(ctx, question) {
+		ctx.JSON(403, gin.H{"error": errQuestionForbidden})
+		return
+	}
+
+	rubricID, ok := rubricForAttach(ctx)
+	if !ok {
+		return
+	}
+	if err := database.DB.Model(&question).Update("rubric_id", rubricID).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to attach rubric", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "question rubric updated", "question_id": question.ID, "rubric_id": rubricID})
+}
+
+// GradeWithRubric → POST /submissions/:id/rubric-grade
+// With answer_id the answer's question rubric is used and the answer's points become the
+// rubric score scaled to the question's points; without it the assignment rubric grades
+// the whole submission and its points are added to the score. Grading again replaces
+// the previous assessment.
+func GradeWithRubric(ctx *gin.Context) {
+	teacherID, _ := getContextUserID(ctx)
+
+	var input struct {
+		AnswerID *uint        `json:"answer_id"`
+		Scores   []rubricPick `json:"scores" binding:"required,min=1,dive"`
+		Comment  string       `json:"comment"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+
+	var submission models.Submission
+	if err := database.DB.First(&submission, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "submission not found"})
+		return
+	}
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, submission.AssignmentID).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only grade submissions of your own assignments"})
+		return
+	}
+
+	var answer models.SubmissionAnswer
+	rubricID := assignment.RubricID
+	if input.AnswerID != nil {
+		if err := database.DB.Preload("Question").Where("submission_id = ?", submission.ID).
+			First(&answer, *input.AnswerID).Error; err != nil {
+			ctx.JSON(404, gin.H{"error": "answer not found in this submission"})
+			return
+		}
+		rubricID = nil
+		if answer.Question != nil {
+			rubricID = answer.Question.RubricID
+		}
+	}
+	if rubricID == nil {
+		ctx.JSON(400, gin.H{"error": "no rubric is attached to this question or assignment"})
+		return
+	}
+
+	rubric, err := loadRubric(*rubricID)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to load rubric", "details": err.Error()})
+		return
+	}
+	scores, total, err := scoreRubric(rubric, input.Scores)
+	if err != nil {
+		ctx.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	maxPoints := rubricMaxPoints(rubric)
+
+	var assessment models.RubricAssessment
+	var updated models.Submission
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		existing := tx.Where("submission_id = ?", submission.ID)
+		if input.AnswerID != nil {
+			existing = existing.Where("answer_id = ?", *input.AnswerID)
+		} else {
+			existing = existing.Where("answer_id IS NULL")
+		}
+		if err := existing.First(&assessment).Error; err != nil && err != gorm.ErrRecordNotFound {
+			return err
+		}
+		if assessment.ID != 0 {
+			if err := tx.Where("assessment_id = ?", assessment.ID).Delete(&models.RubricScore{}).Error; err != nil {
+				return err
+			}
+		}
+
+		assessment.SubmissionID, assessment.AnswerID, assessment.RubricID = submission.ID, input.AnswerID, rubric.ID
+		assessment.Score, assessment.MaxScore, assessment.Comment, assessment.GradedBy = total, maxPoints, input.Comment, teacherID
+		assessment.Scores = nil
+		if err := tx.Save(&assessment).Error; err != nil {
+			return err
+		}
+		for i := range scores {
+			scores[i].AssessmentID = &assessment.ID
+		}
+		if err := tx.Create(&scores).Error; err != nil {
+			return err
+		}
+
+		if input.AnswerID != nil {
+			points := 0
+			if maxPoints > 0 {
+				points = int(math.Round(float64(total) * float64(answer.MaxPoints) / float64(maxPoints)))
+			}
+			now := time.Now()
+			if err := tx.Model(&answer).Updates(map[string]interface{}{
+				"points_awarded":  points,
+				"is_correct":      points == answer.MaxPoints,
+				"grading_mode":    models.GradingManual,
+				"needs_grading":   false,
+				"override_reason": "rubric",
+				"overridden_by":   teacherID,
+				"overridden_at":   now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		updated, err = recalculateSubmission(tx, submission.ID)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to save rubric grade", "details": err.Error()})
+		return
+	}
+
+	assessment.Scores = scores
+	ctx.JSON(200, gin.H{
+		"message":       "graded with rubric",
+		"assessment":    assessment,
+		"score":         updated.Score,
+		"max_score":     updated.MaxScore,
+		"needs_grading": updated.NeedsGrading,
+	})
+}
@@ -0,0 +1,420 @@
+package controllers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	jobTypeSimilarity = "similarity"
+
+	// Pairs overlapping less than this (either direction) are not recorded at all
+	similarityReportMin = 20.0
+	// Pairs at or above this are flagged in the grading queue
+	similarityFlagMin = 50.0
+)
+
+// similarityMu serialises checks so two answers submitted at once still see each other
+var similarityMu sync.Mutex
+
+var validSimilarityStatuses = map[string]bool{
+	models.SimilarityOpen:      true,
+	models.SimilarityDismissed: true,
+	models.SimilarityConfirmed: true,
+}
+
+// fingerprintAnswer stores the fingerprints of one text answer and records its overlap with
+// every earlier fingerprinted answer by another student in the same assignment, or to the
+// same question in other assignments (questions reused from previous terms).
+// Returns how many matches were recorded.
+func fingerprintAnswer(submission models.Submission, answer models.SubmissionAnswer) (int, error) {
+	var prints []models.Fingerprint
+	for _, f := range utils.Winnow(answer.TextAnswer) {
+		prints = append(prints, models.Fingerprint(f))
+	}
+	if len(prints) == 0 {
+		return 0, nil // too short to compare
+	}
+
+	var existing int64
+	database.DB.Model(&models.AnswerFingerprint{}).Where("answer_id = ?", answer.ID).Count(&existing)
+	if existing > 0 {
+		return 0, nil // already compared when it was fingerprinted
+	}
+
+	var candidates []models.AnswerFingerprint
+	if err := database.DB.Where("user_id <> ? AND (assignment_id = ? OR question_id = ?)",
+		submission.UserID, submission.AssignmentID, answer.QuestionID).Find(&candidates).Error; err != nil {
+		return 0, err
+	}
+
+	mine := toUtilPrints(prints)
+	var matches []models.SimilarityMatch
+	for _, c := range candidates {
+		share, otherShare, spans := utils.CompareFingerprints(mine, toUtilPrints(c.Prints))
+		share, otherShare = share*100, otherShare*100
+		if share < similarityReportMin && otherShare < similarityReportMin {
+			continue
+		}
+
+		match := models.SimilarityMatch{
+			AssignmentID:      submission.AssignmentID,
+			QuestionID:        answer.QuestionID,
+			SubmissionID:      submission.ID,
+			AnswerID:          answer.ID,
+			UserID:            submission.UserID,
+			OtherAssignmentID: c.AssignmentID,
+			OtherSubmissionID: c.SubmissionID,
+			OtherAnswerID:     c.AnswerID,
+			OtherUserID:       c.UserID,
+			Similarity:        round2(share),
+			OtherSimilarity:   round2(otherShare),
+			Flagged:           share >= similarityFlagMin || otherShare >= similarityFlagMin,
+			Status:            models.SimilarityOpen,
+		}
+		for _, s := range spans {
+			match.Spans = append(match.Spans, models.MatchSpan(s))
+		}
+		matches = append(matches, match)
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		fingerprint := models.AnswerFingerprint{
+			AnswerID:     answer.ID,
+			SubmissionID: submission.ID,
+			AssignmentID: submission.AssignmentID,
+			QuestionID:   answer.QuestionID,
+			UserID:       submission.UserID,
+			Prints:       prints,
+		}
+		if err := tx.Create(&fingerprint).Error; err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return nil
+		}
+		return tx.Create(&matches).Error
+	})
+	return len(matches), err
+}
+
+func toUtilPrints(prints []models.Fingerprint) []utils.Fingerprint {
+	out := make([]utils.Fingerprint, len(prints))
+	for i, f := range prints {
+		out[i] = utils.Fingerprint(f)
+	}
+	return out
+}
+
+// checkSubmissionSimilarity fingerprints the text answers of the given submissions in order
+func checkSubmissionSimilarity(job *models.Job, submissionIDs []uint) (string, error) {
+	similarityMu.Lock()
+	defer similarityMu.Unlock()
+
+	job.Total = len(submissionIDs)
+	found := 0
+	for i, id := range submissionIDs {
+		var submission models.Submission
+		if err := database.DB.Preload("Answers", "text_answer <> ''").First(&submission, id).Error; err != nil {
+			return "", fmt.Errorf("load submission %d: %w", id, err)
+		}
+		for _, a := range submission.Answers {
+			n, err := fingerprintAnswer(submission, a)
+			if err != nil {
+				return fmt.Sprintf("%d matches found", found), err
+			}
+			found += n
+		}
+		jobProgress(job, i+1)
+	}
+	return fmt.Sprintf("%d matches found", found), nil
+}
+
+// queueSimilarityCheck compares a new submission's text answers in the background.
+// It runs as a system job (created by 0), so the student cannot poll its match count.
+func queueSimilarityCheck(submission models.Submission) {
+	hasText := false
+	for _, a := range submission.Answers {
+		hasText = hasText || a.TextAnswer != ""
+	}
+	if !hasText {
+		return
+	}
+
+	if _, err := startJob(jobTypeSimilarity, 0, func(job *models.Job) (string, error) {
+		return checkSubmissionSimilarity(job, []uint{submission.ID})
+	}); err != nil {
+		fmt.Println("ERROR: failed to queue similarity check for submission", submission.ID, ":", err)
+	}
+}
+
+// ScanAssignmentSimilarity → POST /assignments/:id/similarity/scan
+// Fingerprints every text answer of the assignment that has not been checked yet
+// (e.g. submissions made before similarity checking existed).
+func ScanAssignmentSimilarity(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only scan your own assignments"})
+		return
+	}
+
+	var submissionIDs []uint
+	if err := database.DB.Model(&models.Submission{}).Where("assignment_id = ?", assignment.ID).
+		Order("id").Pluck("id", &submissionIDs).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch submissions", "details": err.Error()})
+		return
+	}
+
+	userID, _ := getContextUserID(ctx)
+	job, err := startJob(jobTypeSimilarity, userID, func(job *models.Job) (string, error) {
+		return checkSubmissionSimilarity(job, submissionIDs)
+	})
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to start scan", "details": err.Error()})
+		return
+	}
+	ctx.JSON(202, gin.H{"message": "similarity scan started", "job": job})
+}
+
+// similarityView is a match seen from one submission's side, with the matched text
+type similarityView struct {
+	MatchID           uint    `json:"match_id"`
+	QuestionID        uint    `json:"question_id"`
+	AnswerID          uint    `json:"answer_id"`
+	OtherSubmissionID uint    `json:"other_submission_id"`
+	OtherAssignmentID uint    `json:"other_assignment_id"`
+	OtherUserID       uint    `json:"other_user_id"`
+	Similarity        float64 `json:"similarity"`       // percent of this answer found in the other
+	OtherSimilarity   float64 `json:"other_similarity"` // percent of the other answer found in this one
+	Flagged           bool    `json:"flagged"`
+	Status            string  `json:"status"`
+	ReviewNote        string  `json:"review_note,omitempty"`
+
+	Spans []similaritySpan `json:"spans"`
+}
+
+type similaritySpan struct {
+	models.MatchSpan
+	Text      string `json:"text"`
+	OtherText string `json:"other_text"`
+}
+
+// viewFrom turns a stored match around when the submission is on its "other" side
+func viewFrom(m models.SimilarityMatch, submissionID uint, texts map[uint]string) similarityView {
+	v := similarityView{
+		MatchID: m.ID, QuestionID: m.QuestionID, Flagged: m.Flagged, Status: m.Status, ReviewNote: m.ReviewNote,
+		AnswerID: m.AnswerID, OtherSubmissionID: m.OtherSubmissionID, OtherAssignmentID: m.OtherAssignmentID,
+		OtherUserID: m.OtherUserID, Similarity: m.Similarity, OtherSimilarity: m.OtherSimilarity,
+	}
+	mine, other := texts[m.AnswerID], texts[m.OtherAnswerID]
+	spans := m.Spans
+
+	if m.SubmissionID != submissionID {
+		v.AnswerID, v.OtherSubmissionID, v.OtherAssignmentID, v.OtherUserID = m.OtherAnswerID, m.SubmissionID, m.AssignmentID, m.UserID
+		v.Similarity, v.OtherSimilarity = m.OtherSimilarity, m.Similarity
+		mine, other = other, mine
+		swapped := make([]models.MatchSpan, len(spans))
+		for i, s := range spans {
+			swapped[i] = models.MatchSpan{Start: s.OtherStart, End: s.OtherEnd, OtherStart: s.Start, OtherEnd: s.End}
+		}
+		sort.Slice(swapped, func(i, j int) bool { return swapped[i].Start < swapped[j].Start })
+		spans = swapped
+	}
+
+	v.Spans = []similaritySpan{}
+	for _, s := range spans {
+		v.Spans = append(v.Spans, similaritySpan{MatchSpan: s, Text: excerpt(mine, s.Start, s.End), OtherText: excerpt(other, s.OtherStart, s.OtherEnd)})
+	}
+	return v
+}
+
+func excerpt(text string, start, end int) string {
+	if start < 0 || end > len(text) || start >= end {
+		return ""
+	}
+	return text[start:end]
+}
+
+// GetSubmissionSimilarity → GET /submissions/:id/similarity
+// Similarity report: every recorded overlap of this submission's text answers, both ways.
+func GetSubmissionSimilarity(ctx *gin.Context) {
+	var submission models.Submission
+	if err := database.DB.First(&submission, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "submission not found"})
+		return
+	}
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, submission.AssignmentID).Error; err != nil || !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only view similarity reports of your own assignments"})
+		return
+	}
+
+	var matches []models.SimilarityMatch
+	if err := database.DB.Where("submission_id = ? OR other_submission_id = ?", submission.ID, submission.ID).
+		Order("similarity DESC").Find(&matches).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch similarity report", "details": err.Error()})
+		return
+	}
+
+	answerIDs := []uint{}
+	for _, m := range matches {
+		answerIDs = append(answerIDs, m.AnswerID, m.OtherAnswerID)
+	}
+	texts := map[uint]string{}
+	if len(answerIDs) > 0 {
+		var answers []models.SubmissionAnswer
+		if err := database.DB.Select("id", "text_answer").Where("id IN ?", answerIDs).Find(&answers).Error; err != nil {
+			ctx.JSON(500, gin.H{"error": "failed to fetch answers", "details": err.Error()})
+			return
+		}
+		for _, a := range answers {
+			texts[a.ID] = a.TextAnswer
+		}
+	}
+
+	report := make([]similarityView, 0, len(matches))
+	highest := 0.0
+	for _, m := range matches {
+		v := viewFrom(m, submission.ID, texts)
+		if v.Similarity > highest {
+			highest = v.Similarity
+		}
+		report = append(report, v)
+	}
+
+	ctx.JSON(200, gin.H{"submission_id": submission.ID, "highest_similarity": highest, "matches": report})
+}
+
+// ReviewSimilarityMatch → PUT /similarity-matches/:id  {"status": "dismissed|confirmed|open", "note": "..."}
+func ReviewSimilarityMatch(ctx *gin.Context) {
+	var match models.SimilarityMatch
+	if err := database.DB.First(&match, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "match not found"})
+		return
+	}
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, match.AssignmentID).Error; err != nil || !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only review matches in your own assignments"})
+		return
+	}
+
+	var input struct {
+		Status string `json:"status" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := ctx.ShouldBindJSON(&input); err != nil {
+		ctx.JSON(400, gin.H{"error": "invalid request", "details": err.Error()})
+		return
+	}
+	if !validSimilarityStatuses[input.Status] {
+		ctx.JSON(400, gin.H{"error": "status must be one of open, dismissed, confirmed"})
+		return
+	}
+
+	userID, _ := getContextUserID(ctx)
+	match.Status, match.ReviewNote, match.ReviewedBy = input.Status, input.Note, &userID
+	if err := database.DB.Model(&match).Updates(map[string]interface{}{
+		"status":      match.Status,
+		"review_note": match.ReviewNote,
+		"reviewed_by": userID,
+	}).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to update match", "details": err.Error()})
+		return
+	}
+	ctx.JSON(200, gin.H{"message": "match updated", "match": match})
+}
+
+// gradingQueueItem is a submission waiting for the teacher
+type gradingQueueItem struct {
+	SubmissionID uint             `json:"submission_id"`
+	UserID       uint             `json:"user_id"`
+	Name         string           `json:"name"`
+	SubmittedAt  time.Time        `json:"submitted_at"`
+	NeedsGrading bool             `json:"needs_grading"`
+	Score        int              `json:"score"`
+	MaxScore     int              `json:"max_score"`
+	Similarity   []similarityView `json:"similarity_flags,omitempty"`
+}
+
+// GetGradingQueue → GET /assignments/:id/grading-queue
+// Latest submissions that still need manual grading or have open similarity flags,
+// flagged ones first.
+func GetGradingQueue(ctx *gin.Context) {
+	var assignment models.Assignment
+	if err := database.DB.First(&assignment, ctx.Param("id")).Error; err != nil {
+		ctx.JSON(404, gin.H{"error": "assignment not found"})
+		return
+	}
+	if !canGradeAssignment(ctx, assignment) {
+		ctx.JSON(403, gin.H{"error": "you can only view the grading queue of your own assignments"})
+		return
+	}
+
+	latest, _, err := latestSubmissions(assignment.ID, false)
+	if err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch submissions", "details": err.Error()})
+		return
+	}
+
+	var flags []models.SimilarityMatch
+	if err := database.DB.Where("flagged = ? AND status = ? AND (assignment_id = ? OR other_assignment_id = ?)",
+		true, models.SimilarityOpen, assignment.ID, assignment.ID).Find(&flags).Error; err != nil {
+		ctx.JSON(500, gin.H{"error": "failed to fetch similarity flags", "details": err.Error()})
+		return
+	}
+	bySubmission := map[uint][]similarityView{}
+	for _, m := range flags {
+		// Spans are left out here; the full report has them
+		m.Spans = nil
+		bySubmission[m.SubmissionID] = append(bySubmission[m.SubmissionID], viewFrom(m, m.SubmissionID, nil))
+		bySubmission[m.OtherSubmissionID] = append(bySubmission[m.OtherSubmissionID], viewFrom(m, m.OtherSubmissionID, nil))
+	}
+
+	userIDs := make([]uint, 0, len(latest))
+	for _, s := range latest {
+		userIDs = append(userIDs, s.UserID)
+	}
+	names := map[uint]string{}
+	if len(userIDs) > 0 {
+		var users []models.User
+		database.DB.Select("id", "name").Where("id IN ?", userIDs).Find(&users)
+		for _, u := range users {
+			names[u.ID] = u.Name
+		}
+	}
+
+	queue := []gradingQueueItem{}
+	for _, s := range latest {
+		flagged := bySubmission[s.ID]
+		if !s.NeedsGrading && len(flagged) == 0 {
+			continue
+		}
+		queue = append(queue, gradingQueueItem{
+			SubmissionID: s.ID,
+			UserID:       s.UserID,
+			Name:         names[s.UserID],
+			SubmittedAt:  s.SubmittedAt,
+			NeedsGrading: s.NeedsGrading,
+			Score:        s.Score,
+			MaxScore:     s.MaxScore,
+			Similarity:   flagged,
+		})
+	}
+	sort.SliceStable(queue, func(i, j int) bool {
+		return len(queue[i].Similarity) > 0 && len(queue[j].Similarity) == 0
+	})
+
+	ctx.JSON(200, gin.H{"queue": queue})
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"bytes"
+	"html/template"
+	"strings"
+	"time"
+
+	"github.com/ayushwar/major/database"
+	"github.com/ayushwar/major/models"
+	"github.com/ayushwar/major/utils"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Public certificate statuses
+const (
+	certificateValid      = "valid"
+	certificateRevoked    = "revoked"
+	certificateSuperseded = "superseded"
+)
+
+// certificateStatus → revoked wins over superseded; a reissue of a revoked certificate still reads revoked
+func certificateStatus(cert models.Certificate) string {
+	switch {
+	case cert.RevokedAt != nil:
+		return certificateRevoked
+	case cert.SupersededAt != nil:
+		return certificateSuperseded
+	}
+	return certificateValid
+}
+
+// certificateHistoryEntry is the public form of a CertificateEvent (no actor)
+type certificateHistoryEntry struct {
+	Type        string    `json:"type"`
+	Reason      string    `json:"reason,omitempty"`
+	RelatedCode string    `json:"related_code,omitempty"`
+	At          time.Time `json:"at"`
+}
+
+// certificateVerification is the public view of a certificate: enough to confirm it, nothing private
+type certificateVerification struct {
+	Code          string     `json:"code"`
+	Status        string     `json:"status"`
+	HolderName    string     `json:"holder_name"`
+	CourseTitle   string     `json:"course_title"`
+	CourseCode    string     `json:"course_code"`
+	IssuedAt      time.Time  `json:"issued_at"`
+	SignedAt      string     `json:"signed_issued_at"` // issued_at exactly as it appears in the signed payload
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `json:"revoked_reason,omitempty"`
+	SupersededAt  *time.Time `json:"superseded_at,omitempty"`
+	SupersededBy  string     `json:"superseded_by,omitempty"` // code of the replacement
+	VerifyURL     string     `json:"verify_url"`
+
+	History []certificateHistoryEntry `json:"history"`
+
+	Signature      string `json:"signature,omitempty"`
+	SigningKeyID   string `json:"signing_key_id,omitempty"`
+	SignatureValid bool   `json:"signature_valid"` // stored record still matches its signature
+}
+
+func newCertificateVerification(cert models.Certificate) certificateVerification {
+	v := certificateVerification{
+		Code:          cert.CertCode,
+		Status:        certificateStatus(cert),
+		IssuedAt:      cert.IssuedAt,
+		SignedAt:      certificatePayload(cert).IssuedAt,
+		RevokedAt:     cert.RevokedAt,
+		RevokedReason: cert.RevokedReason,
+		SupersededAt:  cert.SupersededAt,
+		VerifyURL:     utils.CertificateVerifyURL(cert.CertCode),
+		History:       []certificateHistoryEntry{},
+	}
+	if cert.SupersededBy != nil {
+		v.SupersededBy = cert.SupersededBy.CertCode
+	}
+	for _, e := range cert.Events {
+		v.History = append(v.History, certificateHistoryEntry{Type: e.Type, Reason: e.Reason, RelatedCode: e.RelatedCode, At: e.CreatedAt})
+	}
+	// The signed names; certificates from before they were stored fall back to the current ones
+	v.HolderName, v.CourseTitle = cert.HolderName, cert.CourseTitle
+	if v.HolderName == "" && cert.User != nil {
+		v.HolderName = cert.User.Name
+	}
+	if cert.Course != nil {
+		v.CourseCode = cert.Course.Code
+		if v.CourseTitle == "" {
+			v.CourseTitle = cert.Course.Title
+		}
+	}
+	v.Signature, v.SigningKeyID = cert.Signature, cert.SigningKeyID
+	v.SignatureValid = cert.Signature != "" && utils.VerifySignature(certificatePayload(cert).Bytes(), cert.Signature)
+	return v
+}
+
+var verifyPage = template.Must(template.New("verify").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Certificate verification</title>
+<style>
+body { font-family: sans-serif; max-width: 40rem; margin: 3rem auto; padding: 0 1rem; color: #222; }
+.status { display: inline-block; padding: .3rem .8rem; border-radius: 1rem; font-weight: bold; }
+.valid { background: #d7f5dd; color: #14632a; }
+.revoked, .not_found { background: #fbdada; color: #8a1c1c; }
+.superseded { background: #fdf0cf; color: #7a5400; }
+dt { font-weight: bold; margin-top: .8rem; }
+</style>
+</head>
+<body>
+<h1>Certificate verification</h1>
+{{if .Found}}{{with .Certificate}}
+<p><span class="status {{.Status}}">{{if eq .Status "valid"}}Valid certificate{{else if eq .Status "superseded"}}Superseded{{else}}Revoked{{end}}</span></p>
+{{if .SupersededBy}}<p>This certificate was reissued as <a href="{{.SupersededBy}}">{{.SupersededBy}}</a>.</p>{{end}}
+<dl>
+<dt>Awarded to</dt><dd>{{.HolderName}}</dd>
+<dt>Course</dt><dd>{{.CourseTitle}} ({{.CourseCode}})</dd>
+<dt>Issued on</dt><dd>{{.IssuedAt.Format "02 Jan 2006"}}</dd>
+<dt>Certificate code</dt><dd>{{.Code}}</dd>
+<dt>Digital signature</dt><dd>{{if .SignatureValid}}Verified (key {{.SigningKeyID}}), signed issue time {{.SignedAt}}{{else}}Not verified{{end}}</dd>
+{{if .RevokedAt}}<dt>Revoked on</dt><dd>{{.RevokedAt.Format "02 Jan 2006"}}{{if .RevokedReason}}: {{.RevokedReason}}{{end}}</dd>{{end}}
+</dl>
+{{if .History}}<h2>History</h2>
+<ul>{{range .History}}
+<li>{{.At.Format "02 Jan 2006"}}: {{.Type}}{{if .RelatedCode}} ({{.RelatedCode}}){{end}}{{if .Reason}}: {{.Reason}}{{end}}</li>{{end}}
+</ul>{{end}}
+{{end}}{{else}}
+<p><span class="status not_found">Not found</span></p>
+<p>No certificate with code <strong>{{.Code}}</strong> was issued by this platform.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// wantsHTML → ?format=html, or a browser asking for text/html (e.g. after scanning the QR)
+func wantsHTML(ctx *gin.Context) bool {
+	switch ctx.Query("format") {
+	case "html":
+		return true
+	case "json":
+		return false
+	}
+	return strings.Contains(ctx.GetHeader("Accept"), "text/html")
+}
+
+// VerifyCertificate → GET /verify/:code (public, rate limited)
+// Confirms a certificate by its code as JSON, or as an HTML page for browsers.
+func VerifyCertificate(ctx *gin.Context) {
+	code := strings.TrimSpace(ctx.Param("code"))
+
+	var cert models.Certificate
+	found := code != "" && database.DB.Preload("User").Preload("Course").Preload("SupersededBy").
+		Preload("Events", func(db *gorm.DB) *gorm.DB { return db.Order("created_at, id") }).
+		Where("cert_code = ?", code).First(&cert).Error == nil
+
+	if wantsHTML(ctx) {
+		data := gin.H{"Found": found, "Code": code}
+		status := 404
+		if found {
+			data["Certificate"] = newCertificateVerification(cert)
+			status = 200
+		}
+		var buf bytes.Buffer
+		if err := verifyPage.Execute(&buf, data); err != nil {
+			ctx.JSON(500, gin.H{"error": "failed to render page", "details": err.Error()})
+			return
+		}
+		ctx.Data(status, "text/html; charset=utf-8", buf.Bytes())
+		return
+	}
+
+	if !found {
+		ctx.JSON(404, gin.H{"error": "certificate not found", "valid": false})
+		return
+	}
+	v := newCertificateVerification(cert)
+	response := gin.H{"certificate": v, "valid": v.Status == certificateValid && v.SignatureValid}
+	// The QR carries the signature; check the one that was presented too
+	if sig := ctx.Query("sig"); sig != "" {
+		response["presented_signature_valid"] = utils.VerifySignature(certificatePayload(cert).Bytes(), sig)
+	}
+	ctx.JSON(200, response)
+}
@@ -57,14 +57,77 @@ func ConnectDB() {
 		&models.Assignment{},
 		&models.Submission{},
 		&models.Payment{},
+		&models.PaymentEvent{},
+		&models.Coupon{},
+		&models.CouponRedemption{},
+		&models.RefundRequest{},
+		&models.ReconciliationRun{},
+		&models.ReconciliationItem{},
+		&models.InvoiceSequence{},
+		&models.Invoice{},
+		&models.InvoiceLine{},
+		&models.BillingDetails{},
+		&models.LedgerEntry{},
+		&models.LedgerPosting{},
+		&models.RevenueShareRule{},
+		&models.PayoutStatement{},
+		&models.PayoutLine{},
 		&models.CollegeVerification{},
 		&models.Progress{},
 		&models.Certificate{},
+		&models.CertificateEvent{},
+		&models.CompletionRule{},
 		&models.Department{},
+		&models.QuestionBank{},
+		&models.Question{},
+		&models.Option{},
+		&models.AssignmentRule{},
+		&models.AssignmentAttempt{},
+		&models.SubmissionAnswer{},
+		&models.Job{},
+		&models.RegradeLog{},
+		&models.AssignmentStats{},
+		&models.GradeCategory{},
+		&models.GradeOverride{},
+		&models.GradeScaleEntry{},
+		&models.Rubric{},
+		&models.RubricCriterion{},
+		&models.RubricLevel{},
+		&models.RubricScore{},
+		&models.RubricAssessment{},
+		&models.PeerReviewSettings{},
+		&models.PeerReview{},
+		&models.AnswerFingerprint{},
+		&models.SimilarityMatch{},
+		&models.CertificateTemplate{},
+		&models.CertificateTemplateElement{},
+		&models.CertificateTemplateAsset{},
 	)
 	if err != nil {
 		log.Fatal("❌ Migration failed: ", err)
 	}
+	if err := restrictAnswerQuestionDelete(db); err != nil {
+		log.Fatal("❌ Migration failed: ", err)
+	}
 
 	log.Println("✅ All models migrated successfully!")
 }
+
+// restrictAnswerQuestionDelete swaps the old ON DELETE CASCADE from submission answers to their
+// question for RESTRICT; AutoMigrate never changes a constraint that already exists
+func restrictAnswerQuestionDelete(db *gorm.DB) error {
+	var rule string
+	if err := db.Raw(`SELECT DELETE_RULE FROM information_schema.REFERENTIAL_CONSTRAINTS
+		WHERE CONSTRAINT_SCHEMA = DATABASE() AND TABLE_NAME = 'submission_answers' AND REFERENCED_TABLE_NAME = 'questions'`).
+		Scan(&rule).Error; err != nil {
+		return err
+	}
+	if rule != "CASCADE" {
+		return nil
+	}
+	m := db.Migrator()
+	if err := m.DropConstraint(&models.SubmissionAnswer{}, "Question"); err != nil {
+		return err
+	}
+	return m.CreateConstraint(&models.SubmissionAnswer{}, "Question")
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"log"
+	"os"
+	"strings"
 
+	"github.com/ayushwar/major/controllers"
 	"github.com/ayushwar/major/database"
 	"github.com/ayushwar/major/routes"
 	"github.com/gin-gonic/gin"
@@ -25,8 +28,20 @@ func main() {
 	}
 
 	database.ConnectDB()
+	controllers.StartPaymentExpiry()
+	controllers.StartVerificationExpiry()
 
 	server := gin.Default()
+	// Only believe X-Forwarded-For from our own proxies; otherwise ClientIP (rate limits, audit) is spoofable
+	var proxies []string
+	if v := strings.TrimSpace(os.Getenv("TRUSTED_PROXIES")); v != "" {
+		for _, p := range strings.Split(v, ",") {
+			proxies = append(proxies, strings.TrimSpace(p))
+		}
+	}
+	if err := server.SetTrustedProxies(proxies); err != nil {
+		log.Fatal(" Invalid TRUSTED_PROXIES: ", err)
+	}
 
 	routes.RegisterRoutes(server)
 
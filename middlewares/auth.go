@@ -105,6 +105,33 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// OptionalAuthMiddleware identifies the caller when a valid Bearer token is sent,
+// but lets anonymous requests through (used by public routes that shape responses by role)
+func OptionalAuthMiddleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		tokenParts := strings.Split(ctx.GetHeader("Authorization"), " ")
+		if len(tokenParts) != 2 || tokenParts[0] != "Bearer" {
+			ctx.Next()
+			return
+		}
+
+		claims, err := VerifyToken(tokenParts[1])
+		if err != nil {
+			ctx.Next()
+			return
+		}
+
+		userIDFloat, idOK := claims["user_id"].(float64)
+		roleString, roleOK := claims["role"].(string)
+		if idOK && roleOK {
+			ctx.Set("userID", uint(userIDFloat))
+			ctx.Set("role", roleString)
+		}
+
+		ctx.Next()
+	}
+}
+
 // RoleMiddleware checks if the user has required roles
 func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
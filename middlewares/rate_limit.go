@@ -0,0 +1,81 @@
+package middlewares
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxRateClients bounds how many clients one limiter tracks; past it the oldest window is dropped
+const maxRateClients = 10000
+
+type rateWindow struct {
+	count int
+	reset time.Time
+}
+
+// rateExpiry queues a window by its reset time. Every window has the same length, so the
+// queue is already sorted and expired clients are always at the front.
+type rateExpiry struct {
+	key   string
+	reset time.Time
+}
+
+// RateLimitMiddleware allows each client IP `limit` requests per `window`.
+// Counters live in memory (fixed windows), which is enough for a single instance.
+// The client IP comes from ctx.ClientIP, so forwarded headers only count when the
+// request came through a proxy listed in TRUSTED_PROXIES (see main.go).
+func RateLimitMiddleware(limit int, window time.Duration) gin.HandlerFunc {
+	var mu sync.Mutex
+	windows := map[string]*rateWindow{}
+	var queue []rateExpiry
+
+	// drop removes the front of the queue, forgetting the client unless its window was renewed since
+	drop := func() {
+		e := queue[0]
+		queue = queue[1:]
+		if w, ok := windows[e.key]; ok && w.reset.Equal(e.reset) {
+			delete(windows, e.key)
+		}
+	}
+
+	return func(ctx *gin.Context) {
+		ip := ctx.ClientIP()
+		now := time.Now()
+
+		mu.Lock()
+		for len(queue) > 0 && now.After(queue[0].reset) {
+			drop()
+		}
+		w, ok := windows[ip]
+		if !ok || now.After(w.reset) {
+			if !ok {
+				for len(windows) >= maxRateClients && len(queue) > 0 {
+					drop()
+				}
+			}
+			w = &rateWindow{reset: now.Add(window)}
+			windows[ip] = w
+			queue = append(queue, rateExpiry{key: ip, reset: w.reset})
+		}
+		w.count++
+		count, reset := w.count, w.reset
+		mu.Unlock()
+
+		remaining := limit - count
+		if remaining < 0 {
+			remaining = 0
+		}
+		ctx.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		ctx.Header("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
+		if count > limit {
+			ctx.Header("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+			ctx.AbortWithStatusJSON(429, gin.H{"error": "too many requests, try again later"})
+			return
+		}
+		ctx.Next()
+	}
+}
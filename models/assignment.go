@@ -2,6 +2,25 @@ package models
 
 import "time"
 
+// Question types
+const (
+    QuestionTypeMCQ  = "mcq"  // one or more options; all correct options must be picked
+    QuestionTypeText = "text" // free-text answer, graded manually
+)
+
+// How a SubmissionAnswer got its points
+const (
+    GradingAuto   = "auto"
+    GradingManual = "manual"
+)
+
+// Review policies: when students may see their answers, the answer key and feedback
+const (
+    ReviewNever           = "never"
+    ReviewAfterSubmission = "after_submission"
+    ReviewAfterDueDate    = "after_due_date"
+)
+
 // Assignment represents a test/quiz for a course
 type Assignment struct {
     ID          uint          `gorm:"primaryKey;autoIncrement" json:"id"`
@@ -16,18 +35,40 @@ type Assignment struct {
 
     Questions []Question `gorm:"constraint:OnDelete:CASCADE" json:"questions"`
 
+    // Randomised papers: questions drawn from banks per student + shuffling
+    Rules            []AssignmentRule `gorm:"constraint:OnDelete:CASCADE" json:"rules,omitempty"`
+    ShuffleQuestions bool             `gorm:"default:false" json:"shuffle_questions"`
+    ShuffleOptions   bool             `gorm:"default:false" json:"shuffle_options"`
+
+    CategoryID *uint `gorm:"index" json:"category_id,omitempty"` // gradebook category
+    RubricID   *uint `json:"rubric_id,omitempty"`                 // rubric for grading the whole submission
+    Required   bool  `gorm:"default:false" json:"required"`          // must be submitted to complete the course
+
+    DueDate      *time.Time `json:"due_date,omitempty"`
+    ReviewPolicy string     `gorm:"size:20;default:'never'" json:"review_policy"` // never | after_submission | after_due_date
+
     CreatedAt time.Time `json:"created_at"`
     UpdatedAt time.Time `json:"updated_at"`
 }
 
 
-// Question represents an MCQ inside an assignment
+// Question represents an MCQ inside an assignment or inside a question bank.
+// Fixed assignment questions have AssignmentID set; bank questions have BankID set.
 type Question struct {
 	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	AssignmentID  uint      `gorm:"not null" json:"assignment_id"`
+	AssignmentID  *uint     `gorm:"index" json:"assignment_id,omitempty"`
 	Assignment   Assignment `gorm:"foreignKey:AssignmentID"`
+	BankID        *uint     `gorm:"index" json:"bank_id,omitempty"`
+
+	Difficulty string `gorm:"size:20;default:'medium'" json:"difficulty"`
+	Tags       string `gorm:"size:255" json:"tags,omitempty"` // comma-separated, e.g. "loops,arrays"
+
+	Type     string `gorm:"size:10;default:'mcq'" json:"type"` // mcq | text
+	Points   int    `gorm:"not null;default:1" json:"points"`
+	RubricID *uint  `json:"rubric_id,omitempty"` // rubric for grading answers to this question by hand
 
 	Text  string    `gorm:"type:text;not null" json:"question_text"`
+	Feedback string `gorm:"type:text" json:"feedback,omitempty"` // shown to students once review is open
 	Options       []Option  `gorm:"constraint:OnDelete:CASCADE" json:"options"`
 	CorrectOption uint      `json:"correct_option"` // Reference to Option.ID
 }
@@ -50,6 +91,50 @@ type Submission struct {
 	UserID uint `gorm:"not null" json:"user_id"`
 	User   User `gorm:"foreignKey:UserID"`
 
+	AttemptID *uint `json:"attempt_id,omitempty"` // set when the paper was drawn from banks
+
 	Score      int       `json:"score"`       // Calculated score
+	MaxScore   int       `json:"max_score"`
+	NeedsGrading bool    `gorm:"default:false" json:"needs_grading"` // text answers waiting for a teacher
+
+	// Peer review: average of the included peer reviews and the grade blended with Score/MaxScore
+	PeerPercent  *float64 `json:"peer_percent,omitempty"`
+	FinalPercent *float64 `json:"final_percent,omitempty"`
 	SubmittedAt time.Time `json:"submitted_at"`
+
+	Answers           []SubmissionAnswer `gorm:"constraint:OnDelete:CASCADE" json:"answers,omitempty"`
+	RubricAssessments []RubricAssessment `gorm:"constraint:OnDelete:CASCADE" json:"rubric_assessments,omitempty"`
+}
+
+// SubmissionAnswer is one question's answer inside a Submission
+type SubmissionAnswer struct {
+	ID           uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	SubmissionID uint     `gorm:"not null;index" json:"submission_id"`
+	QuestionID   uint     `gorm:"not null;index" json:"question_id"`
+	Question     *Question `gorm:"foreignKey:QuestionID;constraint:OnDelete:RESTRICT" json:"question,omitempty"` // answers keep their question
+
+	SelectedOptionIDs []uint `gorm:"serializer:json;type:text" json:"selected_option_ids"`
+	TextAnswer        string `gorm:"type:text" json:"text_answer,omitempty"`
+
+	IsCorrect     bool   `json:"is_correct"`
+	AutoPoints    int    `json:"auto_points"` // what the auto-grader gave, kept after overrides
+	PointsAwarded int    `json:"points_awarded"`
+	MaxPoints     int    `json:"max_points"`
+	GradingMode   string `gorm:"size:10;default:'auto'" json:"grading_mode"` // auto | manual
+	NeedsGrading  bool   `gorm:"default:false" json:"needs_grading"`
+
+	// Manual override trail
+	OverrideReason string     `gorm:"type:text" json:"override_reason,omitempty"`
+	OverriddenBy   *uint      `json:"overridden_by,omitempty"`
+	OverriddenAt   *time.Time `json:"overridden_at,omitempty"`
+}
+
+// AssignmentStats caches computed item analysis for an assignment.
+// Fingerprint describes the submissions it was computed from; a mismatch means it is stale.
+type AssignmentStats struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	AssignmentID uint      `gorm:"uniqueIndex;not null" json:"assignment_id"`
+	Fingerprint  string    `gorm:"size:100" json:"fingerprint"`
+	Data         string    `gorm:"type:mediumtext" json:"-"` // JSON of the analytics response
+	ComputedAt   time.Time `json:"computed_at"`
 }
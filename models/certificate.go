@@ -13,4 +13,48 @@ type Certificate struct {
 	CertCode      string    `gorm:"size:100;unique;not null" json:"code"`
 	URL       string    `gorm:"size:255" json:"url"`
 	IssuedAt  time.Time `json:"issued_at"`
+
+	// Holder name and course title as signed and printed; renaming the user or course later
+	// does not change an issued certificate (reissue it to pick up a correction)
+	HolderName  string `gorm:"size:255" json:"holder_name"`
+	CourseTitle string `gorm:"size:255" json:"course_title"`
+
+	// Ed25519 signature (base64url) over the canonical payload: holder name, course title, issue time, code
+	Signature    string `gorm:"size:128" json:"signature,omitempty"`
+	SigningKeyID string `gorm:"size:32" json:"signing_key_id,omitempty"`
+
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	RevokedReason string     `gorm:"type:text" json:"revoked_reason,omitempty"`
+
+	// One live certificate per enrollment: ActiveEnrollmentID mirrors EnrollmentID while the
+	// certificate is neither revoked nor superseded, and is NULL otherwise (NULLs never collide)
+	EnrollmentID       *uint `gorm:"index" json:"enrollment_id,omitempty"`
+	ActiveEnrollmentID *uint `gorm:"uniqueIndex" json:"-"`
+
+	// Reissue: the old certificate stays verifiable and points at its replacement
+	ReplacesID     *uint        `json:"replaces_id,omitempty"`
+	SupersededByID *uint        `json:"superseded_by_id,omitempty"`
+	SupersededBy   *Certificate `gorm:"foreignKey:SupersededByID" json:"superseded_by,omitempty"`
+	SupersededAt   *time.Time   `json:"superseded_at,omitempty"`
+
+	Events []CertificateEvent `gorm:"constraint:OnDelete:CASCADE" json:"events,omitempty"`
+}
+
+// Certificate lifecycle events
+const (
+	CertificateIssued     = "issued"
+	CertificateReissued   = "reissued"   // this certificate replaced an earlier one
+	CertificateSuperseded = "superseded" // this certificate was replaced by a reissue
+	CertificateRevoked    = "revoked"
+)
+
+// CertificateEvent is one entry in a certificate's audit history
+type CertificateEvent struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CertificateID uint      `gorm:"index;not null" json:"certificate_id"`
+	Type          string    `gorm:"size:20;not null" json:"type"`
+	Reason        string    `gorm:"type:text" json:"reason,omitempty"`
+	RelatedCode   string    `gorm:"size:100" json:"related_code,omitempty"` // the other certificate in a reissue
+	ActorID       *uint     `json:"actor_id,omitempty"`                     // nil when issued automatically
+	CreatedAt     time.Time `json:"created_at"`
 }
\ No newline at end of file
@@ -0,0 +1,77 @@
+package models
+
+import "time"
+
+// Certificate layout orientations
+const (
+	OrientationPortrait  = "portrait"
+	OrientationLandscape = "landscape"
+)
+
+// Kinds of positioned elements on a certificate template
+const (
+	ElementText  = "text"  // Content is a text/template, e.g. "Awarded to {{.User.Name}}"
+	ElementImage = "image" // an uploaded image asset: logo, signature, seal
+	ElementQR    = "qr"    // QR code of the verification URL and signature
+)
+
+// Kinds of files uploaded for a template
+const (
+	AssetImage = "image"
+	AssetFont  = "font"
+)
+
+// CertificateTemplate is an admin-managed PDF layout for certificates.
+// CourseID set → used for that course; DepartmentID set → for the department's courses;
+// neither → the platform default. The most specific template wins.
+type CertificateTemplate struct {
+	ID           uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Name         string `gorm:"size:255;not null" json:"name"`
+	CourseID     *uint  `gorm:"index" json:"course_id,omitempty"`
+	DepartmentID *uint  `gorm:"index" json:"department_id,omitempty"`
+
+	Orientation string `gorm:"size:20;not null;default:'landscape'" json:"orientation"`
+	Background  string `gorm:"size:255" json:"background,omitempty"` // name of an image asset drawn over the whole page
+
+	Elements []CertificateTemplateElement `gorm:"foreignKey:TemplateID;constraint:OnDelete:CASCADE" json:"elements"`
+	Assets   []CertificateTemplateAsset   `gorm:"foreignKey:TemplateID;constraint:OnDelete:CASCADE" json:"assets,omitempty"`
+
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"` // also versions the PDF cache
+}
+
+// CertificateTemplateElement is one positioned item on the page; units are millimetres from the top left
+type CertificateTemplateElement struct {
+	ID         uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	TemplateID uint    `gorm:"index;not null" json:"template_id"`
+	Type       string  `gorm:"size:20;not null" json:"type"`
+	X          float64 `json:"x"`
+	Y          float64 `json:"y"`
+	Width      float64 `json:"width"`
+	Height     float64 `json:"height"`
+
+	// text elements
+	Content  string  `gorm:"type:text" json:"content,omitempty"`
+	Font     string  `gorm:"size:100" json:"font,omitempty"` // core font (Arial, Times, Courier) or a font asset name
+	Style    string  `gorm:"size:4" json:"style,omitempty"`  // any of B, I, U
+	FontSize float64 `json:"font_size,omitempty"`            // points
+	Color    string  `gorm:"size:7" json:"color,omitempty"`  // #RRGGBB
+	Align    string  `gorm:"size:1" json:"align,omitempty"`  // L, C or R
+
+	// image elements
+	Asset string `gorm:"size:255" json:"asset,omitempty"`
+
+	OrderIndex int `gorm:"default:0" json:"order_index"`
+}
+
+// CertificateTemplateAsset is an uploaded image or TrueType font, referenced by name from the layout
+type CertificateTemplateAsset struct {
+	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TemplateID uint      `gorm:"index;not null;uniqueIndex:idx_template_asset_name" json:"template_id"`
+	Kind       string    `gorm:"size:10;not null" json:"kind"`
+	Name       string    `gorm:"size:255;not null;uniqueIndex:idx_template_asset_name" json:"name"`
+	Path       string    `gorm:"size:512;not null" json:"-"`
+	Size       int64     `json:"size"`
+	CreatedAt  time.Time `json:"created_at"`
+}
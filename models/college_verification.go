@@ -4,13 +4,35 @@ import (
 	"time"
 )
 
+// College verification statuses
+const (
+	VerificationPending  = "pending"
+	VerificationVerified = "verified"
+	VerificationRejected = "rejected"
+	VerificationExpired  = "expired" // academic year ended; the student submits a new request
+)
+
+// CollegeVerification is a student's request to be verified as enrolled at a college, reviewed
+// by an admin. The latest verified request drives Profile.Verified until it expires.
 type CollegeVerification struct {
-	ID         uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID     uint      `gorm:"index;not null" json:"user_id"`
-	User       User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user"`
-	College    string    `gorm:"size:100;not null" json:"college"`
-	Document   string    `gorm:"size:255;not null" json:"document"` // uploaded document
-	Status     string    `gorm:"type:enum('pending','verified','rejected');default:'pending'" json:"status"`
-	VerifiedAt time.Time `json:"verified_at"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID           uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       uint   `gorm:"index;not null" json:"user_id"`
+	User         *User  `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user,omitempty"`
+	College      string `gorm:"size:100;not null" json:"college"`
+	StudentID    string `gorm:"size:20" json:"student_id"`  // college enrollment number, copied to the profile
+	Document     string `gorm:"size:255;not null" json:"-"` // stored file path; served by the document endpoint
+	DocumentName string `gorm:"size:255" json:"document_name"`
+	DocumentType string `gorm:"size:100" json:"document_type"`
+	Status       string `gorm:"size:20;index;default:'pending'" json:"status"` // pending | verified | rejected | expired
+
+	ReviewedBy      *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt      *time.Time `json:"reviewed_at,omitempty"`
+	RejectionReason string     `gorm:"type:text" json:"rejection_reason,omitempty"`
+	ReviewNote      string     `gorm:"type:text" json:"review_note,omitempty"`
+
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at,omitempty"` // end of the academic year it was verified in
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// CompletionRule decides when an enrollment counts as completed. Every condition must hold;
+// assignments flagged Required must also have been submitted. Courses without a rule use
+// the defaults: 100% progress, no minimum grade, certificate issued and emailed.
+type CompletionRule struct {
+	ID       uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	CourseID uint `gorm:"uniqueIndex;not null" json:"course_id"`
+
+	MinProgress   float32  `gorm:"not null" json:"min_progress"` // % of assignments submitted
+	MinFinalGrade *float64 `json:"min_final_grade,omitempty"`    // gradebook final %, nil = no minimum
+
+	AutoIssueCertificate bool `gorm:"not null" json:"auto_issue_certificate"`
+	EmailCertificate     bool `gorm:"not null" json:"email_certificate"` // PDF attached to the completion email
+
+	UpdatedBy uint      `json:"updated_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
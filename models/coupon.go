@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// Coupon discount types
+const (
+	CouponPercent = "percent"
+	CouponFixed   = "fixed"
+)
+
+// Coupon is a discount code. Restrictions are optional: a coupon with no course or department
+// applies to every paid course. Usage caps count redemptions on pending and successful payments.
+type Coupon struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Code        string `gorm:"size:40;uniqueIndex;not null" json:"code"` // stored upper-case
+	Description string `gorm:"size:255" json:"description,omitempty"`
+
+	Type        string   `gorm:"size:10;not null" json:"type"`     // percent | fixed
+	Value       float64  `gorm:"not null" json:"value"`            // percent off, or amount off in Currency
+	MaxDiscount *float64 `json:"max_discount,omitempty"`           // cap for percent coupons
+	Currency    string   `gorm:"size:3" json:"currency,omitempty"` // required for fixed coupons
+
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+
+	MaxUses        int `gorm:"not null;default:0" json:"max_uses"`          // 0 = unlimited
+	MaxUsesPerUser int `gorm:"not null;default:0" json:"max_uses_per_user"` // 0 = unlimited
+
+	CourseID     *uint `gorm:"index" json:"course_id,omitempty"`
+	DepartmentID *uint `gorm:"index" json:"department_id,omitempty"`
+
+	// Stacking with the verified-student discount: applied after it on the reduced price,
+	// or (false) only the larger of the two is given
+	StacksWithVerified bool `gorm:"not null;default:false" json:"stacks_with_verified"`
+
+	Disabled  bool      `gorm:"not null;default:false" json:"disabled"`
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CouponRedemption ties a coupon to the payment it discounted
+type CouponRedemption struct {
+	ID        uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	CouponID  uint      `gorm:"index;not null" json:"coupon_id"`
+	Coupon    *Coupon   `gorm:"foreignKey:CouponID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"coupon,omitempty"`
+	UserID    uint      `gorm:"index;not null" json:"user_id"`
+	PaymentID uint      `gorm:"uniqueIndex;not null" json:"payment_id"`
+	Payment   *Payment  `gorm:"foreignKey:PaymentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"payment,omitempty"`
+	Discount  float64   `json:"discount"`
+	CreatedAt time.Time `json:"created_at"`
+}
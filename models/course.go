@@ -27,6 +27,11 @@ type Course struct {
 	Code  string `gorm:"size:50;uniqueIndex;not null" json:"code" binding:"required"`
 	Description string `gorm:"type:text" json:"description,omitempty"`
 	Credits int `gorm:"not null;default:3" json:"credits" binding:"required,min=1,max=10"`
+
+	// Pricing: a course is paid unless IsFree is set; a course without a price is saved as free
+	Currency string `gorm:"size:3;default:'INR'" json:"currency"`
+	Price float64 `gorm:"not null;default:0" json:"price" binding:"min=0"`
+	IsFree bool `gorm:"not null;default:false" json:"is_free"`
 	
 	// Add other fields you need here (e.g., Level, Language)
 	// Example: Level string `gorm:"size:50" json:"level"` 
@@ -35,6 +40,11 @@ type Course struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// RequiresPayment → enrollment has to be paid for
+func (c Course) RequiresPayment() bool {
+	return !c.IsFree && c.Price > 0
+}
+
 // ... Lecture struct follows ...
 
 
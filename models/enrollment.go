@@ -4,6 +4,13 @@ import (
 	"time"
 )
 
+// Enrollment statuses
+const (
+	EnrollmentActive    = "active"
+	EnrollmentCompleted = "completed"
+	EnrollmentSuspended = "suspended" // course fee refunded
+)
+
 type Enrollment struct {
 	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
 	UserID       uint       `gorm:"not null;uniqueIndex:idx_user_course" json:"user_id"`
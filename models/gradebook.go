@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// GradeCategory groups a course's assignments for weighting, e.g. "Quizzes" 20%
+type GradeCategory struct {
+	ID         uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	CourseID   uint    `gorm:"index;not null" json:"course_id"`
+	Name       string  `gorm:"size:100;not null" json:"name"`
+	Weight     float64 `gorm:"not null" json:"weight"`                // percent of the final grade
+	DropLowest int     `gorm:"not null;default:0" json:"drop_lowest"` // lowest N assignment grades ignored
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GradeOverride replaces a computed grade for one student.
+// AssignmentID nil overrides the student's final course grade.
+type GradeOverride struct {
+	ID           uint  `gorm:"primaryKey;autoIncrement" json:"id"`
+	CourseID     uint  `gorm:"index;not null" json:"course_id"`
+	UserID       uint  `gorm:"index;not null" json:"user_id"`
+	AssignmentID *uint `gorm:"index" json:"assignment_id,omitempty"`
+
+	Percent      float64 `gorm:"not null" json:"percent"`
+	Reason       string  `gorm:"type:text" json:"reason"`
+	OverriddenBy uint    `json:"overridden_by"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// GradeScaleEntry maps a minimum percentage to a letter grade for a course.
+// Courses without entries use the default scale.
+type GradeScaleEntry struct {
+	ID         uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	CourseID   uint    `gorm:"index;not null" json:"course_id"`
+	Letter     string  `gorm:"size:5;not null" json:"letter"`
+	MinPercent float64 `gorm:"not null" json:"min_percent"`
+}
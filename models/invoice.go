@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+// InvoiceSequence hands out invoice numbers for one financial year. It is locked and bumped in
+// the transaction that creates the invoice, so a rolled back payment leaves no gap.
+type InvoiceSequence struct {
+	ID            uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	FinancialYear string `gorm:"size:7;uniqueIndex;not null" json:"financial_year"` // e.g. 2026-27
+	LastNumber    int    `gorm:"not null;default:0" json:"last_number"`
+}
+
+// Invoice is issued for each successful paid checkout. Amounts are GST inclusive; the seller and
+// buyer details are copied in so later profile changes do not alter an issued invoice.
+type Invoice struct {
+	ID            uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Number        string `gorm:"size:40;uniqueIndex;not null" json:"number"` // INV/2026-27/00001
+	FinancialYear string `gorm:"size:7;index;not null" json:"financial_year"`
+	Sequence      int    `gorm:"not null" json:"sequence"`
+
+	PaymentID uint     `gorm:"uniqueIndex;not null" json:"payment_id"`
+	Payment   *Payment `gorm:"foreignKey:PaymentID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"-"`
+	UserID    uint     `gorm:"index;not null" json:"user_id"`
+	CourseID  uint     `gorm:"index;not null" json:"course_id"`
+
+	SellerName      string `gorm:"size:255" json:"seller_name"`
+	SellerAddress   string `gorm:"type:text" json:"seller_address,omitempty"`
+	SellerGSTIN     string `gorm:"size:15" json:"seller_gstin,omitempty"`
+	SellerStateCode string `gorm:"size:2" json:"seller_state_code,omitempty"`
+
+	BuyerName     string `gorm:"size:255" json:"buyer_name"`
+	BuyerEmail    string `gorm:"size:100" json:"buyer_email"`
+	BuyerAddress  string `gorm:"type:text" json:"buyer_address,omitempty"`
+	BuyerGSTIN    string `gorm:"size:15" json:"buyer_gstin,omitempty"`
+	PlaceOfSupply string `gorm:"size:2" json:"place_of_supply,omitempty"`   // buyer's state code
+	TaxInvoice    bool   `gorm:"not null;default:false" json:"tax_invoice"` // false: receipt, seller not GST registered
+
+	Currency string  `gorm:"size:3" json:"currency"`
+	Taxable  float64 `json:"taxable"`
+	CGST     float64 `json:"cgst"`
+	SGST     float64 `json:"sgst"`
+	IGST     float64 `json:"igst"`
+	Total    float64 `json:"total"`
+
+	Lines []InvoiceLine `gorm:"foreignKey:InvoiceID;constraint:OnDelete:CASCADE;" json:"lines"`
+
+	IssuedAt  time.Time  `json:"issued_at"`
+	EmailedAt *time.Time `json:"emailed_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// InvoiceLine is one item on an invoice
+type InvoiceLine struct {
+	ID          uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	InvoiceID   uint    `gorm:"index;not null" json:"invoice_id"`
+	Description string  `gorm:"size:255" json:"description"`
+	SAC         string  `gorm:"size:10" json:"sac"`
+	Quantity    int     `json:"quantity"`
+	ListPrice   float64 `json:"list_price"` // GST inclusive, before discounts
+	Discount    float64 `json:"discount"`
+	Taxable     float64 `json:"taxable"`
+	TaxRate     float64 `json:"tax_rate"` // percent
+	CGST        float64 `json:"cgst"`
+	SGST        float64 `json:"sgst"`
+	IGST        float64 `json:"igst"`
+	Total       float64 `json:"total"`
+}
+
+// BillingDetails is what a student or college wants printed on their invoices
+type BillingDetails struct {
+	ID        uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    uint   `gorm:"uniqueIndex;not null" json:"user_id"`
+	Name      string `gorm:"size:255" json:"name"` // e.g. the college; defaults to the user's name
+	Address   string `gorm:"type:text" json:"address"`
+	GSTIN     string `gorm:"size:15" json:"gstin,omitempty"`
+	StateCode string `gorm:"size:2" json:"state_code"` // place of supply
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
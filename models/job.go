@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// Job statuses
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// Job tracks a background task (regrades, bulk operations) so clients can poll its progress
+type Job struct {
+	ID     uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Type   string `gorm:"size:50;index;not null" json:"type"`
+	Status string `gorm:"size:20;default:'queued'" json:"status"` // queued | running | completed | failed
+
+	Total     int `json:"total"`
+	Processed int `json:"processed"`
+
+	Error  string `gorm:"type:text" json:"error,omitempty"`
+	Result string `gorm:"type:text" json:"result,omitempty"` // short summary or artifact path
+
+	// File produced by the job (e.g. a ZIP export), downloaded via GET /jobs/:id/artifact
+	ArtifactPath string `gorm:"size:512" json:"-"`
+	ArtifactName string `gorm:"size:255" json:"artifact_name,omitempty"`
+
+	CreatedBy uint `gorm:"index" json:"created_by"` // 0 for system jobs, which only admins can view
+
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Ledger accounts. Postings carry the course, teacher and department they belong to, so one
+// course_revenue account reports per teacher or department without an account per owner.
+const (
+	AccountGatewayClearing   = "gateway_clearing"   // asset: money collected and held by the gateway
+	AccountStudentReceivable = "student_receivable" // asset: owed by the student for a sale
+	AccountCourseRevenue     = "course_revenue"     // income, at list price net of GST
+	AccountDiscounts         = "discounts"          // contra income: coupons and verified-student discounts
+	AccountRefunds           = "refunds"            // contra income, net of GST
+	AccountGSTPayable        = "gst_payable"        // liability: output tax collected
+	AccountGatewayFees       = "gateway_fees"       // expense: gateway charges incl. their GST
+)
+
+// Ledger entry kinds
+const (
+	LedgerSale       = "sale"       // student owes the course price less discounts
+	LedgerCollection = "collection" // gateway collected the payment
+	LedgerGatewayFee = "gateway_fee"
+	LedgerRefund     = "refund"
+	LedgerReversal   = "reversal" // admin correction; mirrors another entry
+)
+
+// ErrLedgerImmutable is returned when something tries to change a posted entry
+var ErrLedgerImmutable = errors.New("ledger entries are append-only; post a reversal instead")
+
+// LedgerEntry is one balanced journal entry. Entries are never updated or deleted: mistakes
+// are corrected with a reversal entry. Reference makes posting idempotent (e.g. "payment:12:sale").
+type LedgerEntry struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Kind       string `gorm:"size:20;index;not null" json:"kind"`
+	Reference  string `gorm:"size:100;uniqueIndex;not null" json:"reference"`
+	Memo       string `gorm:"size:255" json:"memo"`
+	Currency   string `gorm:"size:3" json:"currency"`
+	PaymentID  *uint  `gorm:"index" json:"payment_id,omitempty"`
+	RefundID   *uint  `gorm:"index" json:"refund_id,omitempty"`   // RefundRequest
+	ReversesID *uint  `gorm:"index" json:"reverses_id,omitempty"` // reversal entries only
+	CreatedBy  *uint  `json:"created_by,omitempty"`               // nil for system postings
+
+	Postings []LedgerPosting `gorm:"foreignKey:EntryID;constraint:OnDelete:RESTRICT;" json:"postings"`
+
+	OccurredAt time.Time `gorm:"index" json:"occurred_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// LedgerPosting is one debit or credit line of an entry
+type LedgerPosting struct {
+	ID      uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	EntryID uint    `gorm:"index;not null" json:"entry_id"`
+	Account string  `gorm:"size:30;index;not null" json:"account"`
+	Debit   float64 `gorm:"not null;default:0" json:"debit"`
+	Credit  float64 `gorm:"not null;default:0" json:"credit"`
+
+	// Dimensions, copied from the payment and course at posting time
+	PaymentID    *uint `gorm:"index" json:"payment_id,omitempty"`
+	UserID       uint  `gorm:"index" json:"user_id,omitempty"` // student
+	CourseID     uint  `gorm:"index" json:"course_id,omitempty"`
+	TeacherID    uint  `gorm:"index" json:"teacher_id,omitempty"`
+	DepartmentID uint  `gorm:"index" json:"department_id,omitempty"`
+
+	OccurredAt time.Time `gorm:"index" json:"occurred_at"`
+}
+
+// GORM hooks refusing updates and deletes
+func (*LedgerEntry) BeforeUpdate(*gorm.DB) error   { return ErrLedgerImmutable }
+func (*LedgerEntry) BeforeDelete(*gorm.DB) error   { return ErrLedgerImmutable }
+func (*LedgerPosting) BeforeUpdate(*gorm.DB) error { return ErrLedgerImmutable }
+func (*LedgerPosting) BeforeDelete(*gorm.DB) error { return ErrLedgerImmutable }
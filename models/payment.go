@@ -4,16 +4,60 @@ import (
 	"time"
 )
 
+// Payment statuses
+const (
+	PaymentPending           = "pending"
+	PaymentSuccess           = "success"
+	PaymentFailed            = "failed"
+	PaymentExpired           = "expired" // left pending past the checkout window
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+)
+
 type Payment struct {
+	ID              uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID          uint    `gorm:"index;not null" json:"user_id"`
+	User            *User   `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user,omitempty"`
+	CourseID        uint    `gorm:"index;not null" json:"course_id"`
+	Course          *Course `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"course,omitempty"`
+	Amount          float64 `gorm:"not null" json:"amount"`
+	Currency        string  `gorm:"size:3;default:'INR'" json:"currency"`
+	Status          string  `gorm:"size:20;index;default:'pending'" json:"status"` // pending | success | failed | expired | partially_refunded | refunded
+	TransactionID   string  `gorm:"size:100;unique" json:"transaction_id"`         // gateway order id
+	PaymentMethod   string  `gorm:"size:50" json:"payment_method"`                 // e.g., card, upi
+	DiscountApplied float64 `json:"discount_applied"`                              // Discount applied if any
+
+	// Gateway side of the payment, filled in by webhooks
+	Gateway          string     `gorm:"size:20" json:"gateway"`
+	GatewayPaymentID string     `gorm:"size:100;index" json:"gateway_payment_id,omitempty"`
+	FailureReason    string     `gorm:"size:255" json:"failure_reason,omitempty"`
+	PaidAt           *time.Time `json:"paid_at,omitempty"`
+
+	RefundedAmount float64    `gorm:"not null;default:0" json:"refunded_amount"`
+	RefundedAt     *time.Time `json:"refunded_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Outcomes of processing a gateway webhook
+const (
+	PaymentEventApplied        = "applied"
+	PaymentEventUnchanged      = "unchanged" // payment already in that state, or a stale event
+	PaymentEventIgnored        = "ignored"   // event type we do not act on
+	PaymentEventUnknownOrder   = "unknown_order"
+	PaymentEventAmountMismatch = "amount_mismatch"
+)
+
+// PaymentEvent records each webhook delivery once, so redeliveries are acknowledged without reprocessing
+type PaymentEvent struct {
 	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID        uint      `gorm:"index;not null" json:"user_id"`
-	User          User      `gorm:"foreignKey:UserID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"user"`
-	CourseID      uint      `gorm:"index;not null" json:"course_id"`
-	Course        Course    `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"course"`
-	Amount        float64   `gorm:"not null" json:"amount"`
-	Status        string    `gorm:"type:enum('pending','success','failed');default:'pending'" json:"status"`
-	TransactionID string    `gorm:"size:100;unique" json:"transaction_id"`
-	PaymentMethod string    `gorm:"size:50" json:"payment_method"` // e.g., card, upi
+	Gateway       string    `gorm:"size:20" json:"gateway"`
+	EventID       string    `gorm:"size:100;uniqueIndex;not null" json:"event_id"`
+	Type          string    `gorm:"size:50" json:"type"`
+	TransactionID string    `gorm:"size:100;index" json:"transaction_id"`
+	PaymentID     *uint     `gorm:"index" json:"payment_id,omitempty"`
+	Result        string    `gorm:"size:20" json:"result"`
+	Payload       string    `gorm:"type:text" json:"-"`
 	CreatedAt     time.Time `json:"created_at"`
-    DiscountApplied float64 `json:"discount_applied"` // Discount applied if any
 }
@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// RevenueShareRule gives teachers a percentage of what their courses earn. The most specific
+// rule in effect wins: a teacher's own rule, then their course's department, then the default
+// (neither set).
+type RevenueShareRule struct {
+	ID           uint    `gorm:"primaryKey;autoIncrement" json:"id"`
+	TeacherID    *uint   `gorm:"index" json:"teacher_id,omitempty"`
+	DepartmentID *uint   `gorm:"index" json:"department_id,omitempty"`
+	Percent      float64 `gorm:"not null" json:"percent"` // of net revenue: after discounts, refunds, GST and gateway fees
+
+	EffectiveFrom  time.Time  `gorm:"index" json:"effective_from"`
+	EffectiveUntil *time.Time `json:"effective_until,omitempty"` // exclusive; nil is open ended
+	Note           string     `gorm:"size:255" json:"note,omitempty"`
+
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Payout statement statuses
+const (
+	PayoutDraft    = "draft" // can be regenerated
+	PayoutApproved = "approved"
+	PayoutRejected = "rejected" // regenerating returns it to draft
+	PayoutPaid     = "paid"
+)
+
+// PayoutStatement is a teacher's earnings for one calendar month, derived from the ledger.
+// A month whose refunds exceed its sales carries the negative balance into the next statement.
+type PayoutStatement struct {
+	ID          uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TeacherID   uint      `gorm:"uniqueIndex:idx_payout_teacher_period;not null" json:"teacher_id"`
+	Teacher     *User     `gorm:"foreignKey:TeacherID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;" json:"teacher,omitempty"`
+	Period      string    `gorm:"size:7;uniqueIndex:idx_payout_teacher_period;not null" json:"period"` // 2026-09
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"` // exclusive
+	Currency    string    `gorm:"size:3" json:"currency"`
+
+	Sales     int     `json:"sales"`
+	Gross     float64 `json:"gross"` // revenue at list price, excluding GST
+	Discounts float64 `json:"discounts"`
+	Refunds   float64 `json:"refunds"`
+	Fees      float64 `json:"fees"`
+	Net       float64 `json:"net"`
+	Share     float64 `json:"share"`      // teacher's cut of Net for the month
+	CarriedIn float64 `json:"carried_in"` // negative balance from the previous statement
+	Payable   float64 `json:"payable"`    // Share + CarriedIn; nothing is paid out below zero
+	Status    string  `gorm:"size:20;index;default:'draft'" json:"status"`
+
+	Lines []PayoutLine `gorm:"foreignKey:StatementID;constraint:OnDelete:CASCADE;" json:"lines,omitempty"`
+
+	ReviewedBy       *uint      `json:"reviewed_by,omitempty"`
+	ReviewedAt       *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote       string     `gorm:"type:text" json:"review_note,omitempty"`
+	PaidAt           *time.Time `json:"paid_at,omitempty"`
+	PaymentReference string     `gorm:"size:100" json:"payment_reference,omitempty"` // bank transfer / UTR
+
+	GeneratedAt time.Time `json:"generated_at"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PayoutLine is one course (under one rule) on a statement
+type PayoutLine struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	StatementID uint   `gorm:"index;not null" json:"statement_id"`
+	CourseID    uint   `gorm:"index" json:"course_id"`
+	CourseTitle string `gorm:"size:255" json:"course_title"`
+	RuleID      *uint  `json:"rule_id,omitempty"` // nil: no rule applied, share is 0
+
+	Sales     int     `json:"sales"`
+	Gross     float64 `json:"gross"`
+	Discounts float64 `json:"discounts"`
+	Refunds   float64 `json:"refunds"`
+	Fees      float64 `json:"fees"`
+	Net       float64 `json:"net"`
+	Percent   float64 `json:"percent"`
+	Share     float64 `json:"share"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Peer review statuses
+const (
+	PeerReviewAssigned  = "assigned"
+	PeerReviewCompleted = "completed"
+)
+
+// PeerReviewSettings turns on peer review for an assignment
+type PeerReviewSettings struct {
+	ID           uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	AssignmentID uint `gorm:"uniqueIndex;not null" json:"assignment_id"`
+	RubricID     uint `gorm:"not null" json:"rubric_id"`
+
+	// No gorm defaults: GORM would skip a deliberate 0 on insert. SetPeerReviewSettings fills them in.
+	ReviewsPerSubmission int        `gorm:"not null" json:"reviews_per_submission"`
+	PeerWeight           float64    `gorm:"not null" json:"peer_weight"`       // percent of the final grade from peers
+	OutlierThreshold     float64    `gorm:"not null" json:"outlier_threshold"` // percentage points away from the median
+	ReviewDueDate        *time.Time `json:"review_due_date,omitempty"`
+
+	AllocatedAt *time.Time `json:"allocated_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PeerReview is one student's review of another student's submission.
+// Reviewer and author are never shown to each other.
+type PeerReview struct {
+	ID           uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	AssignmentID uint `gorm:"index;not null" json:"assignment_id"`
+	SubmissionID uint `gorm:"not null;uniqueIndex:idx_peer_review" json:"submission_id"`
+	ReviewerID   uint `gorm:"not null;uniqueIndex:idx_peer_review;index" json:"reviewer_id,omitempty"` // zeroed in the author's view
+
+	Status   string        `gorm:"size:20;default:'assigned'" json:"status"` // assigned | completed
+	Score    int           `json:"score"`
+	MaxScore int           `json:"max_score"`
+	Comment  string        `gorm:"type:text" json:"comment,omitempty"`
+	Scores   []RubricScore `gorm:"foreignKey:PeerReviewID;constraint:OnDelete:CASCADE" json:"scores,omitempty"`
+
+	// Reviews far from the other reviews are left out of the peer grade until the teacher dismisses the flag
+	IsOutlier        bool `gorm:"default:false" json:"is_outlier"`
+	OutlierDismissed bool `gorm:"default:false" json:"outlier_dismissed"`
+
+	SubmittedAt *time.Time `json:"submitted_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
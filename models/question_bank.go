@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Question difficulty levels used by banks and assignment rules
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// QuestionBank is a reusable pool of questions owned by a course or a department.
+// Exactly one of CourseID / DepartmentID is expected to be set.
+type QuestionBank struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title       string `gorm:"size:255;not null" json:"title"`
+	Description string `gorm:"type:text" json:"description"`
+
+	CourseID *uint   `gorm:"index" json:"course_id,omitempty"`
+	Course   *Course `gorm:"foreignKey:CourseID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"course,omitempty"`
+
+	DepartmentID *uint       `gorm:"index" json:"department_id,omitempty"`
+	Department   *Department `gorm:"foreignKey:DepartmentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"department,omitempty"`
+
+	OwnerID uint `gorm:"not null" json:"owner_id"` // User ID of the teacher who created it
+
+	Questions []Question `gorm:"foreignKey:BankID;constraint:OnDelete:CASCADE" json:"questions,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AssignmentRule draws random questions from a bank when a student starts an assignment,
+// e.g. "5 random easy questions tagged loops".
+type AssignmentRule struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	AssignmentID uint       `gorm:"not null;index" json:"assignment_id"`
+	Assignment   Assignment `gorm:"foreignKey:AssignmentID;constraint:OnDelete:CASCADE" json:"-"`
+
+	BankID uint         `gorm:"not null" json:"bank_id"`
+	Bank   QuestionBank `gorm:"foreignKey:BankID;constraint:OnDelete:CASCADE" json:"-"`
+
+	Count      int    `gorm:"not null" json:"count"`
+	Difficulty string `gorm:"size:20" json:"difficulty,omitempty"` // empty → any difficulty
+	Tag        string `gorm:"size:50" json:"tag,omitempty"`        // empty → any tag
+	OrderIndex int    `gorm:"default:0" json:"order_index"`        // rules are applied in this order
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AssignmentAttempt stores the paper drawn for one student: which questions they got
+// and in what order the questions and options are shown.
+type AssignmentAttempt struct {
+	ID           uint       `gorm:"primaryKey;autoIncrement" json:"id"`
+	AssignmentID uint       `gorm:"not null;uniqueIndex:idx_attempt_user" json:"assignment_id"`
+	Assignment   Assignment `gorm:"foreignKey:AssignmentID;constraint:OnDelete:CASCADE" json:"-"`
+	UserID       uint       `gorm:"not null;uniqueIndex:idx_attempt_user" json:"user_id"`
+	User         User       `gorm:"foreignKey:UserID;constraint:OnDelete:CASCADE" json:"-"`
+
+	Seed        int64           `json:"seed"`
+	QuestionIDs []uint          `gorm:"serializer:json;type:text" json:"question_ids"` // display order
+	OptionOrder map[uint][]uint `gorm:"serializer:json;type:text" json:"option_order"` // QuestionID → option IDs in display order
+
+	StartedAt time.Time `json:"started_at"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// Refund request statuses
+const (
+	RefundRequested  = "requested"
+	RefundProcessing = "processing" // approved, gateway call in flight
+	RefundProcessed  = "processed"
+	RefundRejected   = "rejected"
+	RefundFailed     = "failed" // gateway refused; can be approved again
+)
+
+// RefundRequest is a student's (or an admin's) request to return part or all of a payment.
+// Admins approve it, which refunds through the gateway; refunds made in the gateway dashboard
+// arrive by webhook and are recorded here already processed.
+type RefundRequest struct {
+	ID        uint     `gorm:"primaryKey;autoIncrement" json:"id"`
+	PaymentID uint     `gorm:"index;not null" json:"payment_id"`
+	Payment   *Payment `gorm:"foreignKey:PaymentID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"payment,omitempty"`
+	UserID    uint     `gorm:"index;not null" json:"user_id"` // who asked
+
+	Amount float64 `gorm:"not null" json:"amount"`
+	Reason string  `gorm:"type:text" json:"reason"`
+	Status string  `gorm:"size:20;index;default:'requested'" json:"status"`
+
+	// Keep the student enrolled (e.g. a goodwill partial refund); otherwise the enrollment is suspended
+	KeepEnrollment bool `gorm:"not null;default:false" json:"keep_enrollment"`
+
+	ReviewedBy *uint      `json:"reviewed_by,omitempty"`
+	ReviewNote string     `gorm:"type:text" json:"review_note,omitempty"`
+	ReviewedAt *time.Time `json:"reviewed_at,omitempty"`
+
+	GatewayRefundID string     `gorm:"size:100;index" json:"gateway_refund_id,omitempty"`
+	Error           string     `gorm:"type:text" json:"error,omitempty"`
+	ProcessedAt     *time.Time `json:"processed_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Reconciliation findings for one settlement report line (or a local payment the report lacks)
+const (
+	ReconMissingLocally  = "missing_locally"   // in the report, no matching payment/refund here
+	ReconMissingInReport = "missing_in_report" // paid here, absent from the report's period
+	ReconAmountMismatch  = "amount_mismatch"
+	ReconStatusMismatch  = "status_mismatch"
+)
+
+// ReconciliationRun is one import of a gateway settlement report
+type ReconciliationRun struct {
+	ID         uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	JobID      uint   `gorm:"index" json:"job_id"`
+	FileName   string `gorm:"size:255" json:"file_name"`
+	Rows       int    `json:"rows"`
+	Matched    int    `json:"matched"`
+	Mismatched int    `json:"mismatched"`
+
+	PeriodFrom *time.Time `json:"period_from,omitempty"`
+	PeriodTo   *time.Time `json:"period_to,omitempty"`
+
+	Items []ReconciliationItem `gorm:"foreignKey:RunID;constraint:OnDelete:CASCADE;" json:"items,omitempty"`
+
+	CreatedBy uint      `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ReconciliationItem is a flagged mismatch, kept until an admin resolves it
+type ReconciliationItem struct {
+	ID    uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	RunID uint `gorm:"index;not null" json:"run_id"`
+
+	Issue    string `gorm:"size:30;index" json:"issue"`
+	Type     string `gorm:"size:20" json:"type"`       // payment | refund
+	EntityID string `gorm:"size:100" json:"entity_id"` // gateway payment or refund id
+	OrderID  string `gorm:"size:100" json:"order_id,omitempty"`
+
+	PaymentID    *uint    `gorm:"index" json:"payment_id,omitempty"`
+	ReportAmount *float64 `json:"report_amount,omitempty"`
+	LocalAmount  *float64 `json:"local_amount,omitempty"`
+	LocalStatus  string   `gorm:"size:20" json:"local_status,omitempty"`
+	Detail       string   `gorm:"size:255" json:"detail"`
+
+	ResolvedBy *uint      `json:"resolved_by,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	Resolution string     `gorm:"type:text" json:"resolution,omitempty"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// RegradeLog records one submission whose score changed because an answer key was corrected
+type RegradeLog struct {
+	ID    uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	JobID uint `gorm:"index;not null" json:"job_id"`
+
+	AssignmentID uint `gorm:"index;not null" json:"assignment_id"`
+	QuestionID   uint `gorm:"index;not null" json:"question_id"`
+	SubmissionID uint `gorm:"index;not null" json:"submission_id"`
+	UserID       uint `gorm:"index;not null" json:"user_id"`
+
+	PointsBefore int `json:"points_before"` // this question's points
+	PointsAfter  int `json:"points_after"`
+	ScoreBefore  int `json:"score_before"` // whole submission
+	ScoreAfter   int `json:"score_after"`
+
+	Notified  bool      `gorm:"default:false" json:"notified"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+// Rubric is a reusable grid of criteria × performance levels
+type Rubric struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	Title       string `gorm:"size:255;not null" json:"title"`
+	Description string `gorm:"type:text" json:"description,omitempty"`
+	OwnerID     uint   `gorm:"index;not null" json:"owner_id"`
+
+	Criteria []RubricCriterion `gorm:"constraint:OnDelete:CASCADE" json:"criteria"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RubricCriterion is one row of a rubric, e.g. "Argument"
+type RubricCriterion struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	RubricID    uint   `gorm:"index;not null" json:"rubric_id"`
+	Title       string `gorm:"size:255;not null" json:"title"`
+	Description string `gorm:"type:text" json:"description,omitempty"`
+	OrderIndex  int    `gorm:"default:0" json:"order_index"`
+
+	Levels []RubricLevel `gorm:"foreignKey:CriterionID;constraint:OnDelete:CASCADE" json:"levels"`
+}
+
+// RubricLevel is one performance level of a criterion, e.g. "Excellent" = 4 points
+type RubricLevel struct {
+	ID          uint   `gorm:"primaryKey;autoIncrement" json:"id"`
+	CriterionID uint   `gorm:"index;not null" json:"criterion_id"`
+	Title       string `gorm:"size:100;not null" json:"title"`
+	Descriptor  string `gorm:"type:text" json:"descriptor,omitempty"`
+	Points      int    `gorm:"not null;default:0" json:"points"`
+	OrderIndex  int    `gorm:"default:0" json:"order_index"`
+}
+
+// RubricScore is the level picked for one criterion, in a peer review or a teacher's assessment
+type RubricScore struct {
+	ID           uint  `gorm:"primaryKey;autoIncrement" json:"id"`
+	PeerReviewID *uint `gorm:"index" json:"peer_review_id,omitempty"`
+	AssessmentID *uint `gorm:"index" json:"assessment_id,omitempty"`
+
+	CriterionID uint             `gorm:"not null" json:"criterion_id"`
+	Criterion   *RubricCriterion `gorm:"foreignKey:CriterionID" json:"criterion,omitempty"`
+	LevelID     uint             `gorm:"not null" json:"level_id"`
+	Level       *RubricLevel     `gorm:"foreignKey:LevelID" json:"level,omitempty"`
+	Points      int              `json:"points"`
+	Comment     string           `gorm:"type:text" json:"comment,omitempty"`
+}
+
+// RubricAssessment is a teacher grading a submission with a rubric.
+// AnswerID set → grades that answer (question rubric); nil → an extra graded item for the
+// whole submission (assignment rubric) whose points are added to the submission's score.
+type RubricAssessment struct {
+	ID           uint  `gorm:"primaryKey;autoIncrement" json:"id"`
+	SubmissionID uint  `gorm:"index;not null" json:"submission_id"`
+	AnswerID     *uint `gorm:"index" json:"answer_id,omitempty"`
+	RubricID     uint  `gorm:"not null" json:"rubric_id"`
+
+	Score    int           `json:"score"`
+	MaxScore int           `json:"max_score"`
+	Comment  string        `gorm:"type:text" json:"comment,omitempty"`
+	Scores   []RubricScore `gorm:"foreignKey:AssessmentID;constraint:OnDelete:CASCADE" json:"scores"`
+
+	GradedBy  uint      `json:"graded_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
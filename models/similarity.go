@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// Similarity match review statuses
+const (
+	SimilarityOpen      = "open"
+	SimilarityDismissed = "dismissed" // teacher decided it is not copying
+	SimilarityConfirmed = "confirmed"
+)
+
+// Fingerprint is one winnowed shingle hash and its byte range in the answer text
+type Fingerprint struct {
+	Hash  uint64 `json:"h"`
+	Start int    `json:"s"`
+	End   int    `json:"e"`
+}
+
+// MatchSpan is a copied byte range in the answer and where it appears in the other answer
+type MatchSpan struct {
+	Start      int `json:"start"`
+	End        int `json:"end"`
+	OtherStart int `json:"other_start"`
+	OtherEnd   int `json:"other_end"`
+}
+
+// AnswerFingerprint stores the winnowing fingerprints of one free-text answer
+type AnswerFingerprint struct {
+	ID           uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	AnswerID     uint `gorm:"uniqueIndex;not null" json:"answer_id"`
+	SubmissionID uint `gorm:"index;not null" json:"submission_id"`
+	AssignmentID uint `gorm:"index;not null" json:"assignment_id"`
+	QuestionID   uint `gorm:"index;not null" json:"question_id"`
+	UserID       uint `gorm:"index;not null" json:"user_id"`
+
+	Prints []Fingerprint `gorm:"serializer:json;type:mediumtext" json:"-"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SimilarityMatch links a newer answer to an earlier one it overlaps with.
+// Similarity is the share of the answer found in the other one, OtherSimilarity the reverse.
+type SimilarityMatch struct {
+	ID           uint `gorm:"primaryKey;autoIncrement" json:"id"`
+	AssignmentID uint `gorm:"index;not null" json:"assignment_id"`
+	QuestionID   uint `gorm:"index;not null" json:"question_id"`
+
+	SubmissionID uint `gorm:"index;not null" json:"submission_id"`
+	AnswerID     uint `gorm:"not null" json:"answer_id"`
+	UserID       uint `gorm:"not null" json:"user_id"`
+
+	OtherAssignmentID uint `gorm:"not null" json:"other_assignment_id"` // differs for questions reused from earlier terms
+	OtherSubmissionID uint `gorm:"index;not null" json:"other_submission_id"`
+	OtherAnswerID     uint `gorm:"not null" json:"other_answer_id"`
+	OtherUserID       uint `gorm:"not null" json:"other_user_id"`
+
+	Similarity      float64     `json:"similarity"` // percent
+	OtherSimilarity float64     `json:"other_similarity"`
+	Spans           []MatchSpan `gorm:"serializer:json;type:text" json:"spans"`
+	Flagged         bool        `gorm:"index;default:false" json:"flagged"`
+
+	Status     string `gorm:"size:20;default:'open'" json:"status"` // open | dismissed | confirmed
+	ReviewNote string `gorm:"type:text" json:"review_note,omitempty"`
+	ReviewedBy *uint  `json:"reviewed_by,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -1,8 +1,11 @@
 package routes
 
 import (
+    "time"
+
     "github.com/ayushwar/major/controllers"
     "github.com/ayushwar/major/middlewares"
+    "github.com/ayushwar/major/utils"
 
     "github.com/gin-gonic/gin"
 )
@@ -22,15 +25,31 @@ func RegisterRoutes(router *gin.Engine) {
 
     // Other resource routes
     CourseRoutes(router)
+    GradebookRoutes(router)
     // LectureRoutes(router)
     AssignmentRoutes(router)
     QuestionRoutes(router)
+    QuestionBankRoutes(router)
+    RubricRoutes(router)
     OptionRoutes(router)
     RegisterEnrollmentRoutes(router)
     SubmissionRoutes(router)
+    PeerReviewRoutes(router)
+    SimilarityRoutes(router)
     ProgressRoutes(router)
+    CompletionRoutes(router)
     CertificateRoutes(router)
+    CertificateTemplateRoutes(router)
+    VerifyRoutes(router)
     DepartmentRoutes(router)
+    JobRoutes(router)
+    PaymentRoutes(router)
+    CouponRoutes(router)
+    RefundRoutes(router)
+    InvoiceRoutes(router)
+    LedgerRoutes(router)
+    PayoutRoutes(router)
+    CollegeVerificationRoutes(router)
 }
 
 
@@ -74,12 +93,46 @@ func CourseRoutes(router *gin.Engine) {
 //     }
 // }
 
+func GradebookRoutes(router *gin.Engine) {
+    gradebook := router.Group("/courses/:id")
+    gradebook.Use(middlewares.AuthMiddleware())
+    {
+        // Student: own grades in an enrolled course
+        gradebook.GET("/gradebook/me", controllers.GetMyGrades)
+        gradebook.GET("/grade-scale", controllers.GetGradeScale)
+
+        // Teacher (course owner) / admin
+        staff := gradebook.Group("", middlewares.RoleMiddleware("teacher", "admin"))
+        staff.GET("/gradebook", controllers.GetGradebook)
+        staff.GET("/gradebook/export", controllers.ExportGradebook)
+        staff.GET("/gradebook/overrides", controllers.GetGradeOverrides)
+        staff.POST("/gradebook/overrides", controllers.SetGradeOverride)
+        staff.DELETE("/gradebook/overrides/:override_id", controllers.DeleteGradeOverride)
+        staff.PUT("/grade-scale", controllers.SetGradeScale)
+
+        staff.GET("/grade-categories", controllers.GetGradeCategories)
+        staff.POST("/grade-categories", controllers.CreateGradeCategory)
+        staff.PUT("/grade-categories/:category_id", controllers.UpdateGradeCategory)
+        staff.DELETE("/grade-categories/:category_id", controllers.DeleteGradeCategory)
+    }
+}
+
 func AssignmentRoutes(router *gin.Engine) {
     assignments := router.Group("/assignments")
     {
-        // Public: view assignments
-        assignments.GET("/", controllers.GetAllAssignments)
-        assignments.GET("/:id", controllers.GetAssignmentByID)
+        // Public: view assignments (answer key only for teacher/admin tokens)
+        assignments.GET("/", middlewares.OptionalAuthMiddleware(), controllers.GetAllAssignments)
+        assignments.GET("/:id", middlewares.OptionalAuthMiddleware(), controllers.GetAssignmentByID)
+
+        // Authenticated: review own result once the review policy allows it
+        assignments.GET("/:id/review", middlewares.AuthMiddleware(), controllers.GetAssignmentReview)
+
+        // Student: draw / re-open own randomized paper
+        assignments.POST("/:id/attempt",
+            middlewares.AuthMiddleware(),
+            middlewares.RoleMiddleware("student"),
+            controllers.StartAttempt,
+        )
 
         // Protected: teacher/admin only for modification
         assignments.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware("teacher", "admin"))
@@ -87,6 +140,30 @@ func AssignmentRoutes(router *gin.Engine) {
             assignments.POST("/", controllers.CreateAssignment)
             assignments.PUT("/:id", controllers.UpdateAssignment)
             assignments.DELETE("/:id", controllers.DeleteAssignment)
+
+            // Random draw rules ("5 easy questions tagged loops")
+            assignments.GET("/:id/rules", controllers.GetAssignmentRules)
+            assignments.POST("/:id/rules", controllers.CreateAssignmentRule)
+            assignments.DELETE("/:id/rules/:rule_id", controllers.DeleteAssignmentRule)
+
+            // Before/after scores of regrades caused by answer-key corrections
+            assignments.GET("/:id/regrade-log", controllers.GetRegradeLog)
+
+            // Item analysis: score distribution, difficulty/discrimination, distractors, reliability
+            assignments.GET("/:id/analytics", controllers.GetAssignmentAnalytics)
+
+            // Peer review: settings, allocation after the deadline, moderation
+            assignments.GET("/:id/peer-review", controllers.GetPeerReviewSettings)
+            assignments.PUT("/:id/peer-review", controllers.SetPeerReviewSettings)
+            assignments.POST("/:id/peer-review/allocate", controllers.AllocatePeerReviews)
+            assignments.GET("/:id/peer-reviews", controllers.GetAssignmentPeerReviews)
+
+            // Rubric used to grade whole submissions
+            assignments.PUT("/:id/rubric", controllers.SetAssignmentRubric)
+
+            // Manual grading queue (text answers, similarity flags) and similarity backfill
+            assignments.GET("/:id/grading-queue", controllers.GetGradingQueue)
+            assignments.POST("/:id/similarity/scan", controllers.ScanAssignmentSimilarity)
         }
     }
 }
@@ -94,13 +171,25 @@ func QuestionRoutes(router *gin.Engine) {
     // Assignment related questions — use :id consistently
     questions := router.Group("/assignments/:id/questions")
     {
-        questions.GET("/", controllers.GetQuestionsByAssignment)
+        questions.GET("/", middlewares.OptionalAuthMiddleware(), controllers.GetQuestionsByAssignment)
 
         questions.POST("/",
             middlewares.AuthMiddleware(),
             middlewares.RoleMiddleware("teacher", "admin"),
             controllers.CreateQuestion,
         )
+
+        // Bulk import/export (CSV, GIFT, QTI 2.1)
+        questions.POST("/import",
+            middlewares.AuthMiddleware(),
+            middlewares.RoleMiddleware("teacher", "admin"),
+            controllers.ImportAssignmentQuestions,
+        )
+        questions.GET("/export",
+            middlewares.AuthMiddleware(),
+            middlewares.RoleMiddleware("teacher", "admin"),
+            controllers.ExportAssignmentQuestions,
+        )
     }
 
     // Individual question update/delete with :question_id param unchanged
@@ -109,16 +198,52 @@ func QuestionRoutes(router *gin.Engine) {
     {
         q.PUT("/:question_id", controllers.UpdateQuestion)
         q.DELETE("/:question_id", controllers.DeleteQuestion)
+
+        // Regrade existing submissions against the current answer key
+        q.GET("/:question_id/regrade/preview", controllers.PreviewQuestionRegrade)
+        q.POST("/:question_id/regrade", controllers.RegradeQuestion)
+
+        // Rubric used to grade answers to this question by hand
+        q.PUT("/:question_id/rubric", controllers.SetQuestionRubric)
     }
 }
 
+func QuestionBankRoutes(router *gin.Engine) {
+    banks := router.Group("/question-banks")
+    banks.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware("teacher", "admin"))
+    {
+        banks.POST("/", controllers.CreateQuestionBank)
+        banks.GET("/", controllers.GetQuestionBanks)
+        banks.GET("/:id", controllers.GetQuestionBankByID)
+        banks.PUT("/:id", controllers.UpdateQuestionBank)
+        banks.DELETE("/:id", controllers.DeleteQuestionBank)
+
+        // Bank questions are edited/deleted via /questions/:question_id like any other question
+        banks.POST("/:id/questions", controllers.AddBankQuestion)
+        banks.GET("/:id/questions", controllers.GetBankQuestions)
+        banks.POST("/:id/import", controllers.ImportBankQuestions)
+        banks.GET("/:id/export", controllers.ExportBankQuestions)
+    }
+}
+
+func RubricRoutes(router *gin.Engine) {
+    rubrics := router.Group("/rubrics")
+    rubrics.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware("teacher", "admin"))
+    {
+        rubrics.POST("/", controllers.CreateRubric)
+        rubrics.GET("/", controllers.GetRubrics)
+        rubrics.GET("/:id", controllers.GetRubricByID)
+        rubrics.PUT("/:id", controllers.UpdateRubric)
+        rubrics.DELETE("/:id", controllers.DeleteRubric)
+    }
+}
 
 func OptionRoutes(router *gin.Engine) {
     // Question-related options, param :question_id with unique option id param :option_id
     options := router.Group("/questions/:question_id/options")
     {
         // Public: list options of question
-        options.GET("/", controllers.GetOptions)
+        options.GET("/", middlewares.OptionalAuthMiddleware(), controllers.GetOptions)
 
         // Protected: teacher/admin modify
         options.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware("teacher", "admin"))
@@ -144,6 +269,9 @@ func RegisterEnrollmentRoutes(r *gin.Engine) {
     // Teacher: get enrollments for course
     r.GET("/courses/:id/enrollments", middlewares.AuthMiddleware(), controllers.GetEnrollmentsByCourse)
 
+    // Student/teacher: which completion rules are met
+    enrollments.GET("/:id/completion", controllers.GetEnrollmentCompletion)
+
     // Admin: update/delete enrollments
     enrollments.PUT("/:id", middlewares.RoleMiddleware("admin"), controllers.UpdateEnrollment)
     enrollments.DELETE("/:id", middlewares.RoleMiddleware("admin"), controllers.DeleteEnrollment)
@@ -161,9 +289,56 @@ func SubmissionRoutes(router *gin.Engine) {
 
         // Teachers/Admin: fetch all submissions for assignment
         submissions.GET("/assignment/:id", controllers.GetSubmissionsByAssignment)
+
+        // Owner (once review opens) / course teacher / admin: per-answer breakdown
+        submissions.GET("/:id", controllers.GetSubmissionByID)
+
+        // Teachers/Admin: override one answer's points with a reason
+        submissions.PUT("/:id/answers/:answer_id",
+            middlewares.RoleMiddleware("teacher", "admin"),
+            controllers.OverrideAnswerScore,
+        )
+
+        // Teachers/Admin: grade an answer or the whole submission with its rubric
+        submissions.POST("/:id/rubric-grade",
+            middlewares.RoleMiddleware("teacher", "admin"),
+            controllers.GradeWithRubric,
+        )
+
+        // Teachers/Admin: similarity report with matched passages
+        submissions.GET("/:id/similarity",
+            middlewares.RoleMiddleware("teacher", "admin"),
+            controllers.GetSubmissionSimilarity,
+        )
+
+        // Author (anonymised) / course teacher: peer reviews received
+        submissions.GET("/:id/peer-reviews", controllers.GetSubmissionPeerReviews)
+    }
+}
+
+func PeerReviewRoutes(router *gin.Engine) {
+    reviews := router.Group("/peer-reviews")
+    reviews.Use(middlewares.AuthMiddleware())
+    {
+        // Students: reviews to write, anonymised submission + rubric, submit scores
+        reviews.GET("/assigned", controllers.GetMyPeerReviews)
+        reviews.GET("/:id", controllers.GetPeerReview)
+        reviews.POST("/:id/submit", middlewares.RoleMiddleware("student"), controllers.SubmitPeerReview)
+
+        // Teacher: keep or drop a review flagged as an outlier
+        reviews.PUT("/:id/outlier", middlewares.RoleMiddleware("teacher", "admin"), controllers.DismissPeerReviewOutlier)
     }
 }
 
+func SimilarityRoutes(router *gin.Engine) {
+    // Teacher: mark a similarity match as dismissed / confirmed
+    router.PUT("/similarity-matches/:id",
+        middlewares.AuthMiddleware(),
+        middlewares.RoleMiddleware("teacher", "admin"),
+        controllers.ReviewSimilarityMatch,
+    )
+}
+
 func ProgressRoutes(router *gin.Engine) {
     progress := router.Group("/progress")
     progress.Use(middlewares.AuthMiddleware())
@@ -176,11 +351,31 @@ func ProgressRoutes(router *gin.Engine) {
     }
 }
 
+func CompletionRoutes(router *gin.Engine) {
+    completion := router.Group("/courses/:id")
+    completion.Use(middlewares.AuthMiddleware())
+    {
+        completion.GET("/completion-rule", controllers.GetCompletionRule)
+
+        // Teacher/admin: rules, and re-running them after grades change
+        completion.PUT("/completion-rule", controllers.SetCompletionRule)
+        completion.POST("/completion/evaluate", controllers.EvaluateCourseCompletion)
+
+        // Admin: issue certificates to the whole cohort (?dry_run=true lists who qualifies)
+        completion.POST("/certificates/bulk", middlewares.RoleMiddleware("admin"), controllers.BulkIssueCertificates)
+    }
+}
+
 func CertificateRoutes(router *gin.Engine) {
+    // Public: key for checking certificate signatures offline
+    router.GET("/certificates/public-key", controllers.GetCertificatePublicKey)
+    // Public: Open Badges issuer profile that exported credentials reference
+    router.GET("/certificates/issuer", controllers.GetCertificateIssuer)
+
     certs := router.Group("/certificates")
     certs.Use(middlewares.AuthMiddleware())
     {
-        // Students: request certificate after completion
+        // Students: request own certificate after completion (course teacher/admin: any student)
         certs.POST("/issue", controllers.IssueCertificate)
 
         // Students: get own certificates
@@ -188,8 +383,50 @@ func CertificateRoutes(router *gin.Engine) {
 
         // Admin/teacher: verify certificate by ID
         certs.GET("/:id", controllers.GetCertificateByID)
+
+        // Holder/teacher/admin: Open Badges 3.0 credential and baked badge image
+        certs.GET("/:id/credential", controllers.GetCertificateCredential)
+        certs.GET("/:id/badge.png", controllers.GetCertificateBadge)
+
+        // Admin: withdraw or correct a certificate; both are kept in its history
+        certs.POST("/:id/revoke", middlewares.RoleMiddleware("admin"), controllers.RevokeCertificate)
+        certs.POST("/:id/reissue", middlewares.RoleMiddleware("admin"), controllers.ReissueCertificate)
+        certs.GET("/:id/history", middlewares.RoleMiddleware("admin"), controllers.GetCertificateHistory)
+        // Admin: sign and re-code certificates issued before signing (background job)
+        certs.POST("/backfill-signatures", middlewares.RoleMiddleware("admin"), controllers.SignLegacyCertificates)
+
+        // Holder/teacher/admin: PDF rendered from the certificate template
+        certs.GET("/download/:id", controllers.DownloadCertificate)
+    }
+}
+
+func CertificateTemplateRoutes(router *gin.Engine) {
+    templates := router.Group("/certificate-templates")
+    templates.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware("admin"))
+    {
+        templates.POST("/", controllers.CreateCertificateTemplate)
+        templates.GET("/", controllers.GetCertificateTemplates)
+        templates.GET("/:id", controllers.GetCertificateTemplateByID)
+        templates.PUT("/:id", controllers.UpdateCertificateTemplate)
+        templates.DELETE("/:id", controllers.DeleteCertificateTemplate)
+
+        // Background, logo and signature images; TrueType fonts
+        templates.POST("/:id/assets", controllers.UploadCertificateTemplateAsset)
+        templates.DELETE("/:id/assets/:asset_id", controllers.DeleteCertificateTemplateAsset)
+
+        // PDF with sample data, or ?certificate_id= for a real one
+        templates.GET("/:id/preview", controllers.PreviewCertificateTemplate)
     }
 }
+func VerifyRoutes(router *gin.Engine) {
+    // Public: anyone (employers, QR scans) can check a certificate code.
+    // Rate limited per IP so codes cannot be enumerated.
+    router.GET("/verify/:code",
+        middlewares.RateLimitMiddleware(30, time.Minute),
+        controllers.VerifyCertificate,
+    )
+}
+
 func DepartmentRoutes(router *gin.Engine) {
 	departments := router.Group("/departments")
 	{
@@ -220,4 +457,135 @@ func DepartmentRoutes(router *gin.Engine) {
 			controllers.DeleteDepartment,
 		)
 	}
-}
\ No newline at end of file
+}
+
+func JobRoutes(router *gin.Engine) {
+    // Background jobs (regrades, bulk operations): poll status/progress
+    router.GET("/jobs/:id", middlewares.AuthMiddleware(), controllers.GetJob)
+    router.GET("/jobs/:id/artifact", middlewares.AuthMiddleware(), controllers.GetJobArtifact)
+}
+
+func PaymentRoutes(router *gin.Engine) {
+    // Public: gateway notifications, authenticated by their HMAC signature
+    router.POST("/payments/webhook", controllers.PaymentWebhook)
+
+    payments := router.Group("/payments")
+    payments.Use(middlewares.AuthMiddleware())
+    {
+        // Students: price a course (with an optional coupon), start checkout and confirm it
+        // from the checkout callback
+        payments.POST("/quote", controllers.QuotePayment)
+        payments.POST("/", controllers.CreatePayment)
+        payments.POST("/verify", controllers.VerifyPayment)
+        payments.GET("/me", controllers.GetMyPayments)
+
+        // Admin: match payments against a gateway settlement report
+        admin := payments.Group("/reconciliation", middlewares.RoleMiddleware("admin"))
+        admin.POST("", controllers.ImportSettlementReport)
+        admin.GET("", controllers.GetReconciliationRuns)
+        admin.GET("/:run_id", controllers.GetReconciliationRun)
+        admin.POST("/items/:item_id/resolve", controllers.ResolveReconciliationItem)
+
+        // Owner/admin
+        payments.GET("/:id", controllers.GetPayment)
+        payments.POST("/:id/refunds", controllers.RequestRefund)
+        // Development only: settle a payment through the fake gateway
+        if utils.PaymentDevMode() {
+            payments.POST("/:id/simulate", controllers.SimulatePayment)
+        }
+    }
+}
+
+func CouponRoutes(router *gin.Engine) {
+    coupons := router.Group("/coupons")
+    coupons.Use(middlewares.AuthMiddleware(), middlewares.RoleMiddleware("admin"))
+    {
+        coupons.GET("/", controllers.GetCoupons)
+        coupons.POST("/", controllers.CreateCoupon)
+        coupons.GET("/:id", controllers.GetCouponByID)
+        coupons.PUT("/:id", controllers.UpdateCoupon)
+        coupons.DELETE("/:id", controllers.DeleteCoupon)
+    }
+}
+
+func RefundRoutes(router *gin.Engine) {
+    refunds := router.Group("/refunds")
+    refunds.Use(middlewares.AuthMiddleware())
+    {
+        // Students see their own requests, admins all of them
+        refunds.GET("/", controllers.GetRefundRequests)
+
+        // Admin: approve (refunds through the gateway, suspends the enrollment) or reject
+        refunds.POST("/:id/approve", middlewares.RoleMiddleware("admin"), controllers.ApproveRefund)
+        refunds.POST("/:id/reject", middlewares.RoleMiddleware("admin"), controllers.RejectRefund)
+    }
+}
+
+func InvoiceRoutes(router *gin.Engine) {
+    me := router.Group("/users/me")
+    me.Use(middlewares.AuthMiddleware())
+    {
+        // Invoices are issued when a paid checkout settles
+        me.GET("/invoices", controllers.GetMyInvoices)
+        me.GET("/invoices/:id/pdf", controllers.DownloadMyInvoice)
+
+        // Name, address and GSTIN printed on future invoices
+        me.GET("/billing", controllers.GetBillingDetails)
+        me.PUT("/billing", controllers.UpdateBillingDetails)
+    }
+}
+
+func LedgerRoutes(router *gin.Engine) {
+    ledger := router.Group("/ledger")
+    ledger.Use(middlewares.AuthMiddleware())
+    {
+        // Revenue by course, department, teacher or period; teachers see their own courses
+        ledger.GET("/revenue", middlewares.RoleMiddleware("admin", "teacher"), controllers.GetRevenueReport)
+
+        // Admin: journal, trial balance, corrections and posting of pre-ledger payments
+        ledger.GET("/entries", middlewares.RoleMiddleware("admin"), controllers.GetLedgerEntries)
+        ledger.POST("/entries/:id/reverse", middlewares.RoleMiddleware("admin"), controllers.ReverseLedgerEntry)
+        ledger.GET("/balances", middlewares.RoleMiddleware("admin"), controllers.GetLedgerBalances)
+        ledger.POST("/backfill", middlewares.RoleMiddleware("admin"), controllers.BackfillLedger)
+    }
+}
+
+func PayoutRoutes(router *gin.Engine) {
+    payouts := router.Group("/payouts")
+    payouts.Use(middlewares.AuthMiddleware())
+    {
+        // Admin: revenue share rules per teacher, department or platform default
+        payouts.GET("/rules", middlewares.RoleMiddleware("admin"), controllers.GetRevenueShareRules)
+        payouts.POST("/rules", middlewares.RoleMiddleware("admin"), controllers.CreateRevenueShareRule)
+        payouts.PUT("/rules/:id", middlewares.RoleMiddleware("admin"), controllers.UpdateRevenueShareRule)
+        payouts.DELETE("/rules/:id", middlewares.RoleMiddleware("admin"), controllers.DeleteRevenueShareRule)
+
+        // Monthly statements; teachers see and export their own
+        payouts.GET("/statements", middlewares.RoleMiddleware("admin", "teacher"), controllers.GetPayoutStatements)
+        payouts.GET("/statements/:id", middlewares.RoleMiddleware("admin", "teacher"), controllers.GetPayoutStatement)
+        payouts.GET("/statements/:id/export", middlewares.RoleMiddleware("admin", "teacher"), controllers.ExportPayoutStatement)
+
+        // Admin: generate, approve or reject, record the transfer
+        payouts.POST("/statements/generate", middlewares.RoleMiddleware("admin"), controllers.GeneratePayoutStatements)
+        payouts.POST("/statements/:id/approve", middlewares.RoleMiddleware("admin"), controllers.ApprovePayoutStatement)
+        payouts.POST("/statements/:id/reject", middlewares.RoleMiddleware("admin"), controllers.RejectPayoutStatement)
+        payouts.POST("/statements/:id/paid", middlewares.RoleMiddleware("admin"), controllers.MarkPayoutStatementPaid)
+    }
+}
+
+func CollegeVerificationRoutes(router *gin.Engine) {
+    verification := router.Group("/verification")
+    verification.Use(middlewares.AuthMiddleware())
+    {
+        // Students upload an ID card or enrollment letter and follow their requests
+        verification.POST("/", middlewares.RoleMiddleware("student"), controllers.SubmitCollegeVerification)
+        verification.GET("/me", controllers.GetMyCollegeVerification)
+        verification.GET("/requests/:id/document", controllers.GetCollegeVerificationDocument)
+
+        // Admin review queue
+        verification.GET("/requests", middlewares.RoleMiddleware("admin"), controllers.GetCollegeVerifications)
+        verification.GET("/requests/:id", middlewares.RoleMiddleware("admin"), controllers.GetCollegeVerification)
+        verification.POST("/requests/:id/approve", middlewares.RoleMiddleware("admin"), controllers.ApproveCollegeVerification)
+        verification.POST("/requests/:id/reject", middlewares.RoleMiddleware("admin"), controllers.RejectCollegeVerification)
+    }
+}
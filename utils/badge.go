@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+)
+
+const badgeSize = 400
+
+var (
+	badgeRing  = color.RGBA{R: 0xc9, G: 0x9a, B: 0x2e, A: 0xff} // gold
+	badgeInner = color.RGBA{R: 0x1f, G: 0x3a, B: 0x5f, A: 0xff} // navy
+)
+
+// RenderBadge draws a round badge image with a QR code of verifyURL in the middle
+func RenderBadge(verifyURL string) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, badgeSize, badgeSize))
+
+	centre := badgeSize / 2
+	fillCircle(img, centre, centre, centre, badgeRing)
+	fillCircle(img, centre, centre, centre-18, badgeInner)
+	fillCircle(img, centre, centre, centre-30, color.White)
+
+	code, err := qr.Encode(verifyURL, qr.M, qr.Auto)
+	if err != nil {
+		return nil, err
+	}
+	code, err = barcode.Scale(code, 220, 220)
+	if err != nil {
+		return nil, err
+	}
+	offset := image.Pt(centre-110, centre-110)
+	draw.Draw(img, code.Bounds().Add(offset), code, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func fillCircle(img *image.RGBA, cx, cy, r int, c color.Color) {
+	for y := cy - r; y <= cy+r; y++ {
+		for x := cx - r; x <= cx+r; x++ {
+			dx, dy := x-cx, y-cy
+			if dx*dx+dy*dy <= r*r {
+				img.Set(x, y, c)
+			}
+		}
+	}
+}
+
+// BakePNG embeds an Open Badges credential in a PNG as an uncompressed iTXt chunk
+// with keyword "openbadges", placed just before IEND (Open Badges 3.0 baking).
+func BakePNG(pngData []byte, credential []byte) ([]byte, error) {
+	const iendLen = 12 // length + type + crc, no data
+	if len(pngData) < 8+iendLen || string(pngData[len(pngData)-8:len(pngData)-4]) != "IEND" {
+		return nil, errors.New("not a PNG ending in IEND")
+	}
+
+	// keyword, NUL, compression flag, compression method, language tag NUL, translated keyword NUL, text
+	var data bytes.Buffer
+	data.WriteString("openbadges")
+	data.Write([]byte{0, 0, 0, 0, 0})
+	data.Write(credential)
+
+	var chunk bytes.Buffer
+	binary.Write(&chunk, binary.BigEndian, uint32(data.Len()))
+	chunk.WriteString("iTXt")
+	chunk.Write(data.Bytes())
+	crc := crc32.NewIEEE()
+	crc.Write([]byte("iTXt"))
+	crc.Write(data.Bytes())
+	binary.Write(&chunk, binary.BigEndian, crc.Sum32())
+
+	iend := len(pngData) - iendLen
+	baked := make([]byte, 0, len(pngData)+chunk.Len())
+	baked = append(baked, pngData[:iend]...)
+	baked = append(baked, chunk.Bytes()...)
+	baked = append(baked, pngData[iend:]...)
+	return baked, nil
+}
@@ -1,13 +1,70 @@
 package utils
 
 import (
-	"fmt"
-	"math/rand"
+	"bytes"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"os"
+	"strings"
 	"time"
 )
 
-// GenerateCertificateCode → unique cert ID like CERT-20250912-XYZ123
+// defaultPublicBaseURL is used when PUBLIC_BASE_URL is not set (local development)
+const defaultPublicBaseURL = "http://localhost:8080"
+
+// codeEncoding is base32 without padding; 10 random bytes → 16 characters (80 bits)
+var codeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateCertificateCode → unguessable cert code like CERT-2026-K7Q2MZ4XW3PA9RTB.
+// Codes are drawn from crypto/rand; callers still check the unique index before use.
 func GenerateCertificateCode() string {
-	rand.Seed(time.Now().UnixNano())
-	return fmt.Sprintf("CERT-%d-%06d", time.Now().Year(), rand.Intn(1000000))
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		panic("crypto/rand unavailable: " + err.Error())
+	}
+	return "CERT-" + time.Now().Format("2006") + "-" + codeEncoding.EncodeToString(b)
+}
+
+// CertificatePayload is exactly what a certificate's signature covers.
+// Field order is fixed, so encoding it gives the same bytes every time.
+type CertificatePayload struct {
+	Holder   string `json:"holder"`
+	Course   string `json:"course"`
+	IssuedAt string `json:"issued_at"` // RFC 3339, UTC, whole seconds
+	Code     string `json:"code"`
+}
+
+// NewCertificatePayload builds the canonical payload for a certificate
+func NewCertificatePayload(holder, course string, issuedAt time.Time, code string) CertificatePayload {
+	return CertificatePayload{
+		Holder:   holder,
+		Course:   course,
+		IssuedAt: issuedAt.UTC().Truncate(time.Second).Format(time.RFC3339),
+		Code:     code,
+	}
+}
+
+// Bytes → canonical JSON encoding that is signed: compact, no trailing newline and no HTML
+// escaping, so "R&D" is signed as written rather than as "R\u0026D" (as in CanonicalJSON)
+func (p CertificatePayload) Bytes() []byte {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(p) // only strings; cannot fail
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// PublicBaseURL → the address the platform is reachable at from outside (PUBLIC_BASE_URL env)
+func PublicBaseURL() string {
+	base := strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+	if base == "" {
+		return defaultPublicBaseURL
+	}
+	return base
+}
+
+// CertificateVerifyURL → public page where anyone can check a certificate code (used in the QR)
+func CertificateVerifyURL(code string) string {
+	return PublicBaseURL() + "/verify/" + code
 }
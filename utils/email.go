@@ -1,10 +1,14 @@
 package utils
 
 import (
+	"bytes"
+	"encoding/base64"
 	"fmt"
+	"mime"
 	"net/smtp"
 	"os"
 	"regexp"
+	"time"
 )
 
 // SendEmail sends an email using Gmail SMTP server
@@ -30,3 +34,35 @@ func ValidateEmail(email string) bool {
 	matched := regexp.MustCompile(re).MatchString(email)
 	return matched
 }
+
+// SendEmailWithAttachment sends a plain-text email with one file attached (MIME multipart)
+func SendEmailWithAttachment(toEmail, subject, body, filename, contentType string, attachment []byte) error {
+	from := os.Getenv("EMAIL_FROM")
+	password := os.Getenv("EMAIL_PASSWORD")
+	smtpHost := "smtp.gmail.com"
+	smtpPort := "587"
+
+	boundary := fmt.Sprintf("boundary-%x", time.Now().UnixNano())
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", from, toEmail, mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "MIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=%q\r\n\r\n", boundary)
+
+	fmt.Fprintf(&msg, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n", boundary, body)
+
+	fmt.Fprintf(&msg, "--%s\r\nContent-Type: %s\r\nContent-Transfer-Encoding: base64\r\n", boundary, contentType)
+	fmt.Fprintf(&msg, "Content-Disposition: attachment; filename=%q\r\n\r\n", filename)
+	encoded := base64.StdEncoding.EncodeToString(attachment)
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+	fmt.Fprintf(&msg, "--%s--\r\n", boundary)
+
+	auth := smtp.PlainAuth("", from, password, smtpHost)
+	if err := smtp.SendMail(smtpHost+":"+smtpPort, auth, from, []string{toEmail}, msg.Bytes()); err != nil {
+		return err
+	}
+	fmt.Println("✅ Email sent to:", toEmail)
+	return nil
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// FakeGatewayFeePercent is what simulated captures report as the gateway's fee
+const FakeGatewayFeePercent = 2
+
+// FakeGateway is an in-process gateway for development: orders and refunds always succeed
+// and webhooks use Razorpay's format and signatures, so the same code paths run end to end.
+type FakeGateway struct {
+	WebhookSecret string
+}
+
+// NewFakeGateway → a fake gateway signing with secret. There is no default: a well-known secret
+// would let anyone forge the webhooks and checkout signatures that settle payments.
+func NewFakeGateway(secret string) (*FakeGateway, error) {
+	if secret == "" {
+		return nil, errors.New("FAKE_GATEWAY_WEBHOOK_SECRET is required")
+	}
+	return &FakeGateway{WebhookSecret: secret}, nil
+}
+
+func (g *FakeGateway) Name() string { return "fake" }
+
+func (g *FakeGateway) CreateOrder(req OrderRequest) (Order, error) {
+	return Order{OrderID: RandomReference("order_fake_"), Amount: req.Amount, Currency: req.Currency, Status: "created", Gateway: g.Name()}, nil
+}
+
+func (g *FakeGateway) VerifyPaymentSignature(orderID, paymentID, signature string) bool {
+	return validHMAC(g.WebhookSecret, []byte(orderID+"|"+paymentID), signature)
+}
+
+func (g *FakeGateway) VerifyWebhookSignature(body []byte, signature string) bool {
+	return validHMAC(g.WebhookSecret, body, signature)
+}
+
+func (g *FakeGateway) ParseWebhook(body []byte) (WebhookEvent, error) {
+	return parseRazorpayWebhook(body)
+}
+
+func (g *FakeGateway) Refund(paymentID string, amount int64, notes map[string]string) (Refund, error) {
+	return Refund{RefundID: RandomReference("rfnd_fake_"), PaymentID: paymentID, Amount: amount, Status: "processed"}, nil
+}
+
+// Simulate builds a signed webhook for an order as if the customer had paid (or failed to),
+// returning the body and its signature header
+func (g *FakeGateway) Simulate(orderID string, amount int64, captured bool, reason string) ([]byte, string, error) {
+	event, status := "payment.captured", "captured"
+	if !captured {
+		event, status = "payment.failed", "failed"
+	}
+	entity := map[string]interface{}{
+		"id": RandomReference("pay_fake_"), "order_id": orderID, "amount": amount, "status": status, "method": "fake",
+	}
+	if captured {
+		entity["fee"] = amount * FakeGatewayFeePercent / 100
+	} else {
+		entity["error_description"] = reason
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"event":   event,
+		"payload": map[string]interface{}{"payment": map[string]interface{}{"entity": entity}},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return body, HMACSHA256Hex(g.WebhookSecret, body), nil
+}
@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// SACEducation is the GST services code for commercial training and coaching
+const SACEducation = "999293"
+
+// gstinPattern is the 15-character GSTIN; the first two digits are the state code
+var gstinPattern = regexp.MustCompile(`^[0-9]{2}[A-Z]{5}[0-9]{4}[A-Z][1-9A-Z]Z[0-9A-Z]$`)
+
+// ValidGSTIN checks the format of a GST identification number
+func ValidGSTIN(gstin string) bool {
+	return gstinPattern.MatchString(gstin)
+}
+
+// Seller is the platform as it appears on invoices
+type Seller struct {
+	LegalName string
+	Address   string
+	GSTIN     string // empty: not GST registered, documents are plain receipts
+	StateCode string // two-digit GST state code
+	Prefix    string // invoice number prefix
+}
+
+// InvoiceSeller → seller details from SELLER_LEGAL_NAME, SELLER_ADDRESS, SELLER_GSTIN,
+// SELLER_STATE_CODE (defaults to the GSTIN's) and INVOICE_PREFIX (default INV)
+func InvoiceSeller() Seller {
+	s := Seller{
+		LegalName: os.Getenv("SELLER_LEGAL_NAME"),
+		Address:   os.Getenv("SELLER_ADDRESS"),
+		GSTIN:     os.Getenv("SELLER_GSTIN"),
+		StateCode: os.Getenv("SELLER_STATE_CODE"),
+		Prefix:    os.Getenv("INVOICE_PREFIX"),
+	}
+	if s.LegalName == "" {
+		s.LegalName = PlatformName()
+	}
+	if s.StateCode == "" && len(s.GSTIN) >= 2 {
+		s.StateCode = s.GSTIN[:2]
+	}
+	if s.Prefix == "" {
+		s.Prefix = "INV"
+	}
+	return s
+}
+
+// GSTRate → percentage charged (GST_RATE, default 18); 0 when the seller has no GSTIN
+func GSTRate(s Seller) float64 {
+	if s.GSTIN == "" {
+		return 0
+	}
+	if rate, err := strconv.ParseFloat(os.Getenv("GST_RATE"), 64); err == nil && rate >= 0 {
+		return rate
+	}
+	return 18
+}
+
+// FinancialYear → Indian financial year (April to March) containing t, e.g. "2026-27"
+func FinancialYear(t time.Time) string {
+	start := t.Year()
+	if t.Month() < time.April {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// GSTSplit is the tax inside a GST-inclusive amount
+type GSTSplit struct {
+	Taxable float64
+	CGST    float64
+	SGST    float64
+	IGST    float64
+}
+
+// SplitGST backs the tax out of an inclusive amount: CGST+SGST within the seller's state,
+// IGST across states. Rounding leftovers go to SGST so the parts always add up.
+func SplitGST(inclusive, rate float64, intraState bool) GSTSplit {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+
+	taxable := round(inclusive * 100 / (100 + rate))
+	tax := round(inclusive - taxable)
+	if !intraState {
+		return GSTSplit{Taxable: taxable, IGST: tax}
+	}
+	cgst := round(tax / 2)
+	return GSTSplit{Taxable: taxable, CGST: cgst, SGST: round(tax - cgst)}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Gateway payment states reported by webhooks
+const (
+	GatewayPaymentCaptured = "captured"
+	GatewayPaymentFailed   = "failed"
+	GatewayRefundProcessed = "refund_processed"
+)
+
+// OrderRequest asks the gateway for an order the student then pays at checkout
+type OrderRequest struct {
+	Amount   int64             // minor units (paise)
+	Currency string            // ISO 4217, e.g. INR
+	Receipt  string            // our reference, shown in the gateway dashboard
+	Notes    map[string]string // echoed back in webhooks
+}
+
+// Order is the gateway's side of a checkout; OrderID becomes Payment.TransactionID
+type Order struct {
+	OrderID  string `json:"order_id"`
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+	Status   string `json:"status"`
+	KeyID    string `json:"key_id,omitempty"` // public key the checkout widget needs
+	Gateway  string `json:"gateway"`
+}
+
+// Refund is a refund the gateway accepted
+type Refund struct {
+	RefundID  string `json:"refund_id"`
+	PaymentID string `json:"payment_id"`
+	Amount    int64  `json:"amount"`
+	Status    string `json:"status"`
+}
+
+// WebhookEvent is the part of a gateway notification we act on
+type WebhookEvent struct {
+	EventID   string // unique per delivery; empty if the gateway does not send one
+	Type      string // raw event name, e.g. payment.captured
+	Status    string // GatewayPaymentCaptured | GatewayPaymentFailed | GatewayRefundProcessed | "" (ignored)
+	OrderID   string
+	PaymentID string
+	RefundID  string // refund events only
+	Amount    int64
+	Fee       int64 // gateway charges on a capture, incl. their GST; 0 if not reported
+	Method    string
+	Reason    string // failure description
+}
+
+// PaymentGateway is what the platform needs from a payment provider
+type PaymentGateway interface {
+	Name() string
+	CreateOrder(req OrderRequest) (Order, error)
+	// VerifyPaymentSignature checks the signature the checkout widget returns to the browser
+	VerifyPaymentSignature(orderID, paymentID, signature string) bool
+	// VerifyWebhookSignature checks a webhook body against its signature header
+	VerifyWebhookSignature(body []byte, signature string) bool
+	ParseWebhook(body []byte) (WebhookEvent, error)
+	Refund(paymentID string, amount int64, notes map[string]string) (Refund, error)
+}
+
+var (
+	gateway     PaymentGateway
+	gatewayErr  error
+	gatewayOnce sync.Once
+)
+
+// PaymentDevMode → PAYMENT_DEV_MODE=true, which allows the fake gateway and payment simulation
+func PaymentDevMode() bool {
+	on, _ := strconv.ParseBool(os.Getenv("PAYMENT_DEV_MODE"))
+	return on
+}
+
+// Gateway → the configured payment gateway (PAYMENT_GATEWAY=razorpay|fake). There is no default,
+// and fake, which settles payments nobody paid for, is refused outside PAYMENT_DEV_MODE.
+func Gateway() (PaymentGateway, error) {
+	gatewayOnce.Do(func() {
+		switch strings.ToLower(os.Getenv("PAYMENT_GATEWAY")) {
+		case "razorpay":
+			gateway, gatewayErr = NewRazorpayGateway(
+				os.Getenv("RAZORPAY_KEY_ID"),
+				os.Getenv("RAZORPAY_KEY_SECRET"),
+				os.Getenv("RAZORPAY_WEBHOOK_SECRET"),
+				os.Getenv("RAZORPAY_BASE_URL"),
+			)
+		case "fake":
+			if !PaymentDevMode() {
+				gatewayErr = errors.New("PAYMENT_GATEWAY=fake is only allowed with PAYMENT_DEV_MODE=true")
+				return
+			}
+			gateway, gatewayErr = NewFakeGateway(os.Getenv("FAKE_GATEWAY_WEBHOOK_SECRET"))
+		case "":
+			gatewayErr = errors.New("PAYMENT_GATEWAY is not set (razorpay, or fake in development)")
+		default:
+			gatewayErr = fmt.Errorf("unknown PAYMENT_GATEWAY %q", os.Getenv("PAYMENT_GATEWAY"))
+		}
+	})
+	return gateway, gatewayErr
+}
+
+// RandomReference → prefix plus 16 random hex characters, for ids we mint ourselves
+func RandomReference(prefix string) string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+// ToMinorUnits converts a rupee amount to paise
+func ToMinorUnits(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromMinorUnits converts paise to rupees
+func FromMinorUnits(amount int64) float64 {
+	return float64(amount) / 100
+}
+
+// HMACSHA256Hex → hex HMAC-SHA256 of message, the signature scheme Razorpay uses everywhere
+func HMACSHA256Hex(secret string, message []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(message)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validHMAC compares a hex signature in constant time
+func validHMAC(secret string, message []byte, signature string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	return hmac.Equal([]byte(HMACSHA256Hex(secret, message)), []byte(strings.ToLower(signature)))
+}
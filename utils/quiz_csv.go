@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CSV layout (header row required, column order free):
+//
+//	question,difficulty,tags,correct,option_1,option_2,...
+//
+// tags are ";"-separated, correct is a ";"-separated list of 1-based option
+// numbers or letters (e.g. "2" or "A;C").
+
+func parseQuizCSV(data []byte) ([]QuizQuestion, []QuizImportError) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, []QuizImportError{{Line: 1, Message: "cannot read header row: " + err.Error()}}
+	}
+
+	col := map[string]int{}
+	var optionCols []int
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if strings.HasPrefix(name, "option") {
+			optionCols = append(optionCols, i)
+			continue
+		}
+		col[name] = i
+	}
+	if _, ok := col["question"]; !ok {
+		return nil, []QuizImportError{{Line: 1, Message: "header must contain a 'question' column"}}
+	}
+	if _, ok := col["correct"]; !ok {
+		return nil, []QuizImportError{{Line: 1, Message: "header must contain a 'correct' column"}}
+	}
+	if len(optionCols) == 0 {
+		return nil, []QuizImportError{{Line: 1, Message: "header must contain option_1, option_2, ... columns"}}
+	}
+	// Option numbers, not column positions, decide what "correct" refers to
+	optionNumber := func(i int) int {
+		n, _ := strconv.Atoi(strings.TrimLeft(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(header[i])), "option"), "_ "))
+		return n
+	}
+	sort.SliceStable(optionCols, func(a, b int) bool { return optionNumber(optionCols[a]) < optionNumber(optionCols[b]) })
+
+	get := func(record []string, name string) string {
+		if i, ok := col[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var questions []QuizQuestion
+	var errs []QuizImportError
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			line := 0
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				line = parseErr.StartLine
+			}
+			errs = append(errs, QuizImportError{Line: line, Message: err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if isBlankRecord(record) {
+			continue
+		}
+
+		q := QuizQuestion{
+			Line:       line,
+			Text:       get(record, "question"),
+			Difficulty: strings.ToLower(get(record, "difficulty")),
+			Tags:       SplitTags(strings.ReplaceAll(get(record, "tags"), ";", ",")),
+		}
+		// Trailing options may be left blank, but a gap would shift every later option's number
+		blank, gap := 0, false
+		for n, i := range optionCols {
+			text := ""
+			if i < len(record) {
+				text = strings.TrimSpace(record[i])
+			}
+			if text == "" {
+				blank++
+				continue
+			}
+			if blank > 0 {
+				errs = append(errs, QuizImportError{Line: line, Message: fmt.Sprintf("option %d is filled but an earlier option is blank", n+1)})
+				gap = true
+				break
+			}
+			q.Options = append(q.Options, QuizOption{Text: text})
+		}
+		if gap {
+			continue
+		}
+
+		for _, ref := range strings.Split(get(record, "correct"), ";") {
+			ref = strings.TrimSpace(ref)
+			if ref == "" {
+				continue
+			}
+			idx, ok := csvOptionIndex(ref)
+			if !ok || idx >= len(q.Options) {
+				errs = append(errs, QuizImportError{Line: line, Message: fmt.Sprintf("correct answer %q does not match any option", ref)})
+				continue
+			}
+			q.Options[idx].IsCorrect = true
+		}
+		questions = append(questions, q)
+	}
+	return questions, errs
+}
+
+// csvOptionIndex turns "2" or "B" into the 0-based option index 1
+func csvOptionIndex(ref string) (int, bool) {
+	if n, err := strconv.Atoi(ref); err == nil {
+		return n - 1, n >= 1
+	}
+	if len(ref) == 1 {
+		c := strings.ToUpper(ref)[0]
+		if c >= 'A' && c <= 'Z' {
+			return int(c - 'A'), true
+		}
+	}
+	return 0, false
+}
+
+func isBlankRecord(record []string) bool {
+	for _, f := range record {
+		if strings.TrimSpace(f) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func exportQuizCSV(questions []QuizQuestion) ([]byte, error) {
+	maxOptions := 0
+	for _, q := range questions {
+		if len(q.Options) > maxOptions {
+			maxOptions = len(q.Options)
+		}
+	}
+
+	header := []string{"question", "difficulty", "tags", "correct"}
+	for i := 1; i <= maxOptions; i++ {
+		header = append(header, fmt.Sprintf("option_%d", i))
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, q := range questions {
+		var correct []string
+		row := make([]string, len(header))
+		row[0] = q.Text
+		row[1] = q.Difficulty
+		row[2] = strings.Join(q.Tags, ";")
+		for i, o := range q.Options {
+			row[4+i] = o.Text
+			if o.IsCorrect {
+				correct = append(correct, strconv.Itoa(i+1))
+			}
+		}
+		row[3] = strings.Join(correct, ";")
+		if err := w.Write(row); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
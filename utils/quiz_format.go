@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Supported question interchange formats
+const (
+	QuizFormatCSV  = "csv"
+	QuizFormatGIFT = "gift"
+	QuizFormatQTI  = "qti"
+)
+
+// QuizOption is a format-neutral answer option
+type QuizOption struct {
+	Text      string
+	IsCorrect bool
+}
+
+// QuizQuestion is a format-neutral multiple-choice question used by importers and exporters.
+// Line is the 1-based line in the source file where the question starts (0 when unknown).
+type QuizQuestion struct {
+	Line       int
+	File       string
+	Text       string
+	Difficulty string
+	Tags       []string
+	Options    []QuizOption
+}
+
+// QuizImportError points at the offending line of an import file
+type QuizImportError struct {
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e QuizImportError) Error() string {
+	if e.File != "" {
+		return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// ParseQuiz parses a whole file in the given format and validates every question.
+// All problems are collected so the teacher can fix the file in one pass.
+func ParseQuiz(format string, data []byte) ([]QuizQuestion, []QuizImportError) {
+	var questions []QuizQuestion
+	var errs []QuizImportError
+
+	switch strings.ToLower(format) {
+	case QuizFormatCSV:
+		questions, errs = parseQuizCSV(data)
+	case QuizFormatGIFT:
+		questions, errs = parseQuizGIFT(data)
+	case QuizFormatQTI:
+		questions, errs = parseQuizQTI(data)
+	default:
+		return nil, []QuizImportError{{Message: "unsupported format: " + format}}
+	}
+
+	for _, q := range questions {
+		errs = append(errs, validateQuizQuestion(q)...)
+	}
+	if len(questions) == 0 && len(errs) == 0 {
+		errs = append(errs, QuizImportError{Message: "file contains no questions"})
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].File != errs[j].File {
+			return errs[i].File < errs[j].File
+		}
+		return errs[i].Line < errs[j].Line
+	})
+	return questions, errs
+}
+
+// ExportQuiz renders questions in the given format.
+// Returns the file body, its content type and the file extension to use.
+func ExportQuiz(format, title string, questions []QuizQuestion) ([]byte, string, string, error) {
+	switch strings.ToLower(format) {
+	case QuizFormatCSV:
+		body, err := exportQuizCSV(questions)
+		return body, "text/csv", "csv", err
+	case QuizFormatGIFT:
+		body, err := exportQuizGIFT(questions)
+		return body, "text/plain; charset=utf-8", "gift.txt", err
+	case QuizFormatQTI:
+		body, err := exportQuizQTI(title, questions)
+		return body, "application/zip", "qti.zip", err
+	default:
+		return nil, "", "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+func validateQuizQuestion(q QuizQuestion) []QuizImportError {
+	var errs []QuizImportError
+	fail := func(msg string) {
+		errs = append(errs, QuizImportError{File: q.File, Line: q.Line, Message: msg})
+	}
+
+	if strings.TrimSpace(q.Text) == "" {
+		fail("question text is empty")
+	}
+	if len(q.Options) < 2 {
+		fail("question needs at least 2 options")
+	}
+	correct := 0
+	for i, o := range q.Options {
+		if strings.TrimSpace(o.Text) == "" {
+			fail(fmt.Sprintf("option %d is empty", i+1))
+		}
+		if o.IsCorrect {
+			correct++
+		}
+	}
+	if len(q.Options) > 0 && correct == 0 {
+		fail("question has no correct option")
+	}
+	return errs
+}
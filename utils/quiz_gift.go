@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Moodle GIFT support covers multiple-choice, multiple-answer (%weights%),
+// true/false and missing-word questions. Difficulty and tags travel in a
+// comment line right above the question:
+//
+//	// difficulty: easy; tags: loops, arrays
+//	::Q1:: Which keyword starts a loop in Go? {=for ~while ~loop}
+
+var giftMetaRe = regexp.MustCompile(`(?i)^//\s*(difficulty|tags)\s*:`)
+
+type giftBlock struct {
+	line int
+	text string
+	meta string
+}
+
+func parseQuizGIFT(data []byte) ([]QuizQuestion, []QuizImportError) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+
+	// Split into question blocks separated by blank lines (outside of answer braces)
+	var blocks []giftBlock
+	var current giftBlock
+	var body []string
+	pendingMeta := ""
+	depth := 0
+	flush := func() {
+		if len(body) > 0 {
+			current.text = strings.Join(body, "\n")
+			blocks = append(blocks, current)
+		}
+		current, body = giftBlock{}, nil
+	}
+	for i, raw := range lines {
+		line := strings.TrimSpace(raw)
+		switch {
+		case depth == 0 && line == "":
+			flush()
+			continue
+		case depth == 0 && strings.HasPrefix(line, "//"):
+			if giftMetaRe.MatchString(line) {
+				pendingMeta += ";" + strings.TrimSpace(strings.TrimPrefix(line, "//"))
+			}
+			continue
+		case depth == 0 && len(body) == 0 && strings.HasPrefix(line, "$CATEGORY:"):
+			continue
+		}
+		if len(body) == 0 {
+			current.line = i + 1
+			current.meta, pendingMeta = pendingMeta, ""
+		}
+		body = append(body, raw)
+		depth += giftBraceDelta(raw)
+		if depth < 0 {
+			depth = 0
+		}
+	}
+	flush()
+
+	var questions []QuizQuestion
+	var errs []QuizImportError
+	for _, b := range blocks {
+		q, err := parseGIFTBlock(b)
+		if err != nil {
+			errs = append(errs, QuizImportError{Line: b.line, Message: err.Error()})
+			continue
+		}
+		questions = append(questions, q)
+	}
+	return questions, errs
+}
+
+// giftBraceDelta counts unescaped { minus unescaped } in a line
+func giftBraceDelta(s string) int {
+	delta := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			delta++
+		case '}':
+			delta--
+		}
+	}
+	return delta
+}
+
+// giftIndex finds the first unescaped occurrence of c at or after from
+func giftIndex(s string, c byte, from int) int {
+	for i := from; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func parseGIFTBlock(b giftBlock) (QuizQuestion, error) {
+	q := QuizQuestion{Line: b.line}
+	for _, part := range strings.Split(b.meta, ";") {
+		key, value, ok := strings.Cut(part, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "difficulty":
+			q.Difficulty = strings.ToLower(strings.TrimSpace(value))
+		case "tags":
+			q.Tags = SplitTags(value)
+		}
+	}
+
+	text := strings.TrimSpace(b.text)
+
+	// Optional ::title::
+	if strings.HasPrefix(text, "::") {
+		end := strings.Index(text[2:], "::")
+		if end < 0 {
+			return q, fmt.Errorf("unterminated ::title::")
+		}
+		text = strings.TrimSpace(text[end+4:])
+	}
+	// Optional [html] / [markdown] / [plain] format marker
+	if strings.HasPrefix(text, "[") {
+		if end := strings.Index(text, "]"); end > 0 {
+			text = strings.TrimSpace(text[end+1:])
+		}
+	}
+
+	open := giftIndex(text, '{', 0)
+	if open < 0 {
+		return q, fmt.Errorf("missing answer block { ... }")
+	}
+	closing := giftIndex(text, '}', open+1)
+	if closing < 0 {
+		return q, fmt.Errorf("missing closing }")
+	}
+
+	before := strings.TrimSpace(text[:open])
+	after := strings.TrimSpace(text[closing+1:])
+	if after != "" {
+		// Missing-word format: "Go was designed at {=Google ~Microsoft} in 2007."
+		q.Text = giftUnescape(before) + " _____ " + giftUnescape(after)
+	} else {
+		q.Text = giftUnescape(before)
+	}
+
+	answers := strings.TrimSpace(text[open+1 : closing])
+	switch strings.ToUpper(answers) {
+	case "":
+		return q, fmt.Errorf("essay questions are not supported")
+	case "T", "TRUE", "F", "FALSE":
+		isTrue := strings.HasPrefix(strings.ToUpper(answers), "T")
+		q.Options = []QuizOption{{Text: "True", IsCorrect: isTrue}, {Text: "False", IsCorrect: !isTrue}}
+		return q, nil
+	}
+	if strings.HasPrefix(answers, "#") {
+		return q, fmt.Errorf("numerical questions are not supported")
+	}
+
+	// Split answers on unescaped = / ~ markers
+	var tokens []string
+	start := -1
+	for i := 0; i < len(answers); i++ {
+		switch answers[i] {
+		case '\\':
+			i++
+		case '=', '~':
+			if start >= 0 {
+				tokens = append(tokens, answers[start:i])
+			}
+			start = i
+		}
+	}
+	if start < 0 {
+		return q, fmt.Errorf("answer block has no = or ~ answers")
+	}
+	tokens = append(tokens, answers[start:])
+
+	for _, tok := range tokens {
+		marker, rest := tok[0], tok[1:]
+		if strings.Contains(rest, "->") {
+			return q, fmt.Errorf("matching questions are not supported")
+		}
+		// Drop per-answer feedback
+		if hash := giftIndex(rest, '#', 0); hash >= 0 {
+			rest = rest[:hash]
+		}
+		rest = strings.TrimSpace(rest)
+
+		correct := marker == '='
+		if strings.HasPrefix(rest, "%") {
+			end := strings.Index(rest[1:], "%")
+			if end < 0 {
+				return q, fmt.Errorf("unterminated %%weight%%")
+			}
+			weight, err := strconv.ParseFloat(rest[1:end+1], 64)
+			if err != nil {
+				return q, fmt.Errorf("invalid weight %q", rest[1:end+1])
+			}
+			correct = weight > 0
+			rest = strings.TrimSpace(rest[end+2:])
+		}
+		q.Options = append(q.Options, QuizOption{Text: giftUnescape(rest), IsCorrect: correct})
+	}
+	return q, nil
+}
+
+var giftEscaper = strings.NewReplacer(
+	`\`, `\\`, `~`, `\~`, `=`, `\=`, `#`, `\#`, `{`, `\{`, `}`, `\}`, `:`, `\:`,
+)
+
+var giftUnescaper = strings.NewReplacer(
+	`\\`, `\`, `\~`, `~`, `\=`, `=`, `\#`, `#`, `\{`, `{`, `\}`, `}`, `\:`, `:`, `\n`, "\n",
+)
+
+func giftUnescape(s string) string {
+	return strings.TrimSpace(giftUnescaper.Replace(s))
+}
+
+func exportQuizGIFT(questions []QuizQuestion) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, q := range questions {
+		if q.Difficulty != "" || len(q.Tags) > 0 {
+			fmt.Fprintf(&buf, "// difficulty: %s; tags: %s\n", q.Difficulty, strings.Join(q.Tags, ", "))
+		}
+
+		correct := 0
+		for _, o := range q.Options {
+			if o.IsCorrect {
+				correct++
+			}
+		}
+
+		fmt.Fprintf(&buf, "::Q%d:: %s {\n", i+1, giftEscaper.Replace(q.Text))
+		for _, o := range q.Options {
+			text := giftEscaper.Replace(o.Text)
+			switch {
+			case correct <= 1 && o.IsCorrect:
+				fmt.Fprintf(&buf, "\t=%s\n", text)
+			case correct <= 1:
+				fmt.Fprintf(&buf, "\t~%s\n", text)
+			case o.IsCorrect:
+				fmt.Fprintf(&buf, "\t~%%%.5g%%%s\n", 100/float64(correct), text)
+			default:
+				fmt.Fprintf(&buf, "\t~%%-100%%%s\n", text)
+			}
+		}
+		buf.WriteString("}\n\n")
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,365 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// IMS QTI 2.1 support: content packages (zip with imsmanifest.xml and one
+// assessmentItem per file) or a single assessmentItem XML file. Only
+// choiceInteraction items are imported.
+
+const (
+	qtiNamespace   = "http://www.imsglobal.org/xsd/imsqti_v2p1"
+	qtiCPNamespace = "http://www.imsglobal.org/xsd/imscp_v1p1"
+	qtiItemType    = "imsqti_item_xmlv2p1"
+	qtiMatchRP     = "http://www.imsglobal.org/question/qti_v2p1/rptemplates/match_correct"
+)
+
+// Limits on uploaded packages, checked against the zip directory before anything is
+// decompressed (archive/zip refuses entries that inflate past their declared size)
+const (
+	qtiMaxEntries          = 1000
+	qtiMaxUncompressedSize = 50 << 20
+	qtiMaxFileSize         = 5 << 20
+)
+
+type qtiManifest struct {
+	Resources []struct {
+		Type string `xml:"type,attr"`
+		Href string `xml:"href,attr"`
+	} `xml:"resources>resource"`
+}
+
+func parseQuizQTI(data []byte) ([]QuizQuestion, []QuizImportError) {
+	if !bytes.HasPrefix(data, []byte("PK")) {
+		q, err := parseQTIItem("item.xml", data)
+		if err != nil {
+			return nil, []QuizImportError{*err}
+		}
+		return []QuizQuestion{q}, nil
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, []QuizImportError{{Message: "invalid QTI package: " + err.Error()}}
+	}
+	if len(archive.File) > qtiMaxEntries {
+		return nil, []QuizImportError{{Message: fmt.Sprintf("QTI package has more than %d files", qtiMaxEntries)}}
+	}
+	var total uint64
+	for _, f := range archive.File {
+		total += f.UncompressedSize64
+		if total > qtiMaxUncompressedSize {
+			return nil, []QuizImportError{{Message: fmt.Sprintf("QTI package unpacks to more than %d MB", qtiMaxUncompressedSize>>20)}}
+		}
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		files[path.Clean(f.Name)] = f
+	}
+
+	// Follow the manifest when present, otherwise take every XML file in the package
+	var hrefs []string
+	if mf, ok := files["imsmanifest.xml"]; ok {
+		body, err := readZipFile(mf)
+		if err != nil {
+			return nil, []QuizImportError{{File: "imsmanifest.xml", Message: err.Error()}}
+		}
+		var manifest qtiManifest
+		if err := xml.Unmarshal(body, &manifest); err != nil {
+			return nil, []QuizImportError{{File: "imsmanifest.xml", Line: xmlErrorLine(err), Message: err.Error()}}
+		}
+		for _, r := range manifest.Resources {
+			if strings.HasPrefix(r.Type, "imsqti_item") && r.Href != "" {
+				hrefs = append(hrefs, path.Clean(r.Href))
+			}
+		}
+	} else {
+		for _, f := range archive.File {
+			if strings.HasSuffix(strings.ToLower(f.Name), ".xml") {
+				hrefs = append(hrefs, path.Clean(f.Name))
+			}
+		}
+	}
+
+	var questions []QuizQuestion
+	var errs []QuizImportError
+	for _, href := range hrefs {
+		f, ok := files[href]
+		if !ok {
+			errs = append(errs, QuizImportError{File: "imsmanifest.xml", Message: "manifest references missing file " + href})
+			continue
+		}
+		body, err := readZipFile(f)
+		if err != nil {
+			errs = append(errs, QuizImportError{File: href, Message: err.Error()})
+			continue
+		}
+		q, itemErr := parseQTIItem(href, body)
+		if itemErr != nil {
+			errs = append(errs, *itemErr)
+			continue
+		}
+		questions = append(questions, q)
+	}
+	return questions, errs
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(io.LimitReader(rc, qtiMaxFileSize))
+}
+
+func xmlErrorLine(err error) int {
+	if syntaxErr, ok := err.(*xml.SyntaxError); ok {
+		return syntaxErr.Line
+	}
+	return 0
+}
+
+// parseQTIItem walks an assessmentItem token by token so errors can carry line numbers
+func parseQTIItem(file string, data []byte) (QuizQuestion, *QuizImportError) {
+	q := QuizQuestion{File: file, Line: 1}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	correct := map[string]map[string]bool{} // responseDeclaration identifier → correct choice IDs
+	var choiceIDs []string
+	var prompt, bodyText strings.Builder
+	var (
+		sawItem, sawChoice                   bool
+		inCorrect, inPrompt, inChoice        bool
+		inBody, inInteraction                bool
+		currentChoice                        *QuizOption
+		currentChoiceID, interactionResponse string
+		responseID                           string
+	)
+
+	for {
+		tok, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return q, &QuizImportError{File: file, Line: xmlErrorLine(err), Message: err.Error()}
+		}
+		line, _ := decoder.InputPos()
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "assessmentItem":
+				sawItem = true
+				q.Line = line
+			case "responseDeclaration":
+				responseID = qtiAttr(t, "identifier")
+			case "correctResponse":
+				inCorrect = true
+			case "itemBody":
+				inBody = true
+			case "choiceInteraction":
+				sawChoice, inInteraction = true, true
+				interactionResponse = qtiAttr(t, "responseIdentifier")
+			case "prompt":
+				inPrompt = true
+			case "simpleChoice":
+				if currentChoice != nil {
+					return q, &QuizImportError{File: file, Line: line, Message: "simpleChoice cannot be nested"}
+				}
+				inChoice = true
+				currentChoiceID = qtiAttr(t, "identifier")
+				currentChoice = &QuizOption{}
+			case "textEntryInteraction", "extendedTextInteraction", "matchInteraction",
+				"orderInteraction", "associateInteraction", "gapMatchInteraction":
+				return q, &QuizImportError{File: file, Line: line, Message: t.Name.Local + " items are not supported"}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "correctResponse":
+				inCorrect = false
+			case "itemBody":
+				inBody = false
+			case "choiceInteraction":
+				inInteraction = false
+			case "prompt":
+				inPrompt = false
+			case "simpleChoice":
+				if currentChoice == nil {
+					return q, &QuizImportError{File: file, Line: line, Message: "unexpected </simpleChoice>"}
+				}
+				inChoice = false
+				currentChoice.Text = strings.Join(strings.Fields(currentChoice.Text), " ")
+				q.Options = append(q.Options, *currentChoice)
+				choiceIDs = append(choiceIDs, currentChoiceID)
+				currentChoice = nil
+			}
+		case xml.CharData:
+			text := string(t)
+			switch {
+			case inCorrect:
+				if v := strings.TrimSpace(text); v != "" {
+					if correct[responseID] == nil {
+						correct[responseID] = map[string]bool{}
+					}
+					correct[responseID][v] = true
+				}
+			case inChoice:
+				currentChoice.Text += text
+			case inPrompt:
+				prompt.WriteString(text)
+			case inBody && !inInteraction:
+				bodyText.WriteString(text + " ")
+			}
+		}
+	}
+
+	if !sawItem {
+		return q, &QuizImportError{File: file, Line: 1, Message: "file is not a QTI assessmentItem"}
+	}
+	if !sawChoice {
+		return q, &QuizImportError{File: file, Line: q.Line, Message: "item has no choiceInteraction"}
+	}
+
+	q.Text = strings.Join(strings.Fields(prompt.String()), " ")
+	if q.Text == "" {
+		q.Text = strings.Join(strings.Fields(bodyText.String()), " ")
+	}
+	for i, id := range choiceIDs {
+		q.Options[i].IsCorrect = correct[interactionResponse][id]
+	}
+	return q, nil
+}
+
+func qtiAttr(t xml.StartElement, name string) string {
+	for _, a := range t.Attr {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// ---- export ----
+
+type qtiExportItem struct {
+	XMLName       xml.Name `xml:"assessmentItem"`
+	Xmlns         string   `xml:"xmlns,attr"`
+	Identifier    string   `xml:"identifier,attr"`
+	Title         string   `xml:"title,attr"`
+	Adaptive      bool     `xml:"adaptive,attr"`
+	TimeDependent bool     `xml:"timeDependent,attr"`
+	Response      struct {
+		Identifier  string   `xml:"identifier,attr"`
+		Cardinality string   `xml:"cardinality,attr"`
+		BaseType    string   `xml:"baseType,attr"`
+		Values      []string `xml:"correctResponse>value"`
+	} `xml:"responseDeclaration"`
+	Outcome struct {
+		Identifier  string `xml:"identifier,attr"`
+		Cardinality string `xml:"cardinality,attr"`
+		BaseType    string `xml:"baseType,attr"`
+	} `xml:"outcomeDeclaration"`
+	Interaction struct {
+		ResponseIdentifier string            `xml:"responseIdentifier,attr"`
+		Shuffle            bool              `xml:"shuffle,attr"`
+		MaxChoices         int               `xml:"maxChoices,attr"`
+		Prompt             string            `xml:"prompt"`
+		Choices            []qtiExportChoice `xml:"simpleChoice"`
+	} `xml:"itemBody>choiceInteraction"`
+	Processing struct {
+		Template string `xml:"template,attr"`
+	} `xml:"responseProcessing"`
+}
+
+type qtiExportChoice struct {
+	Identifier string `xml:"identifier,attr"`
+	Text       string `xml:",chardata"`
+}
+
+type qtiExportManifest struct {
+	XMLName    xml.Name            `xml:"manifest"`
+	Xmlns      string              `xml:"xmlns,attr"`
+	Identifier string              `xml:"identifier,attr"`
+	Orgs       struct{}            `xml:"organizations"`
+	Resources  []qtiExportResource `xml:"resources>resource"`
+}
+
+type qtiExportResource struct {
+	Identifier string `xml:"identifier,attr"`
+	Type       string `xml:"type,attr"`
+	Href       string `xml:"href,attr"`
+	File       struct {
+		Href string `xml:"href,attr"`
+	} `xml:"file"`
+}
+
+func exportQuizQTI(title string, questions []QuizQuestion) ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	manifest := qtiExportManifest{Xmlns: qtiCPNamespace, Identifier: "MANIFEST-1"}
+	for i, q := range questions {
+		id := fmt.Sprintf("item-%d", i+1)
+		href := "items/" + id + ".xml"
+
+		item := qtiExportItem{Xmlns: qtiNamespace, Identifier: id, Title: fmt.Sprintf("%s - Q%d", title, i+1)}
+		item.Response.Identifier = "RESPONSE"
+		item.Response.BaseType = "identifier"
+		item.Outcome.Identifier, item.Outcome.Cardinality, item.Outcome.BaseType = "SCORE", "single", "float"
+		item.Interaction.ResponseIdentifier = "RESPONSE"
+		item.Interaction.Prompt = q.Text
+		item.Processing.Template = qtiMatchRP
+
+		for j, o := range q.Options {
+			choiceID := fmt.Sprintf("choice-%d", j+1)
+			item.Interaction.Choices = append(item.Interaction.Choices, qtiExportChoice{Identifier: choiceID, Text: o.Text})
+			if o.IsCorrect {
+				item.Response.Values = append(item.Response.Values, choiceID)
+			}
+		}
+		item.Response.Cardinality = "single"
+		item.Interaction.MaxChoices = 1
+		if len(item.Response.Values) > 1 {
+			item.Response.Cardinality = "multiple"
+			item.Interaction.MaxChoices = len(item.Response.Values)
+		}
+
+		if err := writeZipXML(archive, href, item); err != nil {
+			return nil, err
+		}
+
+		res := qtiExportResource{Identifier: id, Type: qtiItemType, Href: href}
+		res.File.Href = href
+		manifest.Resources = append(manifest.Resources, res)
+	}
+
+	if err := writeZipXML(archive, "imsmanifest.xml", manifest); err != nil {
+		return nil, err
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeZipXML(archive *zip.Writer, name string, v interface{}) error {
+	w, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+)
+
+// AttemptSeed → deterministic seed for a student's paper, so the same
+// student always gets the same draw for the same assignment
+func AttemptSeed(assignmentID, userID uint) int64 {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "assignment:%d:user:%d", assignmentID, userID)
+	return int64(h.Sum64() & 0x7fffffffffffffff)
+}
+
+// ShuffleIDs shuffles ids in place using the given random source
+func ShuffleIDs(rng *rand.Rand, ids []uint) {
+	rng.Shuffle(len(ids), func(i, j int) {
+		ids[i], ids[j] = ids[j], ids[i]
+	})
+}
+
+// SplitTags turns "Loops, arrays ,," into ["loops", "arrays"]
+func SplitTags(tags string) []string {
+	var out []string
+	for _, t := range strings.Split(tags, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// JoinTags normalises a tag list into the comma-separated form stored in the DB
+func JoinTags(tags []string) string {
+	return strings.Join(SplitTags(strings.Join(tags, ",")), ",")
+}
+
+// HasTag reports whether a comma-separated tag list contains tag (case-insensitive)
+func HasTag(tags, tag string) bool {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	for _, t := range SplitTags(tags) {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const razorpayDefaultBaseURL = "https://api.razorpay.com"
+
+// RazorpayGateway talks to the Razorpay Orders/Refunds API. BaseURL can point at a
+// local stub server that speaks the same JSON.
+type RazorpayGateway struct {
+	KeyID         string
+	KeySecret     string
+	WebhookSecret string
+	BaseURL       string
+	Client        *http.Client
+}
+
+// NewRazorpayGateway → a gateway for the given credentials; baseURL defaults to the live API
+func NewRazorpayGateway(keyID, keySecret, webhookSecret, baseURL string) (*RazorpayGateway, error) {
+	if keyID == "" || keySecret == "" || webhookSecret == "" {
+		return nil, errors.New("RAZORPAY_KEY_ID, RAZORPAY_KEY_SECRET and RAZORPAY_WEBHOOK_SECRET are required")
+	}
+	if baseURL == "" {
+		baseURL = razorpayDefaultBaseURL
+	}
+	return &RazorpayGateway{
+		KeyID:         keyID,
+		KeySecret:     keySecret,
+		WebhookSecret: webhookSecret,
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		Client:        &http.Client{Timeout: 15 * time.Second},
+	}, nil
+}
+
+func (g *RazorpayGateway) Name() string { return "razorpay" }
+
+// post sends an authenticated JSON request and decodes the response into out
+func (g *RazorpayGateway) post(path string, body, out interface{}) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, g.BaseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(g.KeyID, g.KeySecret)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := g.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode >= 300 {
+		var apiErr struct {
+			Error struct {
+				Code        string `json:"code"`
+				Description string `json:"description"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Description != "" {
+			return fmt.Errorf("razorpay: %s (%s)", apiErr.Error.Description, apiErr.Error.Code)
+		}
+		return fmt.Errorf("razorpay: unexpected status %d", resp.StatusCode)
+	}
+	return json.Unmarshal(data, out)
+}
+
+// CreateOrder → POST /v1/orders
+func (g *RazorpayGateway) CreateOrder(req OrderRequest) (Order, error) {
+	var resp struct {
+		ID       string `json:"id"`
+		Amount   int64  `json:"amount"`
+		Currency string `json:"currency"`
+		Status   string `json:"status"`
+	}
+	body := map[string]interface{}{"amount": req.Amount, "currency": req.Currency, "receipt": req.Receipt}
+	if len(req.Notes) > 0 {
+		body["notes"] = req.Notes
+	}
+	if err := g.post("/v1/orders", body, &resp); err != nil {
+		return Order{}, err
+	}
+	if resp.ID == "" {
+		return Order{}, errors.New("razorpay: order response has no id")
+	}
+	return Order{OrderID: resp.ID, Amount: resp.Amount, Currency: resp.Currency, Status: resp.Status, KeyID: g.KeyID, Gateway: g.Name()}, nil
+}
+
+// VerifyPaymentSignature → checkout signature is HMAC(order_id|payment_id) with the key secret
+func (g *RazorpayGateway) VerifyPaymentSignature(orderID, paymentID, signature string) bool {
+	return validHMAC(g.KeySecret, []byte(orderID+"|"+paymentID), signature)
+}
+
+// VerifyWebhookSignature → X-Razorpay-Signature is HMAC(raw body) with the webhook secret
+func (g *RazorpayGateway) VerifyWebhookSignature(body []byte, signature string) bool {
+	return validHMAC(g.WebhookSecret, body, signature)
+}
+
+// razorpayWebhook is the subset of a Razorpay webhook body we read
+type razorpayWebhook struct {
+	Event   string `json:"event"`
+	Payload struct {
+		Payment struct {
+			Entity struct {
+				ID               string `json:"id"`
+				OrderID          string `json:"order_id"`
+				Amount           int64  `json:"amount"`
+				Fee              int64  `json:"fee"`
+				Status           string `json:"status"`
+				Method           string `json:"method"`
+				ErrorDescription string `json:"error_description"`
+			} `json:"entity"`
+		} `json:"payment"`
+		Refund struct {
+			Entity struct {
+				ID        string `json:"id"`
+				PaymentID string `json:"payment_id"`
+				Amount    int64  `json:"amount"`
+			} `json:"entity"`
+		} `json:"refund"`
+	} `json:"payload"`
+}
+
+// ParseWebhook maps payment.captured, order.paid, payment.failed and refund.processed;
+// other events come back with an empty Status
+func (g *RazorpayGateway) ParseWebhook(body []byte) (WebhookEvent, error) {
+	return parseRazorpayWebhook(body)
+}
+
+func parseRazorpayWebhook(body []byte) (WebhookEvent, error) {
+	var w razorpayWebhook
+	if err := json.Unmarshal(body, &w); err != nil {
+		return WebhookEvent{}, err
+	}
+	if w.Event == "" {
+		return WebhookEvent{}, errors.New("webhook has no event")
+	}
+
+	p := w.Payload.Payment.Entity
+	ev := WebhookEvent{Type: w.Event, OrderID: p.OrderID, PaymentID: p.ID, Amount: p.Amount, Method: p.Method}
+	switch w.Event {
+	case "payment.captured", "order.paid":
+		ev.Status, ev.Fee = GatewayPaymentCaptured, p.Fee
+	case "payment.failed":
+		ev.Status, ev.Reason = GatewayPaymentFailed, p.ErrorDescription
+	case "refund.processed":
+		r := w.Payload.Refund.Entity
+		ev.Status, ev.RefundID, ev.Amount = GatewayRefundProcessed, r.ID, r.Amount
+		if ev.PaymentID == "" {
+			ev.PaymentID = r.PaymentID
+		}
+	}
+	return ev, nil
+}
+
+// Refund → POST /v1/payments/:id/refund; amount 0 refunds in full
+func (g *RazorpayGateway) Refund(paymentID string, amount int64, notes map[string]string) (Refund, error) {
+	body := map[string]interface{}{}
+	if len(notes) > 0 {
+		body["notes"] = notes
+	}
+	if amount > 0 {
+		body["amount"] = amount
+	}
+	var resp struct {
+		ID        string `json:"id"`
+		PaymentID string `json:"payment_id"`
+		Amount    int64  `json:"amount"`
+		Status    string `json:"status"`
+	}
+	if err := g.post("/v1/payments/"+paymentID+"/refund", body, &resp); err != nil {
+		return Refund{}, err
+	}
+	return Refund{RefundID: resp.ID, PaymentID: resp.PaymentID, Amount: resp.Amount, Status: resp.Status}, nil
+}
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// defaultSigningKeyFile keeps a generated key across restarts when CERT_SIGNING_KEY is not set
+const defaultSigningKeyFile = "data/cert_signing.key"
+
+var (
+	signingKey     ed25519.PrivateKey
+	signingKeyErr  error
+	signingKeyOnce sync.Once
+)
+
+// SigningKey → the platform's Ed25519 key for signing certificates.
+// CERT_SIGNING_KEY holds the base64 32-byte seed; otherwise the seed is read from
+// CERT_SIGNING_KEY_FILE (default data/cert_signing.key), which is created on first use.
+func SigningKey() (ed25519.PrivateKey, error) {
+	signingKeyOnce.Do(func() {
+		signingKey, signingKeyErr = loadSigningKey()
+	})
+	return signingKey, signingKeyErr
+}
+
+func loadSigningKey() (ed25519.PrivateKey, error) {
+	if env := strings.TrimSpace(os.Getenv("CERT_SIGNING_KEY")); env != "" {
+		return seedToKey(env)
+	}
+
+	path := os.Getenv("CERT_SIGNING_KEY_FILE")
+	if path == "" {
+		path = defaultSigningKeyFile
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		return seedToKey(strings.TrimSpace(string(data)))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(seed)), 0600); err != nil {
+		return nil, err
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+func seedToKey(encoded string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("signing key is not valid base64: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key seed must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// SigningKeyID → short fingerprint of a public key, so signatures name the key they were made with
+func SigningKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// PublicKeyPEM encodes a public key as an SPKI PEM block
+func PublicKeyPEM(pub ed25519.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// Sign returns the base64url signature of payload and the ID of the key used
+func Sign(payload []byte) (signature, keyID string, err error) {
+	key, err := SigningKey()
+	if err != nil {
+		return "", "", err
+	}
+	pub := key.Public().(ed25519.PublicKey)
+	return base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, payload)), SigningKeyID(pub), nil
+}
+
+// VerifySignature checks a base64url signature made by Sign with the current key
+func VerifySignature(payload []byte, signature string) bool {
+	key, err := SigningKey()
+	if err != nil {
+		return false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(key.Public().(ed25519.PublicKey), payload, sig)
+}
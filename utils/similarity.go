@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"hash/fnv"
+	"sort"
+	"unicode"
+)
+
+// Winnowing parameters: 5-word shingles, one fingerprint kept per window of 4 shingles.
+// Any shared run of ShingleSize+WindowSize-1 words is guaranteed to be detected.
+const (
+	ShingleSize = 5
+	WindowSize  = 4
+)
+
+// Fingerprint is a selected shingle hash and the byte range of that shingle in the original text
+type Fingerprint struct {
+	Hash  uint64 `json:"h"`
+	Start int    `json:"s"`
+	End   int    `json:"e"`
+}
+
+// MatchSpan pairs a copied byte range in one text with where it appears in the other
+type MatchSpan struct {
+	Start      int `json:"start"`
+	End        int `json:"end"`
+	OtherStart int `json:"other_start"`
+	OtherEnd   int `json:"other_end"`
+}
+
+type word struct {
+	text       string
+	start, end int
+}
+
+// words splits text into lower-cased letter/digit runs, keeping byte offsets
+func words(text string) []word {
+	var out []word
+	start := -1
+	var buf []rune
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			buf = append(buf, unicode.ToLower(r))
+			continue
+		}
+		if start >= 0 {
+			out = append(out, word{string(buf), start, i})
+			start, buf = -1, buf[:0]
+		}
+	}
+	if start >= 0 {
+		out = append(out, word{string(buf), start, len(text)})
+	}
+	return out
+}
+
+// Winnow fingerprints text: hash every run of ShingleSize words and keep the minimum
+// hash of each window of WindowSize consecutive shingles (MOSS-style winnowing).
+// Texts shorter than one shingle have no fingerprints.
+func Winnow(text string) []Fingerprint {
+	ws := words(text)
+	if len(ws) < ShingleSize {
+		return nil
+	}
+
+	shingles := make([]Fingerprint, len(ws)-ShingleSize+1)
+	for i := range shingles {
+		h := fnv.New64a()
+		for _, w := range ws[i : i+ShingleSize] {
+			h.Write([]byte(w.text))
+			h.Write([]byte{0})
+		}
+		shingles[i] = Fingerprint{Hash: h.Sum64(), Start: ws[i].start, End: ws[i+ShingleSize-1].end}
+	}
+
+	window := WindowSize
+	if window > len(shingles) {
+		window = len(shingles)
+	}
+	var prints []Fingerprint
+	last := -1
+	for i := 0; i+window <= len(shingles); i++ {
+		// Rightmost minimum, so a minimum shared by overlapping windows is only kept once
+		min := i
+		for j := i; j < i+window; j++ {
+			if shingles[j].Hash <= shingles[min].Hash {
+				min = j
+			}
+		}
+		if min != last {
+			prints = append(prints, shingles[min])
+			last = min
+		}
+	}
+	return prints
+}
+
+// CompareFingerprints reports which share of a's and of b's fingerprints occur in the
+// other text, and the matched ranges of a (merged) with where they occur in b.
+func CompareFingerprints(a, b []Fingerprint) (shareA, shareB float64, spans []MatchSpan) {
+	if len(a) == 0 || len(b) == 0 {
+		return 0, 0, nil
+	}
+
+	inB := map[uint64]Fingerprint{}
+	for _, f := range b {
+		if _, ok := inB[f.Hash]; !ok {
+			inB[f.Hash] = f
+		}
+	}
+	inA := map[uint64]bool{}
+	for _, f := range a {
+		inA[f.Hash] = true
+	}
+
+	var matched []MatchSpan
+	for _, f := range a {
+		if other, ok := inB[f.Hash]; ok {
+			matched = append(matched, MatchSpan{f.Start, f.End, other.Start, other.End})
+		}
+	}
+	sharedB := 0
+	for _, f := range b {
+		if inA[f.Hash] {
+			sharedB++
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool { return matched[i].Start < matched[j].Start })
+	for _, m := range matched {
+		n := len(spans)
+		if n > 0 && m.Start <= spans[n-1].End {
+			if m.End > spans[n-1].End {
+				spans[n-1].End = m.End
+			}
+			if m.OtherStart < spans[n-1].OtherStart {
+				spans[n-1].OtherStart = m.OtherStart
+			}
+			if m.OtherEnd > spans[n-1].OtherEnd {
+				spans[n-1].OtherEnd = m.OtherEnd
+			}
+			continue
+		}
+		spans = append(spans, m)
+	}
+
+	return float64(len(matched)) / float64(len(a)), float64(sharedB) / float64(len(b)), spans
+}
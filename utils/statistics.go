@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"math"
+	"sort"
+)
+
+// Mean of xs (0 for an empty slice)
+func Mean(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// Median of xs (0 for an empty slice). xs is not modified.
+func Median(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), xs...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// Variance is the population variance of xs
+func Variance(xs []float64) float64 {
+	if len(xs) == 0 {
+		return 0
+	}
+	m := Mean(xs)
+	sum := 0.0
+	for _, x := range xs {
+		sum += (x - m) * (x - m)
+	}
+	return sum / float64(len(xs))
+}
+
+// StdDev is the population standard deviation of xs
+func StdDev(xs []float64) float64 {
+	return math.Sqrt(Variance(xs))
+}
+
+// HistogramBin counts values in [From, To); the last bin also includes To
+type HistogramBin struct {
+	From  float64 `json:"from"`
+	To    float64 `json:"to"`
+	Count int     `json:"count"`
+}
+
+// Histogram splits [min, max] into n equal bins and counts xs into them
+func Histogram(xs []float64, min, max float64, n int) []HistogramBin {
+	if n <= 0 || max <= min {
+		return nil
+	}
+	width := (max - min) / float64(n)
+	bins := make([]HistogramBin, n)
+	for i := range bins {
+		bins[i].From = min + float64(i)*width
+		bins[i].To = min + float64(i+1)*width
+	}
+	for _, x := range xs {
+		i := int((x - min) / width)
+		if i < 0 {
+			i = 0
+		}
+		if i >= n {
+			i = n - 1
+		}
+		bins[i].Count++
+	}
+	return bins
+}
+
+// CronbachAlpha estimates internal consistency from a respondents × items score matrix.
+// Every row must have the same number of items. ok is false when alpha is undefined
+// (fewer than 2 items or respondents, or no variance in total scores).
+func CronbachAlpha(matrix [][]float64) (alpha float64, ok bool) {
+	if len(matrix) < 2 || len(matrix[0]) < 2 {
+		return 0, false
+	}
+	k := len(matrix[0])
+
+	totals := make([]float64, len(matrix))
+	itemVarSum := 0.0
+	for item := 0; item < k; item++ {
+		column := make([]float64, len(matrix))
+		for r, row := range matrix {
+			column[r] = row[item]
+			totals[r] += row[item]
+		}
+		itemVarSum += Variance(column)
+	}
+
+	totalVar := Variance(totals)
+	if totalVar == 0 {
+		return 0, false
+	}
+	return float64(k) / float64(k-1) * (1 - itemVarSum/totalVar), true
+}